@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"ProxyBuild/proxy"
+)
+
+// collectAssets packt die embed_dirs aller Konfigurationen in ein Archiv. Die
+// Pfade sind relativ zum Verzeichnis der jeweiligen Konfigurationsdatei. Liefern
+// zwei Konfigurationen denselben Pfad mit anderem Inhalt, ist das ein Fehler.
+func collectAssets(commands []multiCallCommand) ([]byte, error) {
+	files := make(map[string]proxy.AssetFile)
+	sources := make(map[string]string)
+	for _, cmd := range commands {
+		configDir := filepath.Dir(cmd.ConfigFile)
+		for _, dir := range cmd.Config.EmbedDirs {
+			root := filepath.Join(configDir, filepath.FromSlash(dir))
+			err := filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					if d.Name() == ".git" {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				rel, err := filepath.Rel(configDir, file)
+				if err != nil {
+					return err
+				}
+				name := path.Clean(filepath.ToSlash(rel))
+
+				info, err := os.Stat(file)
+				if err != nil {
+					return err
+				}
+				if !info.Mode().IsRegular() {
+					return nil
+				}
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return err
+				}
+				// Ohne Ausführungsrecht (z.B. beim Build unter Windows) zählt die Shebang-Zeile
+				asset := proxy.AssetFile{Name: name, Data: data, Executable: info.Mode()&0111 != 0 || bytes.HasPrefix(data, []byte("#!"))}
+
+				if other, ok := files[name]; ok {
+					if !bytes.Equal(other.Data, data) {
+						return fmt.Errorf("%s ist in %s und %s mit unterschiedlichem Inhalt eingebettet", name, sources[name], cmd.ConfigFile)
+					}
+					return nil
+				}
+				files[name] = asset
+				sources[name] = cmd.ConfigFile
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("embed_dirs %s: %w", dir, err)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	list := make([]proxy.AssetFile, 0, len(files))
+	for _, file := range files {
+		list = append(list, file)
+	}
+	return proxy.EncodeAssets(list)
+}
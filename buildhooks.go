@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"ProxyBuild/proxy"
+)
+
+// build_hooks und smoke_tests der Konfiguration laufen beim Build, nicht im
+// Proxy. Build-Hooks bekommen PROXYBUILD_CONFIG, PROXYBUILD_OUTPUT,
+// PROXYBUILD_TARGET und PROXYBUILD_VERSION in der Umgebung.
+
+// smokeTestTimeout begrenzt einen einzelnen Aufruf des gebauten Executables
+const smokeTestTimeout = 30 * time.Second
+
+// configs liefert alle Konfigurationen des Builds mit ihrer Datei, bei einem
+// einzelnen Proxy mit leerem Befehlsnamen
+func (b *buildInput) configs(opts BuildOptions) []multiCallCommand {
+	if len(b.Commands) > 0 {
+		return b.Commands
+	}
+	return []multiCallCommand{{ConfigFile: opts.ConfigFile, Config: b.Config, ConfigData: b.ConfigData}}
+}
+
+// runBuildHooks führt die build_hooks mit when ("before" oder "after") aller
+// Konfigurationen aus. Sie laufen im Verzeichnis ihrer Konfigurationsdatei,
+// output ist das Executable bzw. bei -targets das Ausgabeverzeichnis.
+func runBuildHooks(when string, opts BuildOptions, input *buildInput, output, target string) error {
+	for _, cmd := range input.configs(opts) {
+		configPath, err := filepath.Abs(cmd.ConfigFile)
+		if err != nil {
+			return err
+		}
+		for i, hook := range cmd.Config.BuildHooks {
+			if hook.When != when {
+				continue
+			}
+			fmt.Printf("Build-Hook (%s): %s\n", when, strings.Join(append([]string{hook.Command}, hook.Args...), " "))
+			c, err := proxy.NewCommand(hook.Command, hook.Args, hook.Executor)
+			if err != nil {
+				return fmt.Errorf("%s: build_hooks[%d]: %w", cmd.ConfigFile, i, err)
+			}
+			c.Dir = filepath.Dir(configPath)
+			c.Env = append(os.Environ(),
+				"PROXYBUILD_CONFIG="+configPath,
+				"PROXYBUILD_OUTPUT="+output,
+				"PROXYBUILD_TARGET="+target,
+				"PROXYBUILD_VERSION="+proxyBuildVersion(),
+			)
+			c.Stdin = os.Stdin
+			c.Stdout = os.Stdout
+			c.Stderr = os.Stderr
+			if err := c.Run(); err != nil {
+				return fmt.Errorf("%s: build_hooks[%d] (%s) fehlgeschlagen: %w", cmd.ConfigFile, i, hook.Command, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runSmokeTests ruft das gebaute Executable mit den smoke_tests aller
+// Konfigurationen auf. Für andere Ziele als den Host werden sie übersprungen.
+func runSmokeTests(opts BuildOptions, input *buildInput, outputPath string) error {
+	var total int
+	for _, cmd := range input.configs(opts) {
+		total += len(cmd.Config.SmokeTests)
+	}
+	if total == 0 {
+		return nil
+	}
+	if targetOS(opts) != runtime.GOOS || targetArch(opts) != runtime.GOARCH {
+		fmt.Printf("Hinweis: %d Smoke-Tests übersprungen, %s/%s ist nicht der Host\n", total, targetOS(opts), targetArch(opts))
+		return nil
+	}
+
+	var failures []string
+	for _, cmd := range input.configs(opts) {
+		for i, test := range cmd.Config.SmokeTests {
+			label := test.Name
+			if label == "" {
+				label = fmt.Sprintf("smoke_tests[%d]", i)
+			}
+			if cmd.Name != "" {
+				label = cmd.Name + ": " + label
+			}
+			if err := runSmokeTest(outputPath, cmd, test); err != nil {
+				fmt.Printf("✗ Smoke-Test %s\n", label)
+				failures = append(failures, fmt.Sprintf("  %s: %s", label, strings.ReplaceAll(err.Error(), "\n", "\n    ")))
+				continue
+			}
+			fmt.Printf("✓ Smoke-Test %s\n", label)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d von %d Smoke-Tests fehlgeschlagen:\n%s", len(failures), total, strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// runSmokeTest führt einen Smoke-Test aus, bei einem Multi-Call-Executable mit
+// dem Befehlsnamen als erstem Argument
+func runSmokeTest(outputPath string, cmd multiCallCommand, test proxy.SmokeTest) error {
+	env := os.Environ()
+	if !test.RealCommand {
+		stubDir, err := os.MkdirTemp("", "proxybuild-smoke-*")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(stubDir)
+		if err := writeSmokeStub(stubDir, cmd.Config.BaseCommand, test.Stub); err != nil {
+			return err
+		}
+		env = append(env, "PATH="+stubDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	}
+	for key, value := range test.Env {
+		env = append(env, key+"="+value)
+	}
+
+	args := test.Args
+	if cmd.Name != "" {
+		args = append([]string{cmd.Name}, args...)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), smokeTestTimeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, outputPath, args...)
+	c.Env = env
+	out, err := c.CombinedOutput()
+
+	exitCode := 0
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		return fmt.Errorf("Zeitüberschreitung nach %s", smokeTestTimeout)
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+	case err != nil:
+		return err
+	}
+
+	var problems []string
+	if exitCode != test.ExitCode {
+		problems = append(problems, fmt.Sprintf("Exit-Code %d, erwartet %d", exitCode, test.ExitCode))
+	}
+	for _, want := range test.OutputContains {
+		if !strings.Contains(string(out), want) {
+			problems = append(problems, fmt.Sprintf("Ausgabe enthält %q nicht", want))
+		}
+	}
+	if test.OutputMatch != "" && !regexp.MustCompile(test.OutputMatch).Match(out) {
+		problems = append(problems, fmt.Sprintf("Ausgabe passt nicht zu %q", test.OutputMatch))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s\nAusgabe:\n%s", strings.Join(problems, ", "), strings.TrimRight(string(out), "\n"))
+	}
+	return nil
+}
+
+// writeSmokeStub legt in dir ein Skript mit dem Namen des Basis-Commands an.
+// Ohne stub.output gibt es seinen Namen und seine Argumente aus.
+func writeSmokeStub(dir, baseCommand string, stub *proxy.SmokeStub) error {
+	name, err := proxy.StubCommand(baseCommand)
+	if err != nil {
+		return err
+	}
+	if stub == nil {
+		stub = &proxy.SmokeStub{}
+	}
+	outputFile := filepath.Join(dir, name+".out")
+	if err := os.WriteFile(outputFile, []byte(stub.Output), 0644); err != nil {
+		return err
+	}
+
+	if runtime.GOOS == "windows" {
+		body := "echo " + name + " %*"
+		if stub.Output != "" {
+			body = fmt.Sprintf("type \"%s\"", outputFile)
+		}
+		script := fmt.Sprintf("@echo off\r\n%s\r\nexit /b %d\r\n", body, stub.ExitCode)
+		return os.WriteFile(filepath.Join(dir, name+".bat"), []byte(script), 0755)
+	}
+	body := fmt.Sprintf("echo \"%s $*\"", name)
+	if stub.Output != "" {
+		body = fmt.Sprintf("cat '%s'", outputFile)
+	}
+	script := fmt.Sprintf("#!/bin/sh\n%s\nexit %d\n", body, stub.ExitCode)
+	return os.WriteFile(filepath.Join(dir, name), []byte(script), 0755)
+}
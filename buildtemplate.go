@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"ProxyBuild/proxy"
+)
+
+// Eigene Templates (-template <dir>) ersetzen main.go des Runners. Alle Dateien
+// des Verzeichnisses werden neben runner.go kompiliert, *.go.tmpl-Dateien
+// vorher mit TemplateVars gerendert. Ein go.mod im Template (module
+// generated-proxy) bringt weitere Module mit, ProxyBuild ergänzt darin
+// ProxyBuild/proxy.
+
+// TemplateVars sind die Variablen in *.go.tmpl-Dateien
+type TemplateVars struct {
+	ConfigPath   string // Absoluter Pfad der (bei Multi-Call ersten) Konfigurationsdatei beim Build
+	ConfigSHA256 string
+	BinaryName   string // Name des Executables ohne .exe
+	Version      string // ProxyBuild-Version
+	Target       string // GOOS/GOARCH
+}
+
+// templateContract sind die Funktionen, die jedes Template definieren muss
+var templateContract = []struct {
+	Name string
+	Type string // Signatur ohne Parameternamen mit Importpfaden statt Paketnamen, zum Vergleich
+	Doc  string // Signatur für die Fehlermeldung
+}{
+	{"main", "func()", "func main() { runProxy() }"},
+	{"setupProxy", "func(*ProxyBuild/proxy.Config) error", "func setupProxy(config *proxy.Config) error"},
+	{"finishProxy", "func(*ProxyBuild/proxy.Config, error)", "func finishProxy(config *proxy.Config, err error)"},
+}
+
+// Dateien, die ProxyBuild selbst in das Build-Verzeichnis schreibt
+var reservedTemplateFiles = []string{"runner.go", "bundle.bin", "config_gen.go", "proxy"}
+
+// templateModule ist der Modulpfad des Build-Verzeichnisses
+const templateModule = "generated-proxy"
+
+// defaultGoMod ist die go.mod des Build-Verzeichnisses ohne go.mod im Template
+const defaultGoMod = "module " + templateModule + "\n\ngo 1.24\n" + proxyGoModDirectives
+
+// proxyGoModDirectives binden das mitgeschriebene proxy-Paket ein
+const proxyGoModDirectives = `
+require ProxyBuild/proxy v0.0.0
+
+replace ProxyBuild/proxy => ./proxy
+`
+
+// newTemplateVars sammelt die Template-Variablen für einen Build nach outputPath
+func newTemplateVars(opts BuildOptions, sections map[string][]byte, outputPath string) (TemplateVars, error) {
+	configPath, err := filepath.Abs(opts.ConfigFile)
+	if err != nil {
+		return TemplateVars{}, err
+	}
+	return TemplateVars{
+		ConfigPath:   configPath,
+		ConfigSHA256: proxy.BundleConfigSHA256(sections),
+		BinaryName:   strings.TrimSuffix(filepath.Base(outputPath), ".exe"),
+		Version:      proxyBuildVersion(),
+		Target:       targetOS(opts) + "/" + targetArch(opts),
+	}, nil
+}
+
+// renderTemplateDir liest das Template-Verzeichnis, rendert *.go.tmpl-Dateien
+// und prüft den Vertrag. Das Ergebnis bildet relative Pfade auf Inhalte ab.
+func renderTemplateDir(dir string, vars TemplateVars) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(rel, "_test.go") || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(rel, ".go.tmpl") {
+			tmpl, err := template.New(rel).Option("missingkey=error").Parse(string(data))
+			if err != nil {
+				return err
+			}
+			var out bytes.Buffer
+			if err := tmpl.Execute(&out, vars); err != nil {
+				return err
+			}
+			rel = strings.TrimSuffix(rel, ".tmpl")
+			data = out.Bytes()
+		}
+		if _, exists := files[rel]; exists {
+			return fmt.Errorf("%s existiert als .go und als .go.tmpl", rel)
+		}
+		files[rel] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Template %s: %w", dir, err)
+	}
+	if err := checkTemplateContract(files); err != nil {
+		return nil, fmt.Errorf("Template %s erfüllt den Vertrag nicht:\n  %s", dir, strings.ReplaceAll(err.Error(), "\n", "\n  "))
+	}
+	if goMod, ok := files["go.mod"]; ok {
+		files["go.mod"] = append(bytes.TrimRight(goMod, "\n"), "\n"+proxyGoModDirectives...)
+	}
+	return files, nil
+}
+
+// checkTemplateGoMod prüft eine go.mod aus dem Template: Modulpfad
+// generated-proxy, ProxyBuild/proxy trägt ProxyBuild selbst ein
+func checkTemplateGoMod(data []byte) error {
+	module := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "//")
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "module" {
+			module = strings.Trim(fields[1], "\"`")
+		}
+		if strings.Contains(line, "ProxyBuild/proxy") {
+			return errors.New("go.mod: ProxyBuild/proxy wird von ProxyBuild eingetragen und darf nicht in go.mod stehen")
+		}
+	}
+	if module != templateModule {
+		return fmt.Errorf("go.mod: module %q, erwartet module %s", module, templateModule)
+	}
+	return nil
+}
+
+// checkTemplateImports prüft, dass Go-Dateien ohne go.mod im Template nur die
+// Standardbibliothek, ProxyBuild/proxy und eigene Pakete importieren
+func checkTemplateImports(fset *token.FileSet, files map[string][]byte) []error {
+	names := make([]string, 0, len(files))
+	for rel := range files {
+		if strings.HasSuffix(rel, ".go") {
+			names = append(names, rel)
+		}
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, rel := range names {
+		file, err := parser.ParseFile(fset, rel, files[rel], parser.ImportsOnly)
+		if err != nil {
+			continue // Syntaxfehler meldet der Compiler
+		}
+		for _, spec := range file.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			first, _, _ := strings.Cut(path, "/")
+			// Pfade der Standardbibliothek haben keinen Punkt im ersten Element
+			if path == "ProxyBuild/proxy" || first == templateModule || !strings.Contains(first, ".") {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%s importiert %s: weitere Module brauchen go.mod (module %s) und go.sum im Template", rel, path, templateModule))
+		}
+	}
+	return errs
+}
+
+// checkTemplateContract prüft die Go-Dateien im Wurzelverzeichnis des Templates:
+// package main, alle Vertragsfunktionen mit passender Signatur und keine Namen,
+// die runner.go schon definiert
+func checkTemplateContract(files map[string][]byte) error {
+	reserved, err := runnerDeclarations()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, name := range reservedTemplateFiles {
+		for rel := range files {
+			if rel == name || strings.HasPrefix(rel, name+"/") {
+				errs = append(errs, fmt.Errorf("%s ist reserviert und wird von ProxyBuild geschrieben", rel))
+			}
+		}
+	}
+
+	fset := token.NewFileSet()
+	if goMod, ok := files["go.mod"]; ok {
+		if err := checkTemplateGoMod(goMod); err != nil {
+			errs = append(errs, err)
+		}
+	} else {
+		errs = append(errs, checkTemplateImports(fset, files)...)
+	}
+
+	names := make([]string, 0, len(files))
+	for rel := range files {
+		if !strings.Contains(rel, "/") && strings.HasSuffix(rel, ".go") {
+			names = append(names, rel)
+		}
+	}
+	sort.Strings(names)
+
+	type templateFunc struct {
+		decl    *ast.FuncDecl
+		imports map[string]string // Paketname in der Datei → Importpfad
+	}
+	funcs := make(map[string]templateFunc)
+	for _, rel := range names {
+		file, err := parser.ParseFile(fset, rel, files[rel], parser.SkipObjectResolution)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if file.Name.Name != "main" {
+			errs = append(errs, fmt.Errorf("%s: package %s, erwartet package main", rel, file.Name.Name))
+			continue
+		}
+		imports := fileImports(file)
+		for _, decl := range file.Decls {
+			for _, name := range declaredNames(decl) {
+				if reserved[name] {
+					errs = append(errs, fmt.Errorf("%s: %s ist in runner.go definiert und darf nicht überschrieben werden", rel, name))
+				}
+			}
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name != "init" {
+				funcs[fn.Name.Name] = templateFunc{decl: fn, imports: imports}
+			}
+		}
+	}
+	if len(names) == 0 {
+		errs = append(errs, errors.New("keine Go-Dateien gefunden"))
+	}
+
+	for _, contract := range templateContract {
+		fn, ok := funcs[contract.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("Vertragsfunktion %s fehlt: %s", contract.Name, contract.Doc))
+			continue
+		}
+		// Verglichen wird mit Importpfaden, damit ein Alias für das proxy-Paket passt
+		got := funcTypeString(fset, fn.decl.Type)
+		if qualifyImports(fn.decl.Type, fn.imports); funcTypeString(fset, fn.decl.Type) != contract.Type {
+			errs = append(errs, fmt.Errorf("Vertragsfunktion %s hat die Signatur %s, erwartet %s", contract.Name, got, contract.Doc))
+		}
+	}
+	if fn, ok := funcs["main"]; ok && !callsFunction(fn.decl, "runProxy") {
+		errs = append(errs, errors.New("main muss runProxy() aufrufen"))
+	}
+	return errors.Join(errs...)
+}
+
+// runnerDeclarations liefert die Namen, die runner.go auf Paketebene definiert
+func runnerDeclarations() (map[string]bool, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "runner.go", runnerSource, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for _, decl := range file.Decls {
+		for _, name := range declaredNames(decl) {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+func declaredNames(decl ast.Decl) []string {
+	var names []string
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			names = append(names, d.Name.Name)
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.ValueSpec:
+				for _, name := range s.Names {
+					names = append(names, name.Name)
+				}
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			}
+		}
+	}
+	return names
+}
+
+// fileImports liefert die Paketnamen einer Datei mit ihren Importpfaden. Ohne
+// Alias ist der Paketname das letzte Element des Pfads.
+func fileImports(file *ast.File) map[string]string {
+	imports := make(map[string]string)
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path[strings.LastIndex(path, "/")+1:]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = path
+	}
+	return imports
+}
+
+// qualifyImports ersetzt in expr Paketnamen durch ihre Importpfade, aus
+// p.Config wird ProxyBuild/proxy.Config
+func qualifyImports(expr ast.Node, imports map[string]string) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok {
+				if path, ok := imports[pkg.Name]; ok {
+					pkg.Name = path
+				}
+			}
+		}
+		return true
+	})
+}
+
+// funcTypeString gibt eine Signatur ohne Parameternamen aus, z.B. "func(*proxy.Config) error"
+func funcTypeString(fset *token.FileSet, fn *ast.FuncType) string {
+	list := func(fields *ast.FieldList) []string {
+		var types []string
+		if fields == nil {
+			return types
+		}
+		for _, field := range fields.List {
+			var buf bytes.Buffer
+			printer.Fprint(&buf, fset, field.Type)
+			for range max(len(field.Names), 1) {
+				types = append(types, buf.String())
+			}
+		}
+		return types
+	}
+	s := "func(" + strings.Join(list(fn.Params), ", ") + ")"
+	switch results := list(fn.Results); len(results) {
+	case 0:
+	case 1:
+		s += " " + results[0]
+	default:
+		s += " (" + strings.Join(results, ", ") + ")"
+	}
+	return s
+}
+
+// callsFunction prüft, ob fn die Funktion name direkt aufruft
+func callsFunction(fn *ast.FuncDecl, name string) bool {
+	found := false
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if ident, ok := call.Fun.(*ast.Ident); ok && ident.Name == name {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// runnerTemplateFiles liefert main.go bzw. die Dateien des eigenen Templates für einen Build
+func runnerTemplateFiles(opts BuildOptions, bundle []byte, outputPath string) (map[string][]byte, error) {
+	if opts.TemplateDir == "" {
+		return map[string][]byte{"main.go": templateSource}, nil
+	}
+	sections := map[string][]byte{"config": nil}
+	if len(bundle) > 0 {
+		var err error
+		if sections, err = proxy.DecodeBundle(bundle); err != nil {
+			return nil, err
+		}
+	}
+	vars, err := newTemplateVars(opts, sections, outputPath)
+	if err != nil {
+		return nil, err
+	}
+	return renderTemplateDir(opts.TemplateDir, vars)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ProxyBuild/proxy"
+)
+
+// Der Build-Cache speichert kompilierte Proxies unter einem Hash ihrer Eingaben.
+// Ein Treffer wird kopiert, statt go build aufzurufen.
+
+// DefaultCacheMaxSize begrenzt den Build-Cache in MiB, ältere Einträge werden zuerst entfernt
+const DefaultCacheMaxSize = 1024
+
+// cacheStatsLockWait begrenzt das Warten auf stats.json, die Statistik ist
+// den Build nicht wert
+const cacheStatsLockWait = 5 * time.Second
+
+// cacheKeyGoEnv sind die Go-Einstellungen, die das kompilierte Executable
+// verändern, ohne in den Build-Flags aufzutauchen
+var cacheKeyGoEnv = []string{
+	"GOVERSION", "GOFLAGS", "CGO_ENABLED", "GOEXPERIMENT",
+	"GOAMD64", "GOARM", "GOARM64", "GO386", "GOPPC64", "GORISCV64", "GOMIPS", "GOMIPS64", "GOWASM",
+}
+
+// cacheStats zählt Treffer und Fehlschläge über alle Builds
+type cacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// buildCacheDir liefert das Verzeichnis der zwischengespeicherten Proxies
+func buildCacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "proxybuild", "builds"), nil
+}
+
+// buildCacheKey bildet den Hash über Konfiguration, Template, proxy-Paket,
+// Go-Version und -Einstellungen, Ziel, Build-Flags einschließlich -codegen und eigenem Template. Die Build-Zeit zählt
+// nicht dazu, ein Treffer behält die Metadaten des ursprünglichen Builds.
+func buildCacheKey(opts BuildOptions, bundle []byte, outputPath string) (string, error) {
+	sections, err := proxy.DecodeBundle(bundle)
+	if err != nil {
+		return "", err
+	}
+	if meta, err := proxy.BundleMetadata(sections, ""); err == nil && meta != nil {
+		meta.BuildTime = ""
+		sections[proxy.MetadataSection] = []byte(proxy.EncodeMetadata(meta))
+	}
+	// Die Signatur hängt von der Build-Zeit ab, der Schlüssel steckt in den Build-Flags
+	delete(sections, proxy.ConfigSignatureSection)
+
+	sourceHash, err := runnerSourceHash()
+	if err != nil {
+		return "", err
+	}
+	// In derselben Umgebung wie go build, Standardwerte wie GOARM hängen vom Ziel ab
+	goEnvCmd := exec.Command("go", append([]string{"env"}, cacheKeyGoEnv...)...)
+	goEnvCmd.Env = runnerBuildEnv(opts)
+	goEnv, err := goEnvCmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("Go-Umgebung nicht ermittelbar: %w", err)
+	}
+	buildArgs, err := runnerBuildArgs(opts, nil)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s/%s\x00%s\x00codegen=%t\x00", sourceHash, string(goEnv),
+		targetOS(opts), targetArch(opts), strings.Join(buildArgs, " "), opts.Codegen)
+	h.Write(proxy.EncodeBundle(sections))
+
+	// Eigene Templates können Namen und Ziel einsetzen, daher die gerenderten Dateien
+	if opts.TemplateDir != "" {
+		files, err := runnerTemplateFiles(opts, bundle, outputPath)
+		if err != nil {
+			return "", err
+		}
+		h.Write(proxy.EncodeBundle(files))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// compileCached kompiliert den Runner oder kopiert ihn aus dem Build-Cache
+func compileCached(opts BuildOptions, bundle []byte, outputPath string) error {
+	if opts.NoCache {
+		fmt.Printf("Kompiliere %s...\n", filepath.Base(outputPath))
+		return compileRunner(opts, bundle, outputPath)
+	}
+
+	dir, err := buildCacheDir()
+	if err != nil {
+		return err
+	}
+	key, err := buildCacheKey(opts, bundle, outputPath)
+	if err != nil {
+		return err
+	}
+	entry := filepath.Join(dir, key[:2], key)
+
+	if data, err := os.ReadFile(entry); err == nil {
+		if err := os.WriteFile(outputPath, data, 0755); err != nil {
+			return err
+		}
+		// Für das Aufräumen zählt die letzte Verwendung
+		now := time.Now()
+		os.Chtimes(entry, now, now)
+		recordCacheResult(dir, true)
+		fmt.Printf("✓ %s aus dem Build-Cache (%s)\n", filepath.Base(outputPath), key[:12])
+		return nil
+	}
+
+	recordCacheResult(dir, false)
+	fmt.Printf("Kompiliere %s...\n", filepath.Base(outputPath))
+	if err := compileRunner(opts, bundle, outputPath); err != nil {
+		return err
+	}
+	// Ein Fehler beim Speichern macht den Build nicht ungültig
+	if err := storeCacheEntry(entry, outputPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Warnung: Build-Cache nicht aktualisiert: %v\n", err)
+		return nil
+	}
+	if err := pruneBuildCache(dir, cacheMaxBytes(opts)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warnung: Build-Cache nicht aufgeräumt: %v\n", err)
+	}
+	return nil
+}
+
+func cacheMaxBytes(opts BuildOptions) int64 {
+	if opts.CacheMaxSize <= 0 {
+		return DefaultCacheMaxSize << 20
+	}
+	return opts.CacheMaxSize << 20
+}
+
+// storeCacheEntry kopiert outputPath über eine temporäre Datei in den Cache,
+// damit parallele Builds nie einen halben Eintrag sehen
+func storeCacheEntry(entry, outputPath string) error {
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(entry), 0755); err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.%d.tmp", entry, os.Getpid())
+	defer os.Remove(tmp)
+	if err := os.WriteFile(tmp, data, 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp, entry)
+}
+
+// cacheEntry ist ein Eintrag im Build-Cache
+type cacheEntry struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// listBuildCache liefert alle Einträge, die am längsten unbenutzten zuerst
+func listBuildCache(dir string) ([]cacheEntry, error) {
+	var entries []cacheEntry
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		// Einträge liegen in Unterverzeichnissen, daneben stats.json und seine Sperre
+		if d.IsDir() || filepath.Dir(path) == dir || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, cacheEntry{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ModTime.Before(entries[j].ModTime) })
+	return entries, err
+}
+
+// pruneBuildCache entfernt die ältesten Einträge, bis der Cache höchstens maxBytes groß ist
+func pruneBuildCache(dir string, maxBytes int64) error {
+	entries, err := listBuildCache(dir)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+	for _, e := range entries {
+		if total <= maxBytes {
+			break
+		}
+		if err := os.Remove(e.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= e.Size
+	}
+	return nil
+}
+
+// recordCacheResult zählt einen Treffer oder Fehlschlag in stats.json. Die
+// Sperre gilt auch für andere Prozesse, etwa parallele CI-Jobs mit demselben Cache.
+func recordCacheResult(dir string, hit bool) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	statsPath := filepath.Join(dir, "stats.json")
+	unlock, err := proxy.LockFile(statsPath, cacheStatsLockWait)
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	stats := readCacheStats(dir)
+	if hit {
+		stats.Hits++
+	} else {
+		stats.Misses++
+	}
+	data, _ := json.Marshal(stats)
+	proxy.WriteFileAtomic(statsPath, data, 0644)
+}
+
+func readCacheStats(dir string) cacheStats {
+	var stats cacheStats
+	if data, err := os.ReadFile(filepath.Join(dir, "stats.json")); err == nil {
+		json.Unmarshal(data, &stats)
+	}
+	return stats
+}
+
+// printCacheStats gibt Größe, Einträge und Trefferquote des Build-Caches aus
+func printCacheStats(maxSize int64) error {
+	dir, err := buildCacheDir()
+	if err != nil {
+		return err
+	}
+	entries, err := listBuildCache(dir)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+	stats := readCacheStats(dir)
+
+	fmt.Printf("Verzeichnis:  %s\n", dir)
+	fmt.Printf("Einträge:     %d\n", len(entries))
+	fmt.Printf("Größe:        %.1f MiB von %d MiB\n", float64(total)/(1<<20), cacheMaxBytes(BuildOptions{CacheMaxSize: maxSize})>>20)
+	fmt.Printf("Treffer:      %d\n", stats.Hits)
+	fmt.Printf("Fehlschläge:  %d\n", stats.Misses)
+	if requests := stats.Hits + stats.Misses; requests > 0 {
+		fmt.Printf("Trefferquote: %.0f%%\n", float64(stats.Hits)*100/float64(requests))
+	}
+	if len(entries) > 0 {
+		fmt.Printf("Zuletzt:      %s\n", entries[len(entries)-1].ModTime.Format(time.RFC3339))
+	}
+	return nil
+}
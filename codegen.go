@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"ProxyBuild/proxy"
+)
+
+// generateConfigSource erzeugt config_gen.go: die geprüfte Konfiguration als
+// Go-Literal, das der Compiler typprüft. Bedingungen werden zu Funktionen
+// vorkompiliert, os_match wird für goos aufgelöst. Bei einem Multi-Call-Executable
+// enthält configs die Konfigurationen je Befehl, sonst nur den Schlüssel "".
+func generateConfigSource(configs map[string]*proxy.Config, goos string) ([]byte, error) {
+	g := &configGenerator{imports: map[string]bool{"ProxyBuild/proxy": true}}
+
+	var body bytes.Buffer
+	if config, ok := configs[""]; ok {
+		fmt.Fprintf(&body, "func init() {\n\tgeneratedConfig = &")
+		if err := g.value(&body, reflect.ValueOf(runtimeConfig(config, goos)), "config"); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&body, "\n}\n")
+	} else {
+		names := make([]string, 0, len(configs))
+		for name := range configs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&body, "func init() {\n\tgeneratedConfigs = map[string]*proxy.Config{\n")
+		for _, name := range names {
+			fmt.Fprintf(&body, "%q: &", name)
+			if err := g.value(&body, reflect.ValueOf(runtimeConfig(configs[name], goos)), name); err != nil {
+				return nil, err
+			}
+			body.WriteString(",\n")
+		}
+		fmt.Fprintf(&body, "}\n}\n")
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by ProxyBuild %s for %s. DO NOT EDIT.\n\npackage main\n\nimport (\n", proxyBuildVersion(), goos)
+	imports := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	for _, path := range imports {
+		fmt.Fprintf(&src, "\t%q\n", path)
+	}
+	src.WriteString(")\n\n")
+	src.Write(body.Bytes())
+	if g.ptrHelper {
+		src.WriteString("\nfunc ptr[T any](v T) *T { return &v }\n")
+	}
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generierter Code ist ungültig: %w", err)
+	}
+	return formatted, nil
+}
+
+// runtimeConfig entfernt, was nur für den Build relevant ist, und die Hooks
+// anderer Betriebssysteme
+func runtimeConfig(config *proxy.Config, goos string) proxy.Config {
+	stripped := *config
+	// Nur für den Build relevant
+	stripped.BuildEnvAllowlist = nil
+	stripped.Package = nil
+	stripped.BuildHooks = nil
+	stripped.SmokeTests = nil
+	stripped.EmbedDirs = nil
+	stripped.Hooks = make(map[string][]proxy.Hook, len(config.Hooks))
+	for subCommand, hooks := range config.Hooks {
+		var kept []proxy.Hook
+		for _, hook := range hooks {
+			if hook.Conditions.OsMatch != nil && !slices.Contains(hook.Conditions.OsMatch, goos) {
+				continue
+			}
+			kept = append(kept, hook)
+		}
+		stripped.Hooks[subCommand] = kept
+	}
+	return stripped
+}
+
+// writeGeneratedConfig schreibt die Konfigurationen aus bundle als Go-Code nach path
+func writeGeneratedConfig(opts BuildOptions, bundle []byte, path string) error {
+	sections, err := proxy.DecodeBundle(bundle)
+	if err != nil {
+		return err
+	}
+	configs := make(map[string]*proxy.Config)
+	names := proxy.MultiCallNames(sections)
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	for _, name := range names {
+		if configs[name], err = proxy.LoadBundleConfigFor(sections, name); err != nil {
+			return err
+		}
+	}
+	src, err := generateConfigSource(configs, targetOS(opts))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, src, 0644)
+}
+
+// configGenerator schreibt Werte aus dem proxy-Paket als Go-Literale
+type configGenerator struct {
+	imports   map[string]bool
+	ptrHelper bool
+}
+
+var hookType = reflect.TypeOf(proxy.Hook{})
+
+func (g *configGenerator) typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + g.typeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + g.typeName(t.Elem())
+	case reflect.Map:
+		return "map[" + g.typeName(t.Key()) + "]" + g.typeName(t.Elem())
+	}
+	if t.PkgPath() == "" {
+		return t.Name()
+	}
+	return "proxy." + t.Name()
+}
+
+// value schreibt v als Literal. path benennt das Feld für Fehlermeldungen,
+// z.B. config.Hooks["build"][0].Command.
+func (g *configGenerator) value(w *bytes.Buffer, v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.String:
+		if v.Type().PkgPath() != "" {
+			fmt.Fprintf(w, "%s(%q)", g.typeName(v.Type()), v.String())
+		} else {
+			fmt.Fprintf(w, "%q", v.String())
+		}
+	case reflect.Bool:
+		fmt.Fprintf(w, "%t", v.Bool())
+	case reflect.Int, reflect.Int64:
+		fmt.Fprintf(w, "%d", v.Int())
+	case reflect.Pointer:
+		if v.IsNil() {
+			w.WriteString("nil")
+			return nil
+		}
+		if v.Elem().Kind() == reflect.Struct {
+			w.WriteString("&")
+			return g.value(w, v.Elem(), path)
+		}
+		g.ptrHelper = true
+		w.WriteString("ptr(")
+		if err := g.value(w, v.Elem(), path); err != nil {
+			return err
+		}
+		w.WriteString(")")
+	case reflect.Slice:
+		fmt.Fprintf(w, "%s{", g.typeName(v.Type()))
+		for i := 0; i < v.Len(); i++ {
+			w.WriteString("\n")
+			if err := g.value(w, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+			w.WriteString(",")
+		}
+		w.WriteString("\n}")
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("codegen: %s: nicht unterstützter Schlüsseltyp %s", path, v.Type().Key())
+		}
+		fmt.Fprintf(w, "%s{", g.typeName(v.Type()))
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			fmt.Fprintf(w, "\n%q: ", key.String())
+			if err := g.value(w, v.MapIndex(key), fmt.Sprintf("%s[%q]", path, key.String())); err != nil {
+				return err
+			}
+			w.WriteString(",")
+		}
+		w.WriteString("\n}")
+	case reflect.Struct:
+		fmt.Fprintf(w, "%s{", g.typeName(v.Type()))
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || field.Type.Kind() == reflect.Func || v.Field(i).IsZero() {
+				continue
+			}
+			// Bedingungen werden als Match-Funktion vorkompiliert
+			if v.Type() == hookType && field.Name == "Conditions" {
+				continue
+			}
+			fmt.Fprintf(w, "\n%s: ", field.Name)
+			if err := g.value(w, v.Field(i), path+"."+field.Name); err != nil {
+				return err
+			}
+			w.WriteString(",")
+		}
+		if v.Type() == hookType {
+			g.matcher(w, v.Interface().(proxy.Hook).Conditions)
+		}
+		w.WriteString("\n}")
+	default:
+		return fmt.Errorf("codegen: %s: nicht unterstützter Typ %s", path, v.Type())
+	}
+	return nil
+}
+
+// matcher schreibt die Bedingungen eines Hooks als Match-Funktion
+func (g *configGenerator) matcher(w *bytes.Buffer, c proxy.Conditions) {
+	var checks []string
+	if c.OnError != nil {
+		if *c.OnError {
+			checks = append(checks, "hadError")
+		} else {
+			checks = append(checks, "!hadError")
+		}
+	}
+	for _, substr := range c.ArgsContain {
+		g.imports["slices"] = true
+		g.imports["strings"] = true
+		checks = append(checks, fmt.Sprintf("slices.ContainsFunc(args, func(arg string) bool { return strings.Contains(arg, %q) })", substr))
+	}
+	for _, arg := range c.ArgsMatch {
+		g.imports["slices"] = true
+		checks = append(checks, fmt.Sprintf("slices.Contains(args, %q)", arg))
+	}
+	if len(checks) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nMatch: func(args []string, hadError bool) bool {\nreturn %s\n},", strings.Join(checks, " &&\n"))
+}
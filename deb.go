@@ -0,0 +1,208 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// debArch übersetzt GOARCH in die Debian-Architektur
+var debArch = map[string]string{
+	"amd64":   "amd64",
+	"arm64":   "arm64",
+	"386":     "i386",
+	"arm":     "armhf",
+	"ppc64le": "ppc64el",
+	"riscv64": "riscv64",
+	"s390x":   "s390x",
+}
+
+// writeDeb erzeugt ein Debian-Paket: ein ar-Archiv aus debian-binary,
+// control.tar.gz und data.tar.gz
+func writeDeb(w io.Writer, pkg packageInput) error {
+	arch, ok := debArch[pkg.Arch]
+	if !ok {
+		return fmt.Errorf("deb: keine Debian-Architektur für %s", pkg.Arch)
+	}
+
+	data, err := debData(pkg)
+	if err != nil {
+		return err
+	}
+	control, err := debControl(pkg, arch)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, "!<arch>\n"); err != nil {
+		return err
+	}
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", control},
+		{"data.tar.gz", data},
+	} {
+		if err := writeArMember(w, member.name, member.data, pkg.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeArMember schreibt einen Eintrag im gemeinsamen ar-Format
+func writeArMember(w io.Writer, name string, data []byte, modTime time.Time) error {
+	header := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8s%-10d`\n", name, modTime.Unix(), 0, 0, "100644", len(data))
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	// Einträge beginnen immer an geraden Offsets
+	if len(data)%2 != 0 {
+		_, err := w.Write([]byte{'\n'})
+		return err
+	}
+	return nil
+}
+
+// debControl erzeugt control.tar.gz mit der control-Datei und den md5sums
+func debControl(pkg packageInput, arch string) ([]byte, error) {
+	var control strings.Builder
+	fmt.Fprintf(&control, "Package: %s\n", pkg.Name)
+	fmt.Fprintf(&control, "Version: %s-%s\n", pkg.Version, pkg.Release)
+	fmt.Fprintf(&control, "Architecture: %s\n", arch)
+	maintainer := pkg.Maintainer
+	if maintainer == "" {
+		maintainer = "unknown"
+	}
+	fmt.Fprintf(&control, "Maintainer: %s\n", maintainer)
+	fmt.Fprintf(&control, "Installed-Size: %d\n", (len(pkg.Binary)+1023)/1024)
+	control.WriteString("Section: utils\n")
+	control.WriteString("Priority: optional\n")
+	if pkg.Homepage != "" {
+		fmt.Fprintf(&control, "Homepage: %s\n", pkg.Homepage)
+	}
+	fmt.Fprintf(&control, "Description: %s\n", debDescription(pkg.Summary(), pkg.Description))
+
+	md5sums := fmt.Sprintf("%x  %s\n", md5.Sum(pkg.Binary), strings.TrimPrefix(pkg.BinaryPath(), "/"))
+	entries := []tarEntry{
+		{Name: "./", Mode: 0755, Dir: true},
+		{Name: "./control", Mode: 0644, Data: []byte(control.String())},
+	}
+	// Das profile.d-Skript liegt in /etc und ist damit eine conffile
+	if script, data := pkg.ProfileScript(); script != "" {
+		md5sums += fmt.Sprintf("%x  %s\n", md5.Sum(data), strings.TrimPrefix(script, "/"))
+		entries = append(entries, tarEntry{Name: "./conffiles", Mode: 0644, Data: []byte(script + "\n")})
+	}
+	entries = append(entries, tarEntry{Name: "./md5sums", Mode: 0644, Data: []byte(md5sums)})
+	return tarGz(entries, pkg.ModTime)
+}
+
+// debDescription formatiert eine mehrzeilige Beschreibung für die control-Datei
+func debDescription(summary, description string) string {
+	var b strings.Builder
+	b.WriteString(summary)
+	// Die erste Zeile der Beschreibung ist bereits die Zusammenfassung
+	first, rest, _ := strings.Cut(strings.TrimSpace(description), "\n")
+	if first != summary {
+		rest = strings.TrimSpace(description)
+	}
+	if strings.TrimSpace(rest) == "" {
+		return b.String()
+	}
+	for _, line := range strings.Split(strings.TrimSpace(rest), "\n") {
+		if strings.TrimSpace(line) == "" {
+			line = "."
+		}
+		b.WriteString("\n " + line)
+	}
+	return b.String()
+}
+
+// debData erzeugt data.tar.gz mit dem Executable, den Verzeichnissen und Symlinks
+func debData(pkg packageInput) ([]byte, error) {
+	entries := []tarEntry{{Name: "./", Mode: 0755, Dir: true}}
+	seen := make(map[string]bool)
+	addDirs := func(dir string) {
+		var parts []string
+		for _, part := range strings.Split(strings.Trim(dir, "/"), "/") {
+			parts = append(parts, part)
+			name := "./" + strings.Join(parts, "/") + "/"
+			if !seen[name] {
+				seen[name] = true
+				entries = append(entries, tarEntry{Name: name, Mode: 0755, Dir: true})
+			}
+		}
+	}
+
+	addDirs(pkg.BinDir)
+	entries = append(entries, tarEntry{Name: "." + pkg.BinaryPath(), Mode: 0755, Data: pkg.Binary})
+	for _, link := range pkg.Symlinks {
+		addDirs(path.Dir(link))
+		entries = append(entries, tarEntry{Name: "." + link, Mode: 0777, Link: pkg.BinaryPath()})
+	}
+	if script, data := pkg.ProfileScript(); script != "" {
+		addDirs(path.Dir(script))
+		entries = append(entries, tarEntry{Name: "." + script, Mode: 0644, Data: data})
+	}
+	return tarGz(entries, pkg.ModTime)
+}
+
+// tarEntry ist eine Datei, ein Verzeichnis oder ein Symlink in einem tar-Archiv
+type tarEntry struct {
+	Name string
+	Mode int64
+	Data []byte
+	Dir  bool
+	Link string
+}
+
+// tarGz schreibt die Einträge als tar.gz mit root als Besitzer
+func tarGz(entries []tarEntry, modTime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:    entry.Name,
+			Mode:    entry.Mode,
+			ModTime: modTime,
+			Uname:   "root",
+			Gname:   "root",
+			Format:  tar.FormatGNU,
+		}
+		switch {
+		case entry.Dir:
+			header.Typeflag = tar.TypeDir
+		case entry.Link != "":
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = entry.Link
+		default:
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(entry.Data))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(entry.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
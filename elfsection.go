@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+)
+
+// Name der ELF-Section, die das angehängte Bundle beschreibt
+const bundleSectionName = ".proxybuild"
+
+// appendBundle hängt das Bundle an einen Runner-Stub an. Bei ELF-Dateien wird
+// das Bundle zusätzlich als eigene Section eingetragen, damit strip es behält.
+// Das Bundle liegt in beiden Fällen am Ende der Datei.
+func appendBundle(stub, bundle []byte) ([]byte, error) {
+	if !bytes.HasPrefix(stub, []byte(elf.ELFMAG)) {
+		return append(append([]byte{}, stub...), bundle...), nil
+	}
+	return appendELFSection(stub, bundle)
+}
+
+// appendELFSection schreibt hinter den Stub eine erweiterte Section-Namenstabelle,
+// eine neue Section-Header-Tabelle mit der zusätzlichen Section und zuletzt das Bundle
+func appendELFSection(stub, bundle []byte) ([]byte, error) {
+	f, err := elf.NewFile(bytes.NewReader(stub))
+	if err != nil {
+		return nil, fmt.Errorf("ELF-Stub: %w", err)
+	}
+	defer f.Close()
+
+	is64 := f.Class == elf.ELFCLASS64
+	order := f.ByteOrder
+
+	// Position der Felder im ELF-Header und Größe eines Section-Headers
+	var shoff uint64
+	var shentsize, shnum, shstrndx int
+	if is64 {
+		shoff = order.Uint64(stub[0x28:])
+		shentsize = int(order.Uint16(stub[0x3A:]))
+		shnum = int(order.Uint16(stub[0x3C:]))
+		shstrndx = int(order.Uint16(stub[0x3E:]))
+	} else {
+		shoff = uint64(order.Uint32(stub[0x20:]))
+		shentsize = int(order.Uint16(stub[0x2E:]))
+		shnum = int(order.Uint16(stub[0x30:]))
+		shstrndx = int(order.Uint16(stub[0x32:]))
+	}
+	if shnum == 0 || shstrndx >= shnum || shoff+uint64(shnum*shentsize) > uint64(len(stub)) {
+		return nil, fmt.Errorf("ELF-Stub ohne gültige Section-Header")
+	}
+	headers := stub[shoff : shoff+uint64(shnum*shentsize)]
+
+	out := bytes.NewBuffer(append([]byte{}, stub...))
+	pad := func(align int) {
+		for out.Len()%align != 0 {
+			out.WriteByte(0)
+		}
+	}
+
+	// Erweiterte Namenstabelle
+	strtab := f.Sections[shstrndx]
+	names, err := strtab.Data()
+	if err != nil {
+		return nil, err
+	}
+	strtabOffset := uint64(out.Len())
+	nameOffset := uint32(len(names))
+	out.Write(names)
+	out.WriteString(bundleSectionName)
+	out.WriteByte(0)
+	strtabSize := uint64(out.Len()) - strtabOffset
+
+	// Neue Section-Header-Tabelle
+	pad(8)
+	newShoff := uint64(out.Len())
+	bundleOffset := newShoff + uint64((shnum+1)*shentsize)
+
+	table := append([]byte{}, headers...)
+	strtabHeader := table[shstrndx*shentsize : (shstrndx+1)*shentsize]
+	section := make([]byte, shentsize)
+	if is64 {
+		order.PutUint64(strtabHeader[24:], strtabOffset)
+		order.PutUint64(strtabHeader[32:], strtabSize)
+
+		order.PutUint32(section[0:], nameOffset)
+		order.PutUint32(section[4:], uint32(elf.SHT_PROGBITS))
+		order.PutUint64(section[24:], bundleOffset)
+		order.PutUint64(section[32:], uint64(len(bundle)))
+		order.PutUint64(section[48:], 1)
+	} else {
+		order.PutUint32(strtabHeader[16:], uint32(strtabOffset))
+		order.PutUint32(strtabHeader[20:], uint32(strtabSize))
+
+		order.PutUint32(section[0:], nameOffset)
+		order.PutUint32(section[4:], uint32(elf.SHT_PROGBITS))
+		order.PutUint32(section[16:], uint32(bundleOffset))
+		order.PutUint32(section[20:], uint32(len(bundle)))
+		order.PutUint32(section[32:], 1)
+	}
+	out.Write(table)
+	out.Write(section)
+
+	// Bundle am Ende, damit der Runner es ohne Suche findet
+	out.Write(bundle)
+
+	data := out.Bytes()
+	if is64 {
+		order.PutUint64(data[0x28:], newShoff)
+		order.PutUint16(data[0x3C:], uint16(shnum+1))
+	} else {
+		order.PutUint32(data[0x20:], uint32(newShoff))
+		order.PutUint16(data[0x30:], uint16(shnum+1))
+	}
+	return data, nil
+}
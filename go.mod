@@ -1,7 +1,3 @@
 module ProxyBuild
 
 go 1.24
-
-require ProxyBuild/proxy v0.0.0
-
-replace ProxyBuild/proxy => ./proxy
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"ProxyBuild/proxy"
+)
+
+// configGitCommit liefert den Commit des Repositories, in dem die Konfiguration
+// liegt, mit "-dirty", wenn die Datei geändert ist. Ohne git oder Repository leer.
+func configGitCommit(configFile string) string {
+	dir, file := filepath.Split(configFile)
+	if dir == "" {
+		dir = "."
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	commit := strings.TrimSpace(string(out))
+	status, err := exec.Command("git", "-C", dir, "status", "--porcelain", "--", file).Output()
+	if err == nil && len(bytes.TrimSpace(status)) > 0 {
+		commit += "-dirty"
+	}
+	return commit
+}
+
+// inspectExecutable gibt Metadaten und Konfiguration eines gebauten Proxys aus.
+// Der Proxy wird nicht ausgeführt, das funktioniert auch für andere Plattformen.
+func inspectExecutable(path, pubkey string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// Beim Compile-Build liegt das Bundle mitten im Executable, daher die ganze Datei durchsuchen
+	sections, err := proxy.FindBundle(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	meta, err := proxy.BundleMetadata(sections, "")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Executable:    %s\n", path)
+	proxy.WriteMetadata(os.Stdout, meta)
+	if info, err := buildinfo.Read(bytes.NewReader(data)); err == nil {
+		fmt.Printf("Go:            %s\n", info.GoVersion)
+	}
+
+	switch _, signed := sections[proxy.ConfigSignatureSection]; {
+	case !signed:
+		fmt.Println("Signatur:      keine")
+	case pubkey == "":
+		fmt.Println("Signatur:      vorhanden (mit -pubkey prüfen)")
+	default:
+		key, err := loadPublicKey(pubkey)
+		if err != nil {
+			return err
+		}
+		if err := proxy.VerifyBundle(sections, key.String()); err != nil {
+			return err
+		}
+		fmt.Printf("Signatur:      gültig (Schlüssel %s)\n", key.ID())
+	}
+
+	if archive, ok := sections[proxy.AssetsSection]; ok {
+		files, err := proxy.DecodeAssets(archive)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Assets:        %d Dateien, %d Bytes\n", len(files), len(archive))
+		for _, file := range files {
+			fmt.Printf("               %s\n", file.Name)
+		}
+	}
+
+	names := proxy.MultiCallNames(sections)
+	if len(names) == 0 {
+		return printConfigJSON("Konfiguration", sections["config"])
+	}
+	fmt.Printf("Befehle:       %s\n", strings.Join(names, ", "))
+	for _, name := range names {
+		if err := printConfigJSON("Konfiguration "+name, sections[proxy.MultiCallPrefix+name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printConfigJSON gibt eine eingebettete Konfiguration eingerückt aus
+func printConfigJSON(title string, data []byte) error {
+	var config bytes.Buffer
+	if err := json.Indent(&config, data, "", "  "); err != nil {
+		return fmt.Errorf("eingebettete Konfiguration: %w", err)
+	}
+	fmt.Printf("\n%s:\n%s\n", title, config.String())
+	return nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"ProxyBuild/proxy"
+)
+
+// -install legt Shims für einen gebauten Proxy an, -uninstall und
+// -list-installed verwalten sie über die Registry (proxy.ShimRegistry).
+
+// InstallOptions steuern -install
+type InstallOptions struct {
+	Binary  string // Gebauter Proxy
+	As      string // Name des Shims, leer: aus base_command bzw. alle Befehle eines Multi-Call-Executables
+	ShimDir string // Leer: proxy.DefaultShimDir
+}
+
+// shimDir liefert das absolute Shim-Verzeichnis aus -shim-dir oder den Standard
+func shimDir(flagValue string) (string, error) {
+	if flagValue == "" {
+		return proxy.DefaultShimDir()
+	}
+	return filepath.Abs(flagValue)
+}
+
+// installShims legt die Shims an, trägt sie in die Registry ein und zeigt,
+// wie das Shim-Verzeichnis in den PATH kommt
+func installShims(w io.Writer, opts InstallOptions) error {
+	target, err := filepath.Abs(opts.Binary)
+	if err != nil {
+		return err
+	}
+	if target, err = filepath.EvalSymlinks(target); err != nil {
+		return err
+	}
+	names, err := shimNames(target, opts.As)
+	if err != nil {
+		return err
+	}
+	dir, err := shimDir(opts.ShimDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	registry, err := proxy.LoadShimRegistry()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range names {
+		link := filepath.Join(dir, proxy.ShimFileName(name))
+		if link == target {
+			errs = append(errs, fmt.Errorf("%s ist der Proxy selbst", link))
+			continue
+		}
+		if _, err := os.Lstat(link); err == nil {
+			_, registered := registry.Find(link)
+			switch {
+			case registered && proxy.SameFile(link, target):
+				fmt.Fprintf(w, "✓ %s (vorhanden)\n", link)
+				continue
+			case !registered:
+				errs = append(errs, fmt.Errorf("%s existiert bereits und wurde nicht mit -install angelegt", link))
+				continue
+			}
+			// Eigener Shim für einen anderen Proxy: ersetzen
+			if err := os.Remove(link); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		if err := proxy.LinkExecutable(target, link); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		registry.Remove(link)
+		registry.Shims = append(registry.Shims, proxy.InstalledShim{Name: name, Path: link, Target: target, ShimDir: dir, Installed: time.Now().UTC()})
+		fmt.Fprintf(w, "✓ %s -> %s\n", link, target)
+	}
+	if err := registry.Save(); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	writePathHint(w, dir, names)
+	return nil
+}
+
+// shimNames bestimmt die Namen der Shims: as, sonst alle Befehle eines
+// Multi-Call-Executables oder der Name des Basis-Befehls
+func shimNames(target, as string) ([]string, error) {
+	data, err := os.ReadFile(target)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := proxy.FindBundle(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s ist kein mit ProxyBuild gebauter Proxy: %w", target, err)
+	}
+	commands := proxy.MultiCallNames(sections)
+	if as != "" {
+		if strings.ContainsAny(as, `/\`) {
+			return nil, fmt.Errorf("--as %q: nur ein Name, kein Pfad", as)
+		}
+		if len(commands) > 0 && !slices.Contains(commands, as) {
+			return nil, fmt.Errorf("--as %q: kein Befehl von %s, verfügbar: %s", as, filepath.Base(target), strings.Join(commands, ", "))
+		}
+		return []string{as}, nil
+	}
+	if len(commands) > 0 {
+		return commands, nil
+	}
+	config, err := proxy.LoadBundleConfig(sections)
+	if err != nil {
+		return nil, err
+	}
+	name := multiCallName(config)
+	if name == "" {
+		return nil, fmt.Errorf("aus base_command %q lässt sich kein Name ableiten, --as angeben", config.BaseCommand)
+	}
+	return []string{name}, nil
+}
+
+// writePathHint prüft, ob die Shims im PATH Vorrang haben, und gibt sonst die
+// Zeile für die Shell des Benutzers aus
+func writePathHint(w io.Writer, dir string, names []string) {
+	var shadowed []string
+	for _, name := range names {
+		found, err := exec.LookPath(name)
+		if err != nil || filepath.Dir(found) != dir {
+			shadowed = append(shadowed, name)
+		}
+	}
+	if len(shadowed) == 0 {
+		fmt.Fprintf(w, "✓ %s steht im PATH vor den ursprünglichen Befehlen\n", dir)
+		return
+	}
+
+	file, line := pathSnippet(dir)
+	fmt.Fprintf(w, "\n%s muss im PATH vor den ursprünglichen Befehlen stehen (%s). ", dir, strings.Join(shadowed, ", "))
+	fmt.Fprintf(w, "Dafür in %s eintragen und eine neue Shell öffnen:\n\n  %s\n", file, line)
+}
+
+// pathSnippet liefert die Datei und die Zeile, mit der die Shell des Benutzers
+// dir an den Anfang des PATH setzt
+func pathSnippet(dir string) (file, line string) {
+	if runtime.GOOS == "windows" {
+		return "das PowerShell-Profil ($PROFILE)", fmt.Sprintf(`$env:Path = "%s;" + $env:Path`, dir)
+	}
+	shellDir := dir
+	if home, err := os.UserHomeDir(); err == nil {
+		if rel, err := filepath.Rel(home, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			shellDir = "$HOME/" + filepath.ToSlash(rel)
+		}
+	}
+	switch filepath.Base(os.Getenv("SHELL")) {
+	case "fish":
+		return "~/.config/fish/config.fish", fmt.Sprintf(`fish_add_path --prepend "%s"`, shellDir)
+	case "zsh":
+		return "~/.zshrc", fmt.Sprintf(`export PATH="%s:$PATH"`, shellDir)
+	default:
+		return "~/.bashrc", fmt.Sprintf(`export PATH="%s:$PATH"`, shellDir)
+	}
+}
+
+// uninstallShims entfernt die Shims mit dem Namen name, mit -shim-dir nur die
+// in diesem Verzeichnis. Dateien, die inzwischen etwas anderes sind, bleiben
+// stehen, nur der Eintrag in der Registry wird entfernt.
+func uninstallShims(w io.Writer, name, shimDirFlag string) error {
+	registry, err := proxy.LoadShimRegistry()
+	if err != nil {
+		return err
+	}
+	var dir string
+	if shimDirFlag != "" {
+		if dir, err = filepath.Abs(shimDirFlag); err != nil {
+			return err
+		}
+	}
+
+	var matches []proxy.InstalledShim
+	for _, shim := range registry.Shims {
+		if shim.Name == name && (dir == "" || filepath.Clean(shim.ShimDir) == filepath.Clean(dir)) {
+			matches = append(matches, shim)
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("kein Shim %q installiert (siehe -list-installed)", name)
+	}
+	for _, shim := range matches {
+		switch status := shimStatus(shim); status {
+		case "", "Proxy fehlt":
+			if err := os.Remove(shim.Path); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "✓ Entfernt: %s\n", shim.Path)
+		default:
+			fmt.Fprintf(w, "Hinweis: %s nicht gelöscht (%s), nur aus der Registry entfernt\n", shim.Path, status)
+		}
+		registry.Remove(shim.Path)
+	}
+	return registry.Save()
+}
+
+// listInstalledShims gibt alle Shims aus der Registry mit ihrem Zustand aus
+func listInstalledShims(w io.Writer) error {
+	registry, err := proxy.LoadShimRegistry()
+	if err != nil {
+		return err
+	}
+	if len(registry.Shims) == 0 {
+		fmt.Fprintln(w, "Keine Shims installiert")
+		return nil
+	}
+	for _, shim := range registry.Shims {
+		status := shimStatus(shim)
+		if status == "" {
+			status = "ok"
+		}
+		fmt.Fprintf(w, "%-20s %s -> %s (%s)\n", shim.Name, shim.Path, shim.Target, status)
+	}
+	return nil
+}
+
+// shimStatus prüft einen Shim, leer heißt in Ordnung
+func shimStatus(shim proxy.InstalledShim) string {
+	if _, err := os.Lstat(shim.Path); err != nil {
+		return "Shim fehlt"
+	}
+	if proxy.SameFile(shim.Path, shim.Target) {
+		return ""
+	}
+	// Ein Symlink auf den gelöschten Proxy gehört noch zum Shim
+	if dest, err := os.Readlink(shim.Path); err == nil && dest == shim.Target {
+		return "Proxy fehlt"
+	}
+	return "zeigt auf eine andere Datei"
+}
@@ -1,47 +1,252 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"crypto/ed25519"
+	_ "embed"
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"ProxyBuild/proxy"
 )
 
+// version wird beim Release per -ldflags "-X main.version=v1.2.3" gesetzt
+var version = "dev"
+
+// Template und proxy-Paket sind eingebettet, damit ProxyBuild aus jedem Verzeichnis bauen kann
+//
+//go:embed template/main.go
+var templateSource []byte
+
+//go:embed template/runner.go
+var runnerSource []byte
+
 type BuildOptions struct {
-	ConfigFile string
-	GOOS       string
-	GOARCH     string
-	OutputName string
+	ConfigFile  string
+	ConfigFiles []string // Mehrere Konfigurationen ergeben ein Multi-Call-Executable, ConfigFile ist die erste
+	GOOS        string
+	GOARCH      string
+	OutputName  string
+
+	AllowSecrets bool              // Gefundene Secrets nur melden statt den Build abzubrechen
+	SecretsKey   SecretsKeyOptions // Schlüsselquelle zum Prüfen der verschlüsselten Secrets
+
+	Strategy string // "stub" (Standard) oder "compile"
+	StubDir  string // Verzeichnis mit vorgebauten Runner-Stubs
+
+	Codegen     bool   // Konfiguration als Go-Code generieren und mitkompilieren
+	TemplateDir string // Eigenes Template statt template/main.go
+
+	NoCache      bool  // Build-Cache für kompilierte Proxies umgehen
+	CacheMaxSize int64 // Maximale Größe des Build-Caches in MiB
+
+	SBOM               string // SBOM-Format: cyclonedx oder spdx (leer: keine SBOM)
+	VerifyReproducible bool   // Zweiten Build ohne Cache erstellen und vergleichen
+	BuildTimeNow       bool   // Aktuelle Zeit statt eines reproduzierbaren Zeitstempels, siehe buildTime
+
+	SignKey      string // ed25519-Schlüssel (PEM) für minisign-Signaturen der Artefakte und der Konfiguration
+	TamperPolicy string // Verhalten des Runners bei ungültiger Konfigurationssignatur: refuse oder no-hooks
+
+	Publish string // Update-Feed-Verzeichnis, in dem die Executables veröffentlicht werden
+
+	Pin bool // Pfad und Prüfsumme des Basis-Commands auf dem Build-Rechner festlegen
+
+	configPublicKey     string // Wird von loadSigner gesetzt und in den Runner kompiliert
+	trustedBaseCommands string // Mit -tamper-policy no-hooks aus dem Bundle, wird in den Runner kompiliert
 }
 
 func main() {
-	buildCmd := flag.String("build", "", "Erstellt ein neues ausführbares Programm mit der angegebenen Konfigurationsdatei")
+	buildCmd := flag.String("build", "", "Erstellt ein neues ausführbares Programm mit der angegebenen Konfigurationsdatei, mit weiteren Dateien ein Multi-Call-Executable")
 	configFile := flag.String("config", "", "Konfigurationsdatei für den Proxy-Modus")
 	goos := flag.String("os", "", "Ziel-Betriebssystem für Cross-Compilation (z.B. linux, darwin, windows)")
 	goarch := flag.String("arch", "", "Ziel-Architektur für Cross-Compilation (z.B. amd64, arm64)")
 	outputName := flag.String("output", "", "Name des Output-Executables (optional)")
+	allowSecrets := flag.Bool("allow-secrets", false, "Warnt bei möglichen Secrets in der Konfiguration, statt den Build abzubrechen")
+	strategy := flag.String("strategy", StrategyStub, "Build-Strategie: stub (Runner-Stub + Trailer, ohne Kompilieren) oder compile")
+	stubDir := flag.String("stub-dir", "", "Verzeichnis mit vorgebauten Runner-Stubs (runner-<os>-<arch>)")
+	targets := flag.String("targets", "", "Baut für mehrere Ziele (z.B. linux/amd64,windows/amd64 oder all) und verpackt die Executables")
+	jobs := flag.Int("jobs", 0, "Maximale Anzahl paralleler Builds bei -targets (Standard: Anzahl CPUs)")
+	distDir := flag.String("dist", "dist", "Ausgabeverzeichnis für Archive, SHA256SUMS und manifest.json bei -targets")
+	archive := flag.String("archive", ArchiveAuto, "Archivformat bei -targets: auto (zip für Windows, sonst tar.gz), tar.gz oder zip")
+	nameTemplate := flag.String("name-template", DefaultNameTemplate, "Namens-Template für Archive mit {{.Name}}, {{.OS}} und {{.Arch}}")
+	sbom := flag.String("sbom", "", "Erstellt eine SBOM neben dem Executable: cyclonedx oder spdx")
+	templateDir := flag.String("template", "", "Verzeichnis mit eigenem Template und Go-Dateien, die mitkompiliert werden (impliziert -strategy compile)")
+	codegen := flag.Bool("codegen", false, "Generiert die Konfiguration als Go-Code, der Proxy parst beim Start kein JSON (impliziert -strategy compile)")
+	noCache := flag.Bool("no-cache", false, "Kompiliert ohne Build-Cache und speichert das Ergebnis nicht")
+	cacheMaxSize := flag.Int64("cache-max-size", DefaultCacheMaxSize, "Maximale Größe des Build-Caches in MiB, ältere Einträge werden entfernt")
+	cacheStatsFlag := flag.Bool("cache-stats", false, "Zeigt Größe, Einträge und Trefferquote des Build-Caches an")
+	verifyRepro := flag.Bool("verify-reproducible", false, "Baut ein zweites Mal ohne Cache und prüft, ob beide Executables identisch sind")
+	buildTimeNow := flag.Bool("build-time-now", false, "Verwendet die aktuelle Zeit als Build-Zeit statt SOURCE_DATE_EPOCH bzw. der Commit-Zeit (nicht reproduzierbar)")
+	signKey := flag.String("sign-key", "", "Signiert die erstellten Dateien mit diesem ed25519-Schlüssel (PEM) im minisign-Format")
+	verify := flag.String("verify", "", "Prüft die Signatur <datei>.minisig der angegebenen Datei")
+	pubkey := flag.String("pubkey", "", "Öffentlicher Schlüssel für -verify und -inspect (.pub-Datei, PEM-Datei oder base64)")
+	inspect := flag.String("inspect", "", "Zeigt Build-Metadaten und Konfiguration eines gebauten Proxys an")
+	tamperPolicy := flag.String("tamper-policy", proxy.PolicyRefuse, "Verhalten bei ungültig signierter Konfiguration (mit -sign-key): refuse oder no-hooks")
+	publish := flag.String("publish", "", "Veröffentlicht das Executable im Update-Feed-Verzeichnis der update-Section (erfordert -sign-key)")
+	install := flag.String("install", "", "Installiert Shims für den angegebenen Proxy im Shim-Verzeichnis")
+	installAs := flag.String("as", "", "Name des Shims bei -install (Standard: Name des Basis-Befehls bzw. alle Befehle)")
+	shimDirFlag := flag.String("shim-dir", "", "Shim-Verzeichnis für -install und -uninstall (Standard: $"+proxy.ShimDirEnv+" oder ~/.local/proxybuild/bin)")
+	uninstall := flag.String("uninstall", "", "Entfernt die mit -install angelegten Shims mit diesem Namen")
+	listInstalled := flag.Bool("list-installed", false, "Zeigt alle mit -install angelegten Shims an")
+	pin := flag.Bool("pin", false, "Legt Pfad und SHA-256 des Basis-Commands auf diesem Rechner fest (base_command_path, base_command_sha256)")
+	genSignKey := flag.String("gen-sign-key", "", "Erzeugt einen ed25519-Schlüssel (PEM) und den öffentlichen Schlüssel (.pub)")
+	buildStubsDir := flag.String("build-stubs", "", "Baut Runner-Stubs für -os/-arch in das angegebene Verzeichnis")
+	secretsEdit := flag.String("secrets-edit", "", "Bearbeitet die verschlüsselten Secrets der angegebenen Konfigurationsdatei im Editor")
+	secretsKeyFile := flag.String("secrets-key-file", "", "Schlüsseldatei für die Secrets (überschreibt key_file)")
+	secretsKeyEnv := flag.String("secrets-key-env", "", "Umgebungsvariable mit dem Schlüssel für die Secrets (überschreibt key_env)")
 	flag.Parse()
 
+	// -build a.json b.json c.json: weitere Konfigurationen für ein Multi-Call-Executable.
+	// Danach dürfen wieder Flags folgen.
+	buildFiles := []string{*buildCmd}
+	for *buildCmd != "" && flag.NArg() > 0 && !strings.HasPrefix(flag.Arg(0), "-") {
+		buildFiles = append(buildFiles, flag.Arg(0))
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
+	secretsKey := SecretsKeyOptions{KeyFile: *secretsKeyFile, KeyEnv: *secretsKeyEnv}
+
+	if *secretsEdit != "" {
+		if err := editSecrets(*secretsEdit, secretsKey); err != nil {
+			exitWithError("Fehler beim Bearbeiten der Secrets", err)
+		}
+		return
+	}
+
+	if *genSignKey != "" {
+		pubPath, err := generateSigningKey(*genSignKey)
+		if err != nil {
+			exitWithError("Fehler beim Erzeugen des Schlüssels", err)
+		}
+		fmt.Printf("✓ Schlüssel erstellt: %s (geheim halten) und %s\n", *genSignKey, pubPath)
+		return
+	}
+
+	if *verify != "" {
+		if err := verifyFile(*verify, *pubkey); err != nil {
+			exitWithError("Verifikation fehlgeschlagen", err)
+		}
+		return
+	}
+
+	if *cacheStatsFlag {
+		if err := printCacheStats(*cacheMaxSize); err != nil {
+			exitWithError("Fehler beim Lesen des Build-Caches", err)
+		}
+		return
+	}
+
+	if *inspect != "" {
+		if err := inspectExecutable(*inspect, *pubkey); err != nil {
+			exitWithError("Fehler beim Untersuchen", err)
+		}
+		return
+	}
+
+	if *install != "" {
+		if err := installShims(os.Stdout, InstallOptions{Binary: *install, As: *installAs, ShimDir: *shimDirFlag}); err != nil {
+			exitWithError("Fehler beim Installieren", err)
+		}
+		return
+	}
+
+	if *uninstall != "" {
+		if err := uninstallShims(os.Stdout, *uninstall, *shimDirFlag); err != nil {
+			exitWithError("Fehler beim Entfernen", err)
+		}
+		return
+	}
+
+	if *listInstalled {
+		if err := listInstalledShims(os.Stdout); err != nil {
+			exitWithError("Fehler beim Lesen der Shim-Registry", err)
+		}
+		return
+	}
+
+	if *buildStubsDir != "" {
+		stubOpts := BuildOptions{GOOS: *goos, GOARCH: *goarch, SignKey: *signKey, TamperPolicy: *tamperPolicy}
+		if err := buildStubs(*buildStubsDir, stubOpts); err != nil {
+			exitWithError("Fehler beim Erstellen der Runner-Stubs", err)
+		}
+		return
+	}
+
 	if *buildCmd != "" {
 		// Build-Modus: Erstelle ein neues ausführbares Programm
 		buildOpts := BuildOptions{
-			ConfigFile: *buildCmd,
-			GOOS:       *goos,
-			GOARCH:     *goarch,
-			OutputName: *outputName,
+			ConfigFile:  *buildCmd,
+			ConfigFiles: buildFiles,
+			GOOS:        *goos,
+			GOARCH:      *goarch,
+			OutputName:  *outputName,
+
+			AllowSecrets: *allowSecrets,
+			SecretsKey:   secretsKey,
+
+			Strategy: *strategy,
+			StubDir:  *stubDir,
+
+			Codegen:      *codegen,
+			TemplateDir:  *templateDir,
+			NoCache:      *noCache,
+			CacheMaxSize: *cacheMaxSize,
+
+			SBOM:               *sbom,
+			VerifyReproducible: *verifyRepro,
+			BuildTimeNow:       *buildTimeNow,
+
+			SignKey:      *signKey,
+			TamperPolicy: *tamperPolicy,
+
+			Publish: *publish,
+
+			Pin: *pin,
+		}
+		if *sbom != "" {
+			if _, err := sbomExtension(*sbom); err != nil {
+				exitWithError("Fehler", err)
+			}
 		}
-		if err := buildExecutable(buildOpts); err != nil {
-			_, err := fmt.Fprintf(os.Stderr, "Fehler beim Erstellen: %v\n", err)
+		if *verifyRepro && *buildTimeNow {
+			exitWithError("Fehler", fmt.Errorf("-verify-reproducible kann nicht mit -build-time-now kombiniert werden, die Build-Zeit unterscheidet sich bei jedem Build"))
+		}
+
+		if *targets != "" {
+			if *goos != "" || *goarch != "" {
+				exitWithError("Fehler", fmt.Errorf("-targets kann nicht mit -os/-arch kombiniert werden"))
+			}
+			if *pin {
+				exitWithError("Fehler", fmt.Errorf("-pin kann nicht mit -targets kombiniert werden, Pfad und Prüfsumme gelten nur für diesen Rechner"))
+			}
+			targetList, err := parseTargets(*targets)
 			if err != nil {
-				return
+				exitWithError("Fehler", err)
 			}
-			os.Exit(1)
+			release := ReleaseOptions{
+				Targets:      targetList,
+				Jobs:         *jobs,
+				OutputDir:    *distDir,
+				Archive:      *archive,
+				NameTemplate: *nameTemplate,
+			}
+			err = withConfigSources(buildOpts, func(opts BuildOptions) error {
+				return buildRelease(opts, release)
+			})
+			if err != nil {
+				exitWithError("Fehler beim Erstellen", err)
+			}
+			fmt.Printf("Release erfolgreich erstellt in %s\n", *distDir)
+			return
+		}
+
+		if err := withConfigSources(buildOpts, buildExecutable); err != nil {
+			exitWithError("Fehler beim Erstellen", err)
 		}
 		fmt.Println("Executable erfolgreich erstellt!")
 		return
@@ -51,19 +256,15 @@ func main() {
 		// Proxy-Modus mit Konfigurationsdatei
 		config, err := loadConfig(*configFile)
 		if err != nil {
-			_, err := fmt.Fprintf(os.Stderr, "Fehler beim Laden der Konfiguration: %v\n", err)
-			if err != nil {
-				return
-			}
-			os.Exit(1)
+			exitWithError("Fehler beim Laden der Konfiguration", err)
+		}
+		// Ohne Bundle liegen die embed_dirs neben der Konfiguration
+		if config.AssetDir, err = filepath.Abs(filepath.Dir(*configFile)); err != nil {
+			exitWithError("Fehler", err)
 		}
 
 		if err := proxy.Run(config, flag.Args()); err != nil {
-			_, err := fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
-			if err != nil {
-				return
-			}
-			os.Exit(1)
+			exitWithError("Fehler", err)
 		}
 		return
 	}
@@ -73,15 +274,60 @@ func main() {
 	fmt.Println("\nVerwendung:")
 	fmt.Println("  ProxyBuild -config <config.json> [args...]  - Führt Proxy mit Konfiguration aus")
 	fmt.Println("  ProxyBuild -build <config.json>             - Erstellt ein neues Executable")
+	fmt.Println("  ProxyBuild -build <a.json> <b.json> ...     - Erstellt ein Multi-Call-Executable für mehrere Befehle")
+	fmt.Println("  ProxyBuild -secrets-edit <config.json>      - Bearbeitet die verschlüsselten Secrets")
+	fmt.Println("  ProxyBuild -build-stubs <dir>               - Baut Runner-Stubs zum Ausliefern")
+	fmt.Println("  ProxyBuild -inspect <proxy>                 - Zeigt Metadaten und Konfiguration eines Proxys")
+	fmt.Println("  ProxyBuild -cache-stats                     - Zeigt den Zustand des Build-Caches")
 	fmt.Println("\nBuild-Optionen:")
 	fmt.Println("  -os <os>       Ziel-Betriebssystem (linux, darwin, windows)")
 	fmt.Println("  -arch <arch>   Ziel-Architektur (amd64, arm64, 386)")
 	fmt.Println("  -output <name> Name des Output-Executables")
+	fmt.Println("  -allow-secrets Mögliche Secrets nur melden statt abzubrechen")
+	fmt.Println("  -strategy <s>  stub (Standard, ohne Kompilieren) oder compile")
+	fmt.Println("  -stub-dir <d>  Verzeichnis mit vorgebauten Runner-Stubs")
+	fmt.Println("  -codegen       Konfiguration als Go-Code kompilieren (ohne JSON-Parsing beim Start)")
+	fmt.Println("  -template <d>  Eigenes Template mit zusätzlichen Go-Dateien verwenden")
+	fmt.Println("  -no-cache      Ohne Build-Cache kompilieren")
+	fmt.Println("  -cache-max-size <mib>  Maximale Größe des Build-Caches (Standard 1024)")
+	fmt.Println("  -sbom <format> SBOM erstellen: cyclonedx oder spdx")
+	fmt.Println("  -verify-reproducible  Zweiten Build erstellen und vergleichen")
+	fmt.Println("  -sign-key <pem> Erstellte Dateien und die eingebettete Konfiguration signieren")
+	fmt.Println("  -tamper-policy  refuse (Standard) oder no-hooks bei manipulierter Konfiguration")
+	fmt.Println("  -publish <dir>  Executable im Update-Feed veröffentlichen (update-Section, -sign-key)")
+	fmt.Println("  -pin           Pfad und SHA-256 des Basis-Commands dieses Rechners festschreiben")
+	fmt.Println("\nSignaturen:")
+	fmt.Println("  ProxyBuild -gen-sign-key key.pem              - Erzeugt key.pem und key.pub")
+	fmt.Println("  ProxyBuild -verify <datei> -pubkey key.pub    - Prüft <datei>.minisig")
+	fmt.Println("\nShims:")
+	fmt.Println("  ProxyBuild -install <proxy> [-as <name>] [-shim-dir <dir>]  - Legt Shims an")
+	fmt.Println("  ProxyBuild -uninstall <name> [-shim-dir <dir>]              - Entfernt Shims")
+	fmt.Println("  ProxyBuild -list-installed                                  - Zeigt installierte Shims")
+	fmt.Println("\nRelease-Optionen:")
+	fmt.Println("  -targets <liste>        Ziele wie linux/amd64,windows/amd64 oder all")
+	fmt.Println("  -jobs <n>               Maximale Anzahl paralleler Builds")
+	fmt.Println("  -dist <dir>             Ausgabeverzeichnis (Standard: dist)")
+	fmt.Println("  -archive <format>       auto, tar.gz oder zip")
+	fmt.Println("  -name-template <tmpl>   Archivname, z.B. {{.Name}}_{{.OS}}_{{.Arch}}")
+	fmt.Println("\nSecrets-Optionen:")
+	fmt.Println("  -secrets-key-file <datei> Schlüsseldatei (überschreibt key_file)")
+	fmt.Println("  -secrets-key-env <name>   Umgebungsvariable mit dem Schlüssel (überschreibt key_env)")
 	fmt.Println("\nBeispiele:")
 	fmt.Println("  ProxyBuild -build config.json")
 	fmt.Println("  ProxyBuild -build config.json -os linux -arch amd64")
 	fmt.Println("  ProxyBuild -build config.json -os windows -arch amd64 -output my-tool.exe")
 	fmt.Println("  ProxyBuild -build config.json -os darwin -arch arm64 -output my-tool-mac")
+	fmt.Println("  ProxyBuild -build config.json -targets linux/amd64,darwin/arm64,windows/amd64")
+	fmt.Println("  ProxyBuild -build git.json kubectl.json terraform.json -output tools")
+	fmt.Println("  ProxyBuild -build git+file:///srv/tooling//configs/docker.json@v1.2")
+	fmt.Println("  ProxyBuild -build https://example.com/tools.tar.gz//docker.json#sha256=<hex>")
+	fmt.Println("  ProxyBuild -install ./compose-proxy --as docker-compose")
+}
+
+// exitWithError gibt den Fehler aus und beendet das Programm
+func exitWithError(msg string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", msg, err)
+	os.Exit(1)
 }
 
 func loadConfig(filename string) (*proxy.Config, error) {
@@ -90,94 +336,375 @@ func loadConfig(filename string) (*proxy.Config, error) {
 		return nil, err
 	}
 
-	var config proxy.Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, err
-	}
-
-	return &config, nil
+	return proxy.LoadConfig(data)
 }
 
 func buildExecutable(opts BuildOptions) error {
-	// Lade Konfiguration
-	config, err := loadConfig(opts.ConfigFile)
+	signer, err := loadSigner(&opts)
 	if err != nil {
-		return fmt.Errorf("Fehler beim Laden der Konfiguration: %w", err)
+		return err
+	}
+
+	input, err := prepareBundle(opts, signer)
+	if err != nil {
+		return err
+	}
+	if err := checkPublish(opts, input, signer); err != nil {
+		return err
 	}
 
 	outputName := opts.OutputName
 	if outputName == "" {
-		outputName = filepath.Base(config.BaseCommand) + "-proxy"
-		// Füge .exe für Windows hinzu
-		if opts.GOOS == "windows" {
-			outputName += ".exe"
-		}
+		outputName = input.defaultOutputName(opts.GOOS)
+	}
+
+	outputPath, err := filepath.Abs(outputName)
+	if err != nil {
+		return err
 	}
 
-	// Erstelle temporäres Build-Verzeichnis
-	buildDir := "tmp_build"
-	if err := os.MkdirAll(buildDir, 0755); err != nil {
+	target := targetOS(opts) + "/" + targetArch(opts)
+	if err := runBuildHooks("before", opts, input, outputPath, target); err != nil {
 		return err
 	}
-	defer func(path string) {
-		err := os.RemoveAll(path)
+
+	// Erst nach Reproduzierbarkeitsprüfung und Smoke-Tests an outputPath
+	// verschieben, damit dort nie ein fehlerhafter Proxy liegt
+	stageDir, err := os.MkdirTemp(filepath.Dir(outputPath), ".proxybuild-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stageDir)
+	stagedPath := filepath.Join(stageDir, filepath.Base(outputPath))
+
+	bundle := input.BundleFor(opts)
+	if err := writeExecutable(opts, bundle, stagedPath); err != nil {
+		return err
+	}
+
+	if opts.VerifyReproducible {
+		// Auch das Bundle neu erzeugen, damit Abweichungen in Konfiguration
+		// und Metadaten einschließlich der Build-Zeit auffallen
+		again, err := prepareBundle(opts, signer)
 		if err != nil {
-			_, err := fmt.Fprintf(os.Stderr, "Fehler beim Aufräumen des Build-Verzeichnisses: %v\n", err)
-			if err != nil {
-				return
-			}
+			return err
 		}
-	}(buildDir)
+		if err := verifyReproducible(opts, again.BundleFor(opts), stagedPath); err != nil {
+			return err
+		}
+	}
+	if err := runSmokeTests(opts, input, stagedPath); err != nil {
+		return err
+	}
+	if err := os.Rename(stagedPath, outputPath); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Executable erstellt: %s\n", outputName)
+
+	created := []string{outputPath}
+	if opts.SBOM != "" {
+		sbomPath, err := writeSBOM(opts.SBOM, outputPath, outputPath, input, opts)
+		if err != nil {
+			return fmt.Errorf("SBOM: %w", err)
+		}
+		fmt.Printf("✓ SBOM erstellt: %s\n", sbomPath)
+		created = append(created, sbomPath)
+	}
+
+	if signer != nil {
+		if err := signFiles(signer, created...); err != nil {
+			return err
+		}
+	}
+	if opts.Publish != "" {
+		if err := publishUpdate(opts, input, map[string]string{target: outputPath}); err != nil {
+			return fmt.Errorf("Veröffentlichen: %w", err)
+		}
+	}
+	return runBuildHooks("after", opts, input, outputPath, target)
+}
+
+// defaultOutputName leitet den Namen des Executables aus dem Basis-Befehl ab
+func defaultOutputName(config *proxy.Config, goos string) string {
+	name := filepath.Base(config.BaseCommand) + "-proxy"
+	// Füge .exe für Windows hinzu
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// buildInput ist das plattformunabhängige Ergebnis von prepareBundle
+type buildInput struct {
+	Config     *proxy.Config       // Bei einem Multi-Call-Executable die erste Konfiguration
+	ConfigData []byte              // Serialisierte Konfiguration, wie sie eingebettet wird
+	Commands   []multiCallCommand  // Alle Konfigurationen eines Multi-Call-Executables, sonst leer
+	Assets     []byte              // Archiv der embed_dirs (proxy.EncodeAssets)
+	Metadata   proxy.BuildMetadata // Ohne Ziel, das setzt BundleFor
+
+	signer ed25519.PrivateKey
+}
+
+// multiCallCommand ist eine Konfiguration in einem Multi-Call-Executable
+type multiCallCommand struct {
+	Name       string // Aufrufname, aus base_command abgeleitet
+	ConfigFile string
+	Config     *proxy.Config
+	ConfigData []byte
+}
+
+// configSections liefert die Abschnitte mit der Konfiguration: "config" oder
+// bei einem Multi-Call-Executable "config/<name>" je Befehl
+func (b *buildInput) configSections() map[string][]byte {
+	if len(b.Commands) == 0 {
+		return map[string][]byte{"config": b.ConfigData}
+	}
+	sections := make(map[string][]byte, len(b.Commands))
+	for _, cmd := range b.Commands {
+		sections[proxy.MultiCallPrefix+cmd.Name] = cmd.ConfigData
+	}
+	return sections
+}
+
+// BundleFor erzeugt das Bundle für das Ziel aus opts: Konfiguration, Metadaten
+// und, mit -sign-key, die Signatur über beides
+func (b *buildInput) BundleFor(opts BuildOptions) []byte {
+	meta := b.Metadata
+	meta.Target = targetOS(opts) + "/" + targetArch(opts)
+	sections := b.configSections()
+	sections[proxy.MetadataSection] = []byte(proxy.EncodeMetadata(&meta))
+	if len(b.Assets) > 0 {
+		sections[proxy.AssetsSection] = b.Assets
+	}
+	if b.signer != nil {
+		// Der Runner prüft die Signatur mit dem eingebauten öffentlichen Schlüssel
+		proxy.SignBundle(sections, b.signer)
+	}
+	return proxy.EncodeBundle(sections)
+}
+
+// ConfigSHA256 liefert die Prüfsumme der eingebetteten Konfiguration
+func (b *buildInput) ConfigSHA256() string {
+	return proxy.BundleConfigSHA256(b.configSections())
+}
+
+// defaultOutputName liefert den Namen des Executables ohne -output
+func (b *buildInput) defaultOutputName(goos string) string {
+	if len(b.Commands) > 0 {
+		name := "multi-proxy"
+		if goos == "windows" {
+			name += ".exe"
+		}
+		return name
+	}
+	return defaultOutputName(b.Config, goos)
+}
+
+// prepareBundle lädt und prüft die Konfiguration und sammelt die Build-Metadaten.
+// Es ist unabhängig von der Zielplattform und wird bei mehreren Zielen nur einmal ausgeführt.
+func prepareBundle(opts BuildOptions, signer ed25519.PrivateKey) (*buildInput, error) {
+	input := &buildInput{signer: signer}
+	if len(opts.ConfigFiles) > 1 {
+		commands, err := loadMultiCallCommands(opts)
+		if err != nil {
+			return nil, err
+		}
+		input.Config = commands[0].Config
+		input.Commands = commands
+	} else {
+		config, configData, err := loadBuildConfig(opts, opts.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		input.Config = config
+		input.ConfigData = configData
+	}
 
-	// Kopiere die Konfigurationsdatei ins Build-Verzeichnis
-	configData, err := json.MarshalIndent(config, "", "  ")
+	assets, err := collectAssets(input.configs(opts))
 	if err != nil {
-		return err
+		return nil, err
 	}
+	input.Assets = assets
 
-	// Resolve applicable build env vars to config, before embedding config in build step
-	envVars := os.Environ()
-	if len(envVars) >= 1 {
-		for _, envVar := range envVars {
-			key := strings.Split(envVar, "=")[0]
-			val := strings.Split(envVar, "=")[1]
-			// Windows Env Syntax: %key%
-			windowsSyntax := fmt.Sprintf("%%%s%%", key)
+	// Eigene Templates vor dem ersten Kompilieren prüfen
+	if opts.TemplateDir != "" {
+		if _, err := runnerTemplateFiles(opts, nil, input.defaultOutputName(targetOS(opts))); err != nil {
+			return nil, err
+		}
+	}
 
-			// Unix Env Syntax: $key
-			unixSyntax := fmt.Sprintf("$%s", key)
+	outputName := opts.OutputName
+	if outputName == "" {
+		outputName = input.defaultOutputName("")
+	}
+	input.Metadata = proxy.BuildMetadata{
+		ProxyBuildVersion: proxyBuildVersion(),
+		ConfigSHA256:      input.ConfigSHA256(),
+		BuildTime:         buildTime(opts).Format(time.RFC3339),
+		GitCommit:         configGitCommit(opts.ConfigFile),
+		Name:              strings.TrimSuffix(filepath.Base(outputName), ".exe"),
+	}
+	return input, nil
+}
+
+// loadBuildConfig lädt eine Konfigurationsdatei, prüft ihre Secrets und setzt
+// die Build-Umgebung ein. Zurück kommt auch das JSON, das eingebettet wird.
+func loadBuildConfig(opts BuildOptions, configFile string) (*proxy.Config, []byte, error) {
+	// Lade Konfiguration
+	config, err := loadConfig(configFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Fehler beim Laden der Konfiguration: %w", err)
+	}
+
+	if err := checkSecrets(config, opts.SecretsKey); err != nil {
+		return nil, nil, err
+	}
+
+	// Resolve applicable build env vars to config, before embedding config in build step
+	configData, subs, err := resolveBuildEnv(config, os.Environ())
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.Pin {
+		if configData, err = pinBaseCommand(opts, config, configData); err != nil {
+			return nil, nil, err
+		}
+	}
 
-			configData = bytes.Replace(configData, []byte(unixSyntax), []byte(val), -1)
-			configData = bytes.Replace(configData, []byte(windowsSyntax), []byte(val), -1)
+	// Prüfe ersetzte Werte auf Credentials, bevor sie im Executable landen
+	if findings := scanSubstitutions(subs); len(findings) > 0 {
+		var lines []string
+		for _, finding := range findings {
+			lines = append(lines, "  "+finding.String())
 		}
+		report := strings.Join(lines, "\n")
+		if !opts.AllowSecrets {
+			return nil, nil, fmt.Errorf("mögliche Secrets würden in das Executable eingebettet:\n%s\nSchränke die Ersetzung mit build_env_allowlist ein oder nutze -allow-secrets", report)
+		}
+		fmt.Fprintf(os.Stderr, "Warnung: mögliche Secrets werden in das Executable eingebettet:\n%s\n", report)
+	}
+	return config, configData, nil
+}
+
+// buildStrategy liefert die Strategie, mit der für opts.GOOS gebaut wird
+func buildStrategy(opts BuildOptions) string {
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = StrategyStub
 	}
+	// Generierter Code und eigene Templates müssen kompiliert werden
+	if opts.Codegen || opts.TemplateDir != "" {
+		return StrategyCompile
+	}
+	// Mach-O-Binaries sind signiert, angehängte Daten würden die Signatur brechen
+	if strategy == StrategyStub && targetOS(opts) == "darwin" {
+		return StrategyCompile
+	}
+	return strategy
+}
 
-	if err := os.WriteFile(filepath.Join(buildDir, "config.json"), configData, 0644); err != nil {
+// writeExecutable erstellt den Runner für opts.GOOS/opts.GOARCH mit der gewählten Strategie
+func writeExecutable(opts BuildOptions, bundle []byte, outputPath string) error {
+	opts, err := withTrustedBaseCommands(opts, bundle)
+	if err != nil {
 		return err
 	}
+	strategy := buildStrategy(opts)
+	if strategy == StrategyCompile && opts.Strategy != StrategyCompile && !opts.Codegen && opts.TemplateDir == "" {
+		fmt.Println("Hinweis: für darwin wird kompiliert, da angehängte Daten die Code-Signatur brechen")
+	}
 
-	// Kopiere das Template main.go
-	templatePath := "template/main.go"
-	if err := copyFile(templatePath, filepath.Join(buildDir, "main.go")); err != nil {
-		return fmt.Errorf("Fehler beim Kopieren des Templates: %w", err)
+	switch strategy {
+	case StrategyStub:
+		return buildFromStub(opts, bundle, outputPath)
+	case StrategyCompile:
+		return compileCached(opts, bundle, outputPath)
+	default:
+		return fmt.Errorf("unbekannte Build-Strategie %q (stub oder compile)", strategy)
 	}
+}
 
-	// Erstelle go.mod im Build-Verzeichnis
-	goModContent := fmt.Sprintf(`module generated-proxy
+// runnerBuildFlags machen Runner reproduzierbar: keine lokalen Pfade, keine VCS-Daten
+// und eine feste Build-ID
+var runnerBuildFlags = []string{"-trimpath", "-buildvcs=false", "-ldflags=" + runnerLDFlags}
 
-go 1.24
+const runnerLDFlags = "-buildid="
 
-require ProxyBuild/proxy v0.0.0
+// runnerBuildArgs ergänzt die Build-Flags um den öffentlichen Schlüssel für die
+// Signaturprüfung der Konfiguration und die Build-Metadaten aus dem Bundle
+func runnerBuildArgs(opts BuildOptions, bundle []byte) ([]string, error) {
+	ldflags := runnerLDFlags
+	if opts.configPublicKey != "" {
+		ldflags += fmt.Sprintf(" -X main.configPublicKey=%s -X main.configPolicy=%s", opts.configPublicKey, opts.TamperPolicy)
+	}
+	if opts.trustedBaseCommands != "" {
+		ldflags += " -X main.trustedBaseCommands=" + opts.trustedBaseCommands
+	}
+	if len(bundle) > 0 {
+		sections, err := proxy.DecodeBundle(bundle)
+		if err != nil {
+			return nil, err
+		}
+		if meta, ok := sections[proxy.MetadataSection]; ok {
+			ldflags += " -X main.buildMetadata=" + string(meta)
+		}
+	}
+	return []string{"-trimpath", "-buildvcs=false", "-ldflags=" + ldflags}, nil
+}
+
+// compileRunner kompiliert das Template mit dem eingebetteten Bundle. Ein leeres
+// Bundle ergibt einen Runner-Stub, der sein Bundle am Ende des Executables sucht.
+func compileRunner(opts BuildOptions, bundle []byte, outputPath string) error {
+	// Erstelle ein eigenes temporäres Build-Verzeichnis, damit parallele Builds sich nicht stören
+	buildDir, err := os.MkdirTemp("", "proxybuild-*")
+	if err != nil {
+		return err
+	}
+	defer func(path string) {
+		if err := os.RemoveAll(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Fehler beim Aufräumen des Build-Verzeichnisses: %v\n", err)
+		}
+	}(buildDir)
 
-replace ProxyBuild/proxy => %s
-`, filepath.Join("..", "proxy"))
+	if err := os.WriteFile(filepath.Join(buildDir, "bundle.bin"), bundle, 0644); err != nil {
+		return err
+	}
 
-	if err := os.WriteFile(filepath.Join(buildDir, "go.mod"), []byte(goModContent), 0644); err != nil {
+	// Schreibe runner.go und das Template (main.go oder -template)
+	if err := os.WriteFile(filepath.Join(buildDir, "runner.go"), runnerSource, 0644); err != nil {
+		return fmt.Errorf("Fehler beim Schreiben des Templates: %w", err)
+	}
+	files, err := runnerTemplateFiles(opts, bundle, outputPath)
+	if err != nil {
 		return err
 	}
+	for rel, data := range files {
+		path := filepath.Join(buildDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("Fehler beim Schreiben des Templates: %w", err)
+		}
+	}
+
+	if opts.Codegen && len(bundle) > 0 {
+		if err := writeGeneratedConfig(opts, bundle, filepath.Join(buildDir, "config_gen.go")); err != nil {
+			return err
+		}
+	}
 
-	// Kompiliere das Programm
-	fmt.Printf("Kompiliere %s...\n", outputName)
+	// Schreibe die eingebetteten Quelltexte des proxy-Pakets
+	if err := writeProxySources(buildDir); err != nil {
+		return fmt.Errorf("Fehler beim Schreiben des proxy-Pakets: %w", err)
+	}
+
+	// Erstelle go.mod im Build-Verzeichnis, falls das Template keine mitbringt
+	if _, ok := files["go.mod"]; !ok {
+		if err := os.WriteFile(filepath.Join(buildDir, "go.mod"), []byte(defaultGoMod), 0644); err != nil {
+			return err
+		}
+	}
 
 	// Zeige Cross-Compilation Info
 	if opts.GOOS != "" || opts.GOARCH != "" {
@@ -192,32 +719,57 @@ replace ProxyBuild/proxy => %s
 		fmt.Printf("Cross-Compiling für OS=%s, ARCH=%s\n", targetOS, targetArch)
 	}
 
-	buildCmd := exec.Command("go", "build", "-o", filepath.Join("..", outputName), ".")
+	buildArgs, err := runnerBuildArgs(opts, bundle)
+	if err != nil {
+		return err
+	}
+	args := append(append([]string{"build"}, buildArgs...), "-o", outputPath, ".")
+	buildCmd := exec.Command("go", args...)
 	buildCmd.Dir = buildDir
 	buildCmd.Stdout = os.Stdout
 	buildCmd.Stderr = os.Stderr
 
-	// Setze Cross-Compilation-Flags, falls angegeben
-	buildCmd.Env = os.Environ()
-	if opts.GOOS != "" {
-		buildCmd.Env = append(buildCmd.Env, "GOOS="+opts.GOOS)
-	}
-	if opts.GOARCH != "" {
-		buildCmd.Env = append(buildCmd.Env, "GOARCH="+opts.GOARCH)
-	}
+	buildCmd.Env = runnerBuildEnv(opts)
 
 	if err := buildCmd.Run(); err != nil {
 		return fmt.Errorf("Kompilierung fehlgeschlagen: %w", err)
 	}
-
-	fmt.Printf("✓ Executable erstellt: %s\n", outputName)
 	return nil
 }
 
-func copyFile(src, dst string) error {
-	data, err := os.ReadFile(src)
+// runnerBuildEnv liefert die Umgebung für go build mit den Cross-Compilation-
+// Variablen, falls angegeben. Ein go.work des Aufrufers darf das
+// Build-Verzeichnis nicht beeinflussen.
+func runnerBuildEnv(opts BuildOptions) []string {
+	env := append(os.Environ(), "GOWORK=off")
+	if opts.GOOS != "" {
+		env = append(env, "GOOS="+opts.GOOS)
+	}
+	if opts.GOARCH != "" {
+		env = append(env, "GOARCH="+opts.GOARCH)
+	}
+	return env
+}
+
+// writeProxySources schreibt die eingebetteten Quelltexte des proxy-Pakets und
+// seine go.mod nach dir/proxy
+func writeProxySources(dir string) error {
+	err := fs.WalkDir(proxySources, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(path))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := proxySources.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(dst, data, 0644)
+	return os.WriteFile(filepath.Join(dir, "proxy", "go.mod"), []byte(proxyGoMod), 0644)
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"ProxyBuild/proxy"
+)
+
+// Mit mehreren Konfigurationen (-build a.json b.json) entsteht ein
+// Multi-Call-Executable. Jede Konfiguration bekommt den Namen ihres
+// Basis-Befehls, der Runner wählt sie über den Aufrufnamen oder das erste
+// Argument aus, siehe proxy.SelectCommand.
+
+// loadMultiCallCommands lädt alle Konfigurationen aus opts.ConfigFiles. Zwei
+// Konfigurationen mit demselben Befehlsnamen sind ein Fehler.
+func loadMultiCallCommands(opts BuildOptions) ([]multiCallCommand, error) {
+	var commands []multiCallCommand
+	files := make(map[string]string)
+	for _, file := range opts.ConfigFiles {
+		config, configData, err := loadBuildConfig(opts, file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if config.Package != nil {
+			return nil, fmt.Errorf("%s: package wird in Multi-Call-Executables nicht unterstützt", file)
+		}
+		name := multiCallName(config)
+		if name == "" || strings.HasPrefix(name, "-") {
+			return nil, fmt.Errorf("%s: aus base_command %q lässt sich kein Befehlsname ableiten", file, config.BaseCommand)
+		}
+		if other, ok := files[name]; ok {
+			return nil, fmt.Errorf("Namenskonflikt: %s und %s ergeben beide den Befehl %q", other, file, name)
+		}
+		files[name] = file
+		commands = append(commands, multiCallCommand{Name: name, ConfigFile: file, Config: config, ConfigData: configData})
+	}
+	if err := checkMultiCallUpdate(commands); err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
+
+// multiCallName leitet den Befehlsnamen aus dem ersten Wort von base_command ab,
+// z.B. "git" aus "/usr/bin/git" oder "kubectl" aus "C:\tools\kubectl.exe"
+func multiCallName(config *proxy.Config) string {
+	fields := strings.Fields(config.BaseCommand)
+	if len(fields) == 0 {
+		return ""
+	}
+	name := fields[0]
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, ".exe")
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"ProxyBuild/proxy"
+)
+
+// ReleasePackage beschreibt ein erzeugtes Paket im Manifest
+type ReleasePackage struct {
+	Format string `json:"format"`
+	Target string `json:"target,omitempty"`
+	File   string `json:"file,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// packageInput enthält alles, was für ein deb- oder rpm-Paket eines Ziels nötig ist
+type packageInput struct {
+	Name        string
+	Version     string
+	Release     string
+	Arch        string // GOARCH
+	Maintainer  string
+	Description string
+	Homepage    string
+	License     string
+	Vendor      string
+
+	BinaryName string
+	Binary     []byte
+	BinDir     string
+	ShimDir    string
+	Symlinks   []string // absolute Pfade
+	ModTime    time.Time
+}
+
+// BinaryPath liefert den Installationspfad des Executables
+func (p packageInput) BinaryPath() string {
+	return path.Join(p.BinDir, p.BinaryName)
+}
+
+// ProfileScript liefert Pfad und Inhalt des Skripts in /etc/profile.d, das
+// shim_dir im PATH vor die ursprünglichen Befehle stellt. Ohne Symlinks in
+// shim_dir ist der Pfad leer.
+func (p packageInput) ProfileScript() (string, []byte) {
+	used := false
+	for _, link := range p.Symlinks {
+		used = used || path.Dir(link) == p.ShimDir
+	}
+	if !used {
+		return "", nil
+	}
+	script := fmt.Sprintf(`# Von %[1]s installiert: die Links in %[2]s überdecken die ursprünglichen Befehle
+case ":$PATH:" in
+  *:%[2]s:*) ;;
+  *) PATH="%[2]s:$PATH"; export PATH ;;
+esac
+`, p.Name, p.ShimDir)
+	return "/etc/profile.d/" + p.Name + ".sh", []byte(script)
+}
+
+// Summary liefert die erste Zeile der Beschreibung
+func (p packageInput) Summary() string {
+	summary, _, _ := strings.Cut(strings.TrimSpace(p.Description), "\n")
+	if summary == "" {
+		summary = p.Name + " (erstellt mit ProxyBuild)"
+	}
+	return summary
+}
+
+// urlData sind die Felder für package.url_template
+type urlData struct {
+	Name    string
+	Version string
+	Archive string
+	OS      string
+	Arch    string
+}
+
+// buildPackages erzeugt die Pakete aus der package-Sektion für die gebauten Ziele.
+// Fehler werden je Paket vermerkt, damit ein Format die übrigen nicht verhindert.
+func buildPackages(config *proxy.PackageConfig, name string, artifacts []ReleaseArtifact, workDir, outputDir string, modTime time.Time) []ReleasePackage {
+	base := packageInput{
+		Name:        config.Name,
+		Version:     config.Version,
+		Release:     config.Release,
+		Maintainer:  config.Maintainer,
+		Description: config.Description,
+		Homepage:    config.Homepage,
+		License:     config.License,
+		Vendor:      config.Vendor,
+		BinaryName:  name,
+		BinDir:      config.BinDir,
+		ModTime:     modTime,
+	}
+	if base.Name == "" {
+		base.Name = name
+	}
+	if base.Release == "" {
+		base.Release = "1"
+	}
+	if base.BinDir == "" {
+		base.BinDir = "/usr/bin"
+	}
+	base.ShimDir = "/usr/lib/" + base.Name + "/bin"
+	if config.ShimDir != "" {
+		base.ShimDir = path.Clean(config.ShimDir)
+	}
+	// Homebrew und Scoop verwenden nur die Namen der Symlinks
+	base.Symlinks = config.SymlinkPaths(base.ShimDir)
+
+	var packages []ReleasePackage
+	for _, format := range config.PackageFormats() {
+		switch format {
+		case proxy.PackageDeb, proxy.PackageRPM:
+			for _, artifact := range artifacts {
+				if artifact.Error != "" || artifact.OS != "linux" {
+					continue
+				}
+				pkg := ReleasePackage{Format: format, Target: artifact.Target}
+				if err := writeLinuxPackage(&pkg, base, artifact, workDir, outputDir); err != nil {
+					pkg.Error = err.Error()
+				}
+				packages = append(packages, pkg)
+			}
+		case proxy.PackageBrew, proxy.PackageScoop:
+			pkg := ReleasePackage{Format: format}
+			var err error
+			if format == proxy.PackageBrew {
+				pkg.File, err = writeManifestFile(outputDir, base.Name+".rb", func(w io.Writer) error {
+					return writeBrewFormula(w, config, base, artifacts)
+				})
+			} else {
+				pkg.File, err = writeManifestFile(outputDir, base.Name+".json", func(w io.Writer) error {
+					return writeScoopManifest(w, config, base, artifacts)
+				})
+			}
+			if errors.Is(err, errNoPackageTargets) {
+				fmt.Printf("Hinweis: kein passendes Ziel für %s, wird übersprungen\n", format)
+				continue
+			}
+			if err == nil {
+				err = checksumPackage(&pkg, outputDir)
+			}
+			if err != nil {
+				pkg.Error = err.Error()
+			}
+			packages = append(packages, pkg)
+		}
+	}
+	return packages
+}
+
+// writeLinuxPackage erzeugt das deb- oder rpm-Paket für ein Linux-Ziel
+func writeLinuxPackage(pkg *ReleasePackage, base packageInput, artifact ReleaseArtifact, workDir, outputDir string) error {
+	binary, err := os.ReadFile(filepath.Join(workDir, artifact.OS+"_"+artifact.Arch, artifact.Binary))
+	if err != nil {
+		return err
+	}
+	input := base
+	input.Arch = artifact.Arch
+	input.Binary = binary
+
+	var buf bytes.Buffer
+	if pkg.Format == proxy.PackageDeb {
+		if err := writeDeb(&buf, input); err != nil {
+			return err
+		}
+		pkg.File = fmt.Sprintf("%s_%s-%s_%s.deb", input.Name, input.Version, input.Release, debArch[input.Arch])
+	} else {
+		if err := writeRPM(&buf, input); err != nil {
+			return err
+		}
+		pkg.File = fmt.Sprintf("%s-%s-%s.%s.rpm", input.Name, input.Version, input.Release, rpmArch[input.Arch])
+	}
+
+	if err := os.WriteFile(filepath.Join(outputDir, pkg.File), buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return checksumPackage(pkg, outputDir)
+}
+
+func checksumPackage(pkg *ReleasePackage, outputDir string) error {
+	info, err := os.Stat(filepath.Join(outputDir, pkg.File))
+	if err != nil {
+		return err
+	}
+	pkg.Size = info.Size()
+	pkg.SHA256, err = fileSHA256(filepath.Join(outputDir, pkg.File))
+	return err
+}
+
+// errNoPackageTargets meldet, dass kein Ziel zum Paketformat passt
+var errNoPackageTargets = errors.New("kein passendes Ziel")
+
+// writeManifestFile schreibt eine Formel oder ein Manifest nur, wenn write erfolgreich war
+func writeManifestFile(outputDir, name string, write func(io.Writer) error) (string, error) {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return "", err
+	}
+	return name, os.WriteFile(filepath.Join(outputDir, name), buf.Bytes(), 0644)
+}
+
+// packageURL setzt die Felder eines Archivs in package.url_template ein
+func packageURL(config *proxy.PackageConfig, base packageInput, artifact ReleaseArtifact) (string, error) {
+	tmpl, err := template.New("url").Option("missingkey=error").Parse(config.URLTemplate)
+	if err != nil {
+		return "", fmt.Errorf("url_template: %w", err)
+	}
+	var buf bytes.Buffer
+	data := urlData{Name: base.Name, Version: base.Version, Archive: artifact.Archive, OS: artifact.OS, Arch: artifact.Arch}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("url_template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// findArtifact liefert das erfolgreich gebaute Artefakt für os/arch
+func findArtifact(artifacts []ReleaseArtifact, goos, goarch string) (ReleaseArtifact, bool) {
+	for _, artifact := range artifacts {
+		if artifact.OS == goos && artifact.Arch == goarch && artifact.Error == "" {
+			return artifact, true
+		}
+	}
+	return ReleaseArtifact{}, false
+}
+
+// writeBrewFormula erzeugt eine Homebrew-Formel für die macOS- und Linux-Archive
+func writeBrewFormula(w io.Writer, config *proxy.PackageConfig, base packageInput, artifacts []ReleaseArtifact) error {
+	var blocks strings.Builder
+	for _, platform := range []struct{ goos, block string }{{"darwin", "on_macos"}, {"linux", "on_linux"}} {
+		var body strings.Builder
+		for _, cpu := range []struct{ goarch, check string }{{"arm64", "arm?"}, {"amd64", "intel?"}} {
+			artifact, ok := findArtifact(artifacts, platform.goos, cpu.goarch)
+			if !ok {
+				continue
+			}
+			url, err := packageURL(config, base, artifact)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&body, "    if Hardware::CPU.%s\n      url %s\n      sha256 %s\n    end\n", cpu.check, rubyString(url), rubyString(artifact.SHA256))
+		}
+		if body.Len() > 0 {
+			fmt.Fprintf(&blocks, "\n  %s do\n%s  end\n", platform.block, body.String())
+		}
+	}
+	if blocks.Len() == 0 {
+		return errNoPackageTargets
+	}
+
+	fmt.Fprintf(w, "class %s < Formula\n", brewClassName(base.Name))
+	fmt.Fprintf(w, "  desc %s\n", rubyString(base.Summary()))
+	if base.Homepage != "" {
+		fmt.Fprintf(w, "  homepage %s\n", rubyString(base.Homepage))
+	}
+	fmt.Fprintf(w, "  version %s\n", rubyString(base.Version))
+	if base.License != "" {
+		fmt.Fprintf(w, "  license %s\n", rubyString(base.License))
+	}
+	io.WriteString(w, blocks.String())
+	fmt.Fprintf(w, "\n  def install\n    bin.install %s\n", rubyString(base.BinaryName))
+	for _, link := range base.Symlinks {
+		fmt.Fprintf(w, "    bin.install_symlink %s => %s\n", rubyString(base.BinaryName), rubyString(path.Base(link)))
+	}
+	io.WriteString(w, "  end\n")
+	fmt.Fprintf(w, "\n  test do\n    assert_predicate bin/%s, :executable?\n  end\nend\n", rubyString(base.BinaryName))
+	return nil
+}
+
+// brewClassName bildet den Klassennamen der Formel, z.B. docker-compose-proxy → DockerComposeProxy
+func brewClassName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// rubyString quotiert s als Ruby-String ohne Interpolation
+func rubyString(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `#`, `\#`, "\n", `\n`)
+	return `"` + replacer.Replace(s) + `"`
+}
+
+// scoopManifest ist das App-Manifest für Scoop
+type scoopManifest struct {
+	Version      string                       `json:"version"`
+	Description  string                       `json:"description,omitempty"`
+	Homepage     string                       `json:"homepage,omitempty"`
+	License      string                       `json:"license,omitempty"`
+	Architecture map[string]scoopArchitecture `json:"architecture"`
+	Bin          []any                        `json:"bin"`
+}
+
+type scoopArchitecture struct {
+	URL  string `json:"url"`
+	Hash string `json:"hash"`
+}
+
+// writeScoopManifest erzeugt ein Scoop-Manifest für die Windows-Archive
+func writeScoopManifest(w io.Writer, config *proxy.PackageConfig, base packageInput, artifacts []ReleaseArtifact) error {
+	manifest := scoopManifest{
+		Version:      base.Version,
+		Description:  base.Summary(),
+		Homepage:     base.Homepage,
+		License:      base.License,
+		Architecture: make(map[string]scoopArchitecture),
+	}
+	for _, arch := range []struct{ goarch, scoop string }{{"amd64", "64bit"}, {"386", "32bit"}, {"arm64", "arm64"}} {
+		artifact, ok := findArtifact(artifacts, "windows", arch.goarch)
+		if !ok {
+			continue
+		}
+		url, err := packageURL(config, base, artifact)
+		if err != nil {
+			return err
+		}
+		manifest.Architecture[arch.scoop] = scoopArchitecture{URL: url, Hash: artifact.SHA256}
+	}
+	if len(manifest.Architecture) == 0 {
+		return errNoPackageTargets
+	}
+
+	// Symlinks werden zu Scoop-Shims mit eigenem Namen
+	exe := base.BinaryName + ".exe"
+	manifest.Bin = []any{exe}
+	for _, link := range base.Symlinks {
+		manifest.Bin = append(manifest.Bin, []string{exe, path.Base(link)})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+
+	"ProxyBuild/proxy"
+)
+
+// pinBaseCommand schreibt mit -pin Pfad und SHA-256 des Basis-Commands, wie
+// der Proxy es auf diesem Rechner finden würde, in die eingebettete
+// Konfiguration (configData, nach dem Einsetzen der Build-Umgebung)
+func pinBaseCommand(opts BuildOptions, config *proxy.Config, configData []byte) ([]byte, error) {
+	if goos := targetOS(opts); goos != runtime.GOOS {
+		return nil, fmt.Errorf("-pin geht nur beim Build für dieses Betriebssystem (%s), nicht für %s", runtime.GOOS, goos)
+	}
+	var resolved proxy.Config
+	if err := json.Unmarshal(configData, &resolved); err != nil {
+		return nil, err
+	}
+	if err := proxy.PinBaseCommand(&resolved); err != nil {
+		return nil, fmt.Errorf("-pin: %w", err)
+	}
+	if err := resolved.Validate(); err != nil {
+		return nil, fmt.Errorf("-pin: %w", err)
+	}
+	config.BaseCommandPath = resolved.BaseCommandPath
+	config.BaseCommandSHA256 = resolved.BaseCommandSHA256
+	fmt.Printf("✓ base_command gepinnt: %s (sha256:%s)\n", resolved.BaseCommandPath, resolved.BaseCommandSHA256)
+	return json.MarshalIndent(resolved, "", "  ")
+}
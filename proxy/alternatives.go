@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// base_command darf eine Liste von Alternativen sein, z.B.
+// ["docker-compose", "docker compose", "podman-compose"]. Der Proxy nimmt die
+// erste, deren Befehl im PATH liegt und, mit base_command_probe, deren Probe
+// erfolgreich ist. Die Wahl wird pro Rechner im Cache gespeichert.
+
+// probeTimeout begrenzt einen Aufruf von base_command_probe
+const probeTimeout = 10 * time.Second
+
+// MarshalJSON schreibt base_command als Liste, wenn es Alternativen gibt
+func (c Config) MarshalJSON() ([]byte, error) {
+	type plain Config
+	if len(c.BaseCommandAlternatives) == 0 {
+		return json.Marshal(plain(c))
+	}
+	return json.Marshal(struct {
+		BaseCommand []string `json:"base_command"`
+		plain
+	}{c.BaseCommandAlternatives, plain(c)})
+}
+
+// UnmarshalJSON liest base_command als String oder als Liste von Alternativen.
+// BaseCommand ist dann die erste Alternative.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type plain Config
+	raw := struct {
+		BaseCommand json.RawMessage `json:"base_command"`
+		*plain
+	}{plain: (*plain)(c)}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.BaseCommand, c.BaseCommandAlternatives = "", nil
+	if len(raw.BaseCommand) == 0 || string(raw.BaseCommand) == "null" {
+		return nil
+	}
+	if raw.BaseCommand[0] != '[' {
+		return json.Unmarshal(raw.BaseCommand, &c.BaseCommand)
+	}
+	var alternatives []string
+	if err := json.Unmarshal(raw.BaseCommand, &alternatives); err != nil {
+		return fmt.Errorf("base_command: %w", err)
+	}
+	if len(alternatives) > 0 {
+		c.BaseCommand = alternatives[0]
+	}
+	if len(alternatives) > 1 {
+		c.BaseCommandAlternatives = alternatives
+	}
+	return nil
+}
+
+// validateAlternatives prüft die Alternativen von base_command
+func (c *Config) validateAlternatives() error {
+	var errs []error
+	for i, alternative := range c.BaseCommandAlternatives {
+		if strings.TrimSpace(alternative) == "" {
+			errs = append(errs, fmt.Errorf("base_command[%d] ist leer", i))
+		} else if c.Executor == ExecutorDirect && strings.ContainsAny(strings.TrimSpace(alternative), " \t") {
+			// direct startet den ganzen Eintrag als Programm, "docker compose" würde nie gefunden
+			errs = append(errs, fmt.Errorf("base_command[%d] %q: mit executor direct muss jede Alternative ein einzelnes Programm ohne Argumente sein, für Sub-Commands executor shell verwenden", i, alternative))
+		}
+	}
+	if len(c.BaseCommandAlternatives) > 0 && (c.BaseCommandPath != "" || c.BaseCommandSHA256 != "") {
+		errs = append(errs, errors.New("base_command_path und base_command_sha256 gehen nur mit einem einzelnen base_command"))
+	}
+	return errors.Join(errs...)
+}
+
+// selectBaseCommand liefert das expandierte Basis-Command. Mit Alternativen oder
+// Probe wird das erste verfügbare gewählt, siehe detectBaseCommand.
+func selectBaseCommand(config *Config, ctx *TemplateContext) (string, error) {
+	if len(config.BaseCommandAlternatives) == 0 && len(config.BaseCommandProbe) == 0 {
+		return renderTemplate(config.BaseCommand, ctx, config.Executor)
+	}
+	candidates := config.BaseCommandAlternatives
+	if len(candidates) == 0 {
+		candidates = []string{config.BaseCommand}
+	}
+	rendered := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		var err error
+		if rendered[i], err = renderTemplate(candidate, ctx, config.Executor); err != nil {
+			return "", fmt.Errorf("base_command[%d]: %w", i, err)
+		}
+	}
+	return detectBaseCommand(rendered, config.BaseCommandProbe, config.Executor)
+}
+
+// baseCommandChoice ist der Cache-Eintrag für eine Auswahl
+type baseCommandChoice struct {
+	Command  string    `json:"command"`
+	Path     string    `json:"path"` // Gefundene Datei des Befehls, ändert sie sich, wird neu gewählt
+	Detected time.Time `json:"detected"`
+}
+
+// detectBaseCommand wählt die erste verfügbare Alternative. Die Wahl gilt, bis
+// sich Alternativen, Probe oder PATH ändern oder der gewählte Befehl nicht
+// mehr an derselben Stelle liegt.
+func detectBaseCommand(candidates, probe []string, executor Executor) (string, error) {
+	key, _ := json.Marshal([]any{candidates, probe, executor, os.Getenv("PATH")})
+	cachePath := ""
+	if dir, err := stateDir("base-command"); err == nil {
+		cachePath = filepath.Join(dir, sha256Hex(key)[:16]+".json")
+	}
+
+	if cachePath != "" {
+		var choice baseCommandChoice
+		if data, err := os.ReadFile(cachePath); err == nil && json.Unmarshal(data, &choice) == nil {
+			for _, candidate := range candidates {
+				if candidate != choice.Command {
+					continue
+				}
+				name, _ := splitBaseCommand(candidate, executor)
+				if path, err := lookBaseCommand(name); err == nil && path != "" && path == choice.Path {
+					return candidate, nil
+				}
+			}
+		}
+	}
+
+	var tried []string
+	for _, candidate := range candidates {
+		name, rest := splitBaseCommand(candidate, executor)
+		path, err := lookBaseCommand(name)
+		if err != nil {
+			tried = append(tried, fmt.Sprintf("%s (%v)", candidate, err))
+			continue
+		}
+		if path == "" {
+			tried = append(tried, fmt.Sprintf("%s (%s nicht gefunden)", candidate, name))
+			continue
+		}
+		candidateProbe := probe
+		if len(candidateProbe) == 0 && strings.TrimSpace(rest) != "" {
+			// "docker compose" gibt es nur, wenn docker das Sub-Command kennt
+			candidateProbe = defaultSubCommandProbe
+		}
+		if len(candidateProbe) > 0 {
+			if err := runProbe(joinBaseCommand(path, rest, executor), candidateProbe, executor); err != nil {
+				tried = append(tried, fmt.Sprintf("%s (%s %s: %v)", candidate, candidate, strings.Join(candidateProbe, " "), err))
+				continue
+			}
+		}
+		if cachePath != "" {
+			choice := baseCommandChoice{Command: candidate, Path: path, Detected: time.Now().UTC()}
+			if data, err := json.Marshal(choice); err == nil {
+				_ = WriteFileAtomic(cachePath, data, 0600)
+			}
+		}
+		return candidate, nil
+	}
+	return "", fmt.Errorf("kein base_command verfügbar, versucht: %s", strings.Join(tried, ", "))
+}
+
+// defaultSubCommandProbe prüft Alternativen mit Sub-Command ohne base_command_probe
+var defaultSubCommandProbe = []string{"--help"}
+
+// runProbe führt command mit den Argumenten der Probe ohne Ausgabe aus
+func runProbe(command string, probe []string, executor Executor) error {
+	cmd, err := NewCommand(command, probe, executor)
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	timer := time.AfterFunc(probeTimeout, func() { _ = cmd.Process.Kill() })
+	defer timer.Stop()
+	return cmd.Wait()
+}
@@ -0,0 +1,249 @@
+package proxy
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Die Verzeichnisse aus embed_dirs werden beim Build als tar-Archiv in den
+// Abschnitt "assets" gepackt. Zur Laufzeit entpackt der Proxy sie einmalig in
+// ein Cache-Verzeichnis, dessen Name die Prüfsumme des Archivs ist, und prüft
+// bei jedem weiteren Aufruf die Prüfsummen der entpackten Dateien.
+
+// AssetsSection enthält die Dateien aus embed_dirs
+const AssetsSection = "assets"
+
+// AssetPrefix kennzeichnet Hook-Commands relativ zum Asset-Verzeichnis,
+// z.B. "asset:scripts/pre-up.sh"
+const AssetPrefix = "asset:"
+
+// AssetFile ist eine Datei aus embed_dirs, Name ist relativ mit "/" getrennt
+type AssetFile struct {
+	Name       string
+	Data       []byte
+	Executable bool
+}
+
+// EncodeAssets packt die Dateien sortiert und ohne Zeitstempel, damit gleiche
+// Inhalte dasselbe Archiv ergeben
+func EncodeAssets(files []AssetFile) ([]byte, error) {
+	sorted := append([]AssetFile(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, file := range sorted {
+		if err := validateAssetName(file.Name); err != nil {
+			return nil, err
+		}
+		mode := int64(0644)
+		if file.Executable {
+			mode = 0755
+		}
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.Name,
+			Mode:     mode,
+			Size:     int64(len(file.Data)),
+			ModTime:  time.Unix(0, 0),
+			Format:   tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(file.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeAssets liest ein Archiv aus EncodeAssets
+func DecodeAssets(archive []byte) ([]AssetFile, error) {
+	var files []AssetFile
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("assets: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("assets: %s ist keine reguläre Datei", header.Name)
+		}
+		if err := validateAssetName(header.Name); err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("assets: %w", err)
+		}
+		files = append(files, AssetFile{Name: header.Name, Data: data, Executable: header.Mode&0111 != 0})
+	}
+}
+
+// validateAssetName verhindert, dass beim Entpacken außerhalb des Verzeichnisses geschrieben wird
+func validateAssetName(name string) error {
+	if name == "" || path.IsAbs(name) || path.Clean(name) != name || name == ".." || strings.HasPrefix(name, "../") || strings.Contains(name, `\`) {
+		return fmt.Errorf("assets: ungültiger Pfad %q", name)
+	}
+	return nil
+}
+
+// ExtractAssets entpackt das Archiv nach <cache>/proxybuild/assets/<sha256>
+// und liefert das Verzeichnis. Stimmt dort bereits jede Datei, wird nichts
+// geschrieben. Fehlende oder veränderte Dateien führen zu neuem Entpacken.
+func ExtractAssets(archive []byte) (string, error) {
+	files, err := DecodeAssets(archive)
+	if err != nil {
+		return "", err
+	}
+	base, err := stateDir("assets")
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(archive)
+	dir := filepath.Join(base, hex.EncodeToString(sum[:]))
+	if verifyAssets(dir, files) == nil {
+		return dir, nil
+	}
+
+	unlock, err := LockFile(dir, 30*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	// Ein paralleler Aufruf hat eventuell schon entpackt
+	if verifyAssets(dir, files) == nil {
+		return dir, nil
+	}
+
+	tmp := dir + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	for _, file := range files {
+		target := filepath.Join(tmp, filepath.FromSlash(file.Name))
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return "", err
+		}
+		mode := os.FileMode(0600)
+		if file.Executable {
+			mode = 0700
+		}
+		if err := os.WriteFile(target, file.Data, mode); err != nil {
+			return "", err
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// verifyAssets prüft, ob jede Datei mit dem Inhalt aus dem Archiv in dir liegt
+func verifyAssets(dir string, files []AssetFile) error {
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file.Name)))
+		if err != nil {
+			return err
+		}
+		if sha256.Sum256(data) != sha256.Sum256(file.Data) {
+			return fmt.Errorf("%s wurde verändert", file.Name)
+		}
+	}
+	return nil
+}
+
+// resolveAssets setzt das Asset-Verzeichnis für Templates und asset:-Commands
+func resolveAssets(config *Config, state *runState) error {
+	state.ctx.AssetDir = config.AssetDir
+	if len(config.Assets) == 0 {
+		return nil
+	}
+	dir, err := ExtractAssets(config.Assets)
+	if err != nil {
+		return fmt.Errorf("embed_dirs: %w", err)
+	}
+	state.ctx.AssetDir = dir
+	return nil
+}
+
+// assetCommand ersetzt den Präfix asset: durch den Pfad im Asset-Verzeichnis.
+// Beim Shell-Executor wird der Pfad gequotet, da das Cache-Verzeichnis
+// Leerzeichen enthalten kann.
+func assetCommand(command, assetDir string, executor Executor) (string, error) {
+	name, ok := strings.CutPrefix(command, AssetPrefix)
+	if !ok {
+		return command, nil
+	}
+	if assetDir == "" {
+		return "", errors.New(command + ": keine embed_dirs eingebettet")
+	}
+	resolved := filepath.Join(assetDir, filepath.FromSlash(name))
+	if executor == ExecutorDirect {
+		return resolved, nil
+	}
+	return QuoteShellWord(resolved, runtime.GOOS)
+}
+
+// validateEmbedDirs prüft embed_dirs und dass asset:-Commands in einem davon liegen
+func (c *Config) validateEmbedDirs() error {
+	var errs []error
+	for i, dir := range c.EmbedDirs {
+		if dir == "" || filepath.IsAbs(dir) || path.IsAbs(filepath.ToSlash(dir)) || strings.HasPrefix(path.Clean(filepath.ToSlash(dir)), "..") {
+			errs = append(errs, fmt.Errorf("embed_dirs[%d]: %q muss ein relativer Pfad innerhalb des Verzeichnisses der Konfiguration sein", i, dir))
+		}
+	}
+
+	subCommands := make([]string, 0, len(c.Hooks))
+	for subCommand := range c.Hooks {
+		subCommands = append(subCommands, subCommand)
+	}
+	sort.Strings(subCommands)
+	for _, subCommand := range subCommands {
+		for i, hook := range c.Hooks[subCommand] {
+			name, ok := strings.CutPrefix(hook.Command, AssetPrefix)
+			if !ok || strings.Contains(name, "{{") {
+				continue
+			}
+			if !c.embedsAsset(name) {
+				errs = append(errs, fmt.Errorf("hooks.%s[%d].command: %s liegt in keinem Verzeichnis aus embed_dirs", subCommand, i, hook.Command))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// embedsAsset prüft, ob name in einem der embed_dirs liegt
+func (c *Config) embedsAsset(name string) bool {
+	name = path.Clean(name)
+	for _, dir := range c.EmbedDirs {
+		dir = path.Clean(filepath.ToSlash(dir))
+		if dir == "." || strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// BuildHook wird von ProxyBuild vor oder nach dem Build ausgeführt, nicht vom Proxy
+type BuildHook struct {
+	Command  string   `json:"command"`
+	Args     []string `json:"args,omitempty"`
+	Executor Executor `json:"executor,omitempty"`
+	When     string   `json:"when"` // "before" oder "after"
+}
+
+// SmokeTest ruft das gebaute Executable auf und prüft Exit-Code und Ausgabe.
+// Das Basis-Command wird dabei durch einen Stub ersetzt, der über PATH gefunden wird.
+type SmokeTest struct {
+	Name           string            `json:"name,omitempty"`
+	Args           []string          `json:"args,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	Stub           *SmokeStub        `json:"stub,omitempty"`         // Verhalten des Stubs, Standard: gibt Befehl und Argumente aus
+	RealCommand    bool              `json:"real_command,omitempty"` // Echtes Basis-Command statt Stub aufrufen
+	ExitCode       int               `json:"exit_code"`
+	OutputContains []string          `json:"output_contains,omitempty"`
+	OutputMatch    string            `json:"output_match,omitempty"` // Regulärer Ausdruck für stdout und stderr
+}
+
+// SmokeStub legt fest, was der Stub des Basis-Commands ausgibt
+type SmokeStub struct {
+	Output   string `json:"output,omitempty"`
+	ExitCode int    `json:"exit_code,omitempty"`
+}
+
+// StubCommand liefert den Namen, unter dem ein Smoke-Test-Stub das Basis-Command
+// ersetzt. Absolute oder relative Pfade und Templates lassen sich über PATH
+// nicht ersetzen.
+func StubCommand(baseCommand string) (string, error) {
+	fields := strings.Fields(baseCommand)
+	if len(fields) == 0 {
+		return "", errors.New("base_command ist leer")
+	}
+	name := fields[0]
+	if strings.ContainsAny(name, `/\`) || strings.Contains(name, "{{") {
+		return "", fmt.Errorf("%q lässt sich nicht durch einen Stub im PATH ersetzen, setze real_command oder verwende einen Befehl ohne Pfad", name)
+	}
+	return name, nil
+}
+
+func validateBuildHooks(hooks []BuildHook) error {
+	var errs []error
+	for i, hook := range hooks {
+		if hook.Command == "" {
+			errs = append(errs, fmt.Errorf("build_hooks[%d]: command fehlt", i))
+		}
+		if hook.When != "before" && hook.When != "after" {
+			errs = append(errs, fmt.Errorf("build_hooks[%d]: when muss before oder after sein, nicht %q", i, hook.When))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateSmokeTests prüft die Smoke-Tests. Ein festgelegtes Basis-Command
+// (pinned) verweigert der Proxy den Stub, dann braucht jeder Test real_command.
+func validateSmokeTests(tests []SmokeTest, baseCommand string, pinned bool) error {
+	var errs []error
+	for i, test := range tests {
+		if test.OutputMatch != "" {
+			if _, err := regexp.Compile(test.OutputMatch); err != nil {
+				errs = append(errs, fmt.Errorf("smoke_tests[%d].output_match: %w", i, err))
+			}
+		}
+		if test.ExitCode < 0 {
+			errs = append(errs, fmt.Errorf("smoke_tests[%d].exit_code: %d ist negativ", i, test.ExitCode))
+		}
+		if !test.RealCommand && pinned {
+			errs = append(errs, fmt.Errorf("smoke_tests[%d]: mit base_command_path oder base_command_sha256 wird kein Stub ausgeführt, setze real_command", i))
+		} else if !test.RealCommand {
+			if _, err := StubCommand(baseCommand); err != nil {
+				errs = append(errs, fmt.Errorf("smoke_tests[%d]: %w", i, err))
+			}
+		} else if test.Stub != nil {
+			errs = append(errs, fmt.Errorf("smoke_tests[%d]: stub und real_command schließen sich aus", i))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Ein Bundle enthält die benannten Abschnitte (z.B. "config"), die ProxyBuild
+// an einen Runner übergibt. Beim Compile-Build wird es per go:embed eingebettet,
+// bei Runner-Stubs als Trailer an das Executable angehängt.
+//
+// Aufbau: Abschnitte | Länge der Abschnitte (8 Byte) | SHA-256 (32 Byte) | Magic (8 Byte)
+// Jeder Abschnitt: Namenslänge (2 Byte) | Name | Datenlänge (4 Byte) | Daten
+
+// BundleMagic markiert das Ende eines Bundles
+var BundleMagic = []byte("PXBNDL01")
+
+const (
+	bundleFooterSize = 8 + sha256.Size + 8
+
+	// Hinter dem Bundle dürfen z.B. Authenticode-Signaturen angehängt sein
+	bundleSearchWindow = 4 << 20
+)
+
+// ErrNoBundle wird geliefert, wenn keine gültige Bundle-Signatur gefunden wurde
+var ErrNoBundle = errors.New("kein eingebettetes Bundle gefunden")
+
+// EncodeBundle serialisiert die Abschnitte in stabiler Reihenfolge
+func EncodeBundle(sections map[string][]byte) []byte {
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var payload bytes.Buffer
+	for _, name := range names {
+		_ = binary.Write(&payload, binary.LittleEndian, uint16(len(name)))
+		payload.WriteString(name)
+		_ = binary.Write(&payload, binary.LittleEndian, uint32(len(sections[name])))
+		payload.Write(sections[name])
+	}
+
+	sum := sha256.Sum256(payload.Bytes())
+	out := payload.Bytes()
+	out = binary.LittleEndian.AppendUint64(out, uint64(payload.Len()))
+	out = append(out, sum[:]...)
+	return append(out, BundleMagic...)
+}
+
+// DecodeBundle liest ein Bundle, das genau am Ende von data liegt
+func DecodeBundle(data []byte) (map[string][]byte, error) {
+	if len(data) < bundleFooterSize || !bytes.Equal(data[len(data)-len(BundleMagic):], BundleMagic) {
+		return nil, ErrNoBundle
+	}
+
+	footer := data[len(data)-bundleFooterSize:]
+	length := binary.LittleEndian.Uint64(footer[:8])
+	if length > uint64(len(data)-bundleFooterSize) {
+		return nil, errors.New("bundle: ungültige Länge")
+	}
+	payload := data[len(data)-bundleFooterSize-int(length) : len(data)-bundleFooterSize]
+	if sum := sha256.Sum256(payload); !bytes.Equal(sum[:], footer[8:8+sha256.Size]) {
+		return nil, errors.New("bundle: Prüfsumme stimmt nicht, das Executable wurde verändert")
+	}
+
+	sections := make(map[string][]byte)
+	for len(payload) > 0 {
+		if len(payload) < 2 {
+			return nil, errors.New("bundle: abgeschnittener Abschnitt")
+		}
+		nameLen := int(binary.LittleEndian.Uint16(payload))
+		if len(payload) < 2+nameLen+4 {
+			return nil, errors.New("bundle: abgeschnittener Abschnitt")
+		}
+		name := string(payload[2 : 2+nameLen])
+		dataLen := int(binary.LittleEndian.Uint32(payload[2+nameLen:]))
+		payload = payload[2+nameLen+4:]
+		if len(payload) < dataLen {
+			return nil, fmt.Errorf("bundle: Abschnitt %q abgeschnitten", name)
+		}
+		sections[name] = payload[:dataLen]
+		payload = payload[dataLen:]
+	}
+	return sections, nil
+}
+
+// FindBundle sucht das letzte gültige Bundle in data. So werden auch Bundles
+// gefunden, hinter denen noch Daten (z.B. eine Signatur) liegen oder die
+// mitten im Executable eingebettet sind.
+func FindBundle(data []byte) (map[string][]byte, error) {
+	end := len(data)
+	for {
+		i := bytes.LastIndex(data[:end], BundleMagic)
+		if i < 0 {
+			return nil, ErrNoBundle
+		}
+		if sections, err := DecodeBundle(data[:i+len(BundleMagic)]); err == nil {
+			return sections, nil
+		}
+		end = i
+	}
+}
+
+// ReadBundleFile liest das Bundle, das an die Datei path angehängt ist
+func ReadBundleFile(path string) (map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Schneller Weg: das Bundle liegt direkt am Ende
+	size := info.Size()
+	if size >= bundleFooterSize {
+		footer := make([]byte, bundleFooterSize)
+		if _, err := f.ReadAt(footer, size-bundleFooterSize); err != nil {
+			return nil, err
+		}
+		if bytes.Equal(footer[bundleFooterSize-len(BundleMagic):], BundleMagic) {
+			length := int64(binary.LittleEndian.Uint64(footer[:8]))
+			if length <= size-bundleFooterSize {
+				data := make([]byte, length+bundleFooterSize)
+				if _, err := f.ReadAt(data, size-int64(len(data))); err != nil {
+					return nil, err
+				}
+				return DecodeBundle(data)
+			}
+		}
+	}
+
+	// Sonst das Ende der Datei durchsuchen
+	start := size - bundleSearchWindow
+	if start < 0 {
+		start = 0
+	}
+	data := make([]byte, size-start)
+	if _, err := f.ReadAt(data, start); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return FindBundle(data)
+}
+
+// ReadExecutableBundle liest das Bundle, das an das laufende Executable angehängt ist
+func ReadExecutableBundle() (map[string][]byte, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return ReadBundleFile(exe)
+}
+
+// LoadBundleConfig liest und validiert die Konfiguration aus einem Bundle
+func LoadBundleConfig(sections map[string][]byte) (*Config, error) {
+	data, ok := sections["config"]
+	if !ok {
+		return nil, errors.New("bundle enthält keine Konfiguration")
+	}
+	return LoadConfig(data)
+}
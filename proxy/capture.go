@@ -0,0 +1,201 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CaptureOptions steuern, wie die Ausgabe eines Hooks mit capture_as übernommen wird
+type CaptureOptions struct {
+	Trim     bool   `json:"trim,omitempty"`      // Whitespace am Anfang und Ende entfernen
+	LastLine bool   `json:"last_line,omitempty"` // Nur die letzte nicht-leere Zeile übernehmen
+	JSON     string `json:"json,omitempty"`      // Ausgabe als JSON parsen und diesen Pfad übernehmen, z.B. "data.token"
+	Secret   bool   `json:"secret,omitempty"`    // Wert in Fehlermeldungen und der Fehlerausgabe von Hooks schwärzen
+}
+
+var captureNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// runState hält die Werte, die während eines Aufrufs von Hook zu Hook weitergereicht werden
+type runState struct {
+	ctx     *TemplateContext
+	env     map[string]string // Zusätzliche Umgebungsvariablen aus Captures
+	secrets []string
+}
+
+func newRunState(subCommand string, args []string, start time.Time) *runState {
+	ctx := newTemplateContext(subCommand, args, start)
+	ctx.Vars = make(map[string]string)
+	return &runState{ctx: ctx, env: make(map[string]string)}
+}
+
+// set macht einen Wert als Umgebungsvariable und als {{.Vars.NAME}} verfügbar
+func (s *runState) set(name, value string, secret bool) {
+	s.ctx.Vars[name] = value
+	s.ctx.Env[name] = value
+	s.env[name] = value
+	if secret && value != "" {
+		s.secrets = append(s.secrets, value)
+	}
+}
+
+// environ liefert die Umgebung für Hooks inklusive aller bisher gesetzten Werte
+func (s *runState) environ() []string {
+	if len(s.env) == 0 {
+		return nil
+	}
+	env := os.Environ()
+	for key, value := range s.env {
+		env = append(env, key+"="+value)
+	}
+	return env
+}
+
+// redact entfernt geheime Werte aus einer Fehlermeldung
+func (s *runState) redact(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	redacted := redactSecrets(msg, s.secrets)
+	if redacted == msg {
+		return err
+	}
+	return errors.New(redacted)
+}
+
+// stderr liefert die Fehlerausgabe für einen Hook. Sind geheime Werte bekannt,
+// werden sie darin geschwärzt, flush schreibt danach zurückgehaltene Reste.
+func (s *runState) stderr() (io.Writer, func()) {
+	if len(s.secrets) == 0 {
+		return os.Stderr, func() {}
+	}
+	w := &redactWriter{w: os.Stderr, secrets: s.secrets}
+	return w, w.Flush
+}
+
+func redactSecrets(text string, secrets []string) string {
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, "***")
+	}
+	return text
+}
+
+// redactWriter schwärzt geheime Werte in einem Ausgabestrom. Zurückgehalten
+// wird nur das Ende, mit dem ein geheimer Wert beginnen könnte, mit hold
+// alles bis Flush.
+type redactWriter struct {
+	w       io.Writer
+	secrets []string
+	hold    bool
+	buf     []byte
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	r.buf = append(r.buf, p...)
+	if r.hold {
+		return len(p), nil
+	}
+	out := redactSecrets(string(r.buf), r.secrets)
+	keep := partialSecret(out, r.secrets)
+	r.buf = []byte(out[len(out)-keep:])
+	if _, err := io.WriteString(r.w, out[:len(out)-keep]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush schreibt den Rest geschwärzt
+func (r *redactWriter) Flush() {
+	io.WriteString(r.w, redactSecrets(string(r.buf), r.secrets))
+	r.buf = nil
+}
+
+// partialSecret liefert die Länge des längsten Endes von text, mit dem ein
+// geheimer Wert beginnt
+func partialSecret(text string, secrets []string) int {
+	longest := 0
+	for _, secret := range secrets {
+		for n := min(len(secret)-1, len(text)); n > longest; n-- {
+			if strings.HasPrefix(secret, text[len(text)-n:]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// processCapture wendet die CaptureOptions auf die Ausgabe eines Hooks an
+func processCapture(output []byte, opts *CaptureOptions) (string, error) {
+	// Wie bei $(...) in der Shell werden abschließende Zeilenumbrüche entfernt
+	value := strings.TrimRight(string(output), "\r\n")
+	if opts == nil {
+		return value, nil
+	}
+
+	if opts.LastLine {
+		lines := strings.Split(value, "\n")
+		value = ""
+		for i := len(lines) - 1; i >= 0; i-- {
+			if line := strings.TrimSpace(lines[i]); line != "" {
+				value = strings.TrimRight(lines[i], "\r")
+				break
+			}
+		}
+	}
+
+	if opts.JSON != "" {
+		extracted, err := extractJSON([]byte(value), opts.JSON)
+		if err != nil {
+			return "", err
+		}
+		value = extracted
+	}
+
+	if opts.Trim {
+		value = strings.TrimSpace(value)
+	}
+	return value, nil
+}
+
+// extractJSON liest einen mit Punkten getrennten Pfad aus einem JSON-Dokument.
+// "." liefert das gesamte Dokument.
+func extractJSON(data []byte, jsonPath string) (string, error) {
+	var node any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&node); err != nil {
+		return "", fmt.Errorf("ausgabe ist kein gültiges JSON: %w", err)
+	}
+
+	if jsonPath != "." {
+		for _, key := range strings.Split(jsonPath, ".") {
+			object, ok := node.(map[string]any)
+			if !ok {
+				return "", fmt.Errorf("JSON-Pfad %q: %q ist kein Objekt", jsonPath, key)
+			}
+			if node, ok = object[key]; !ok {
+				return "", fmt.Errorf("JSON-Pfad %q: Feld %q fehlt", jsonPath, key)
+			}
+		}
+	}
+
+	switch v := node.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case nil:
+		return "", nil
+	default:
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	}
+}
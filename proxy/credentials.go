@@ -0,0 +1,227 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CredentialProvider beschreibt ein Command, das Credentials als JSON ausgibt.
+// Die Werte werden bis kurz vor Ablauf in einer nur für den Benutzer lesbaren
+// Datei zwischengespeichert.
+type CredentialProvider struct {
+	Name          string            `json:"name"` // Eindeutiger Name, bestimmt die Cache-Datei
+	Command       string            `json:"command"`
+	Args          []string          `json:"args"`
+	Executor      Executor          `json:"executor"`
+	Env           map[string]string `json:"env"`                      // Umgebungsvariable -> JSON-Pfad in der Ausgabe
+	ExpiryField   string            `json:"expiry_field,omitempty"`   // JSON-Pfad des Ablaufzeitpunkts, Standard "expiry"
+	TTL           string            `json:"ttl,omitempty"`            // Gültigkeit, falls die Ausgabe keinen Ablaufzeitpunkt enthält
+	RefreshBefore string            `json:"refresh_before,omitempty"` // Vorlauf vor dem Ablauf, Standard "5m"
+}
+
+// credentialCache ist der Inhalt einer Cache-Datei
+type credentialCache struct {
+	Fingerprint string            `json:"fingerprint"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+	Values      map[string]string `json:"values"`
+}
+
+const (
+	defaultExpiryField   = "expiry"
+	defaultRefreshBefore = 5 * time.Minute
+	credentialLockWait   = 2 * time.Minute
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// resolveCredentials lädt alle Credentials und macht sie als Umgebungsvariablen verfügbar
+func resolveCredentials(providers []CredentialProvider, state *runState) error {
+	for _, provider := range providers {
+		values, err := provider.load(time.Now())
+		if err != nil {
+			return fmt.Errorf("credentials %s: %w", provider.Name, err)
+		}
+		for key, value := range values {
+			state.set(key, value, true)
+		}
+	}
+	return nil
+}
+
+// validate prüft die statischen Angaben eines Providers
+func (p CredentialProvider) validate() error {
+	if p.Name == "" {
+		return errors.New("name fehlt")
+	}
+	if p.Command == "" {
+		return errors.New("command fehlt")
+	}
+	if len(p.Env) == 0 {
+		return errors.New("env muss mindestens eine Variable festlegen")
+	}
+	for key := range p.Env {
+		if !captureNamePattern.MatchString(key) {
+			return fmt.Errorf("ungültiger Variablenname %q", key)
+		}
+	}
+	if _, err := p.refreshBefore(); err != nil {
+		return err
+	}
+	if p.TTL != "" {
+		if _, err := time.ParseDuration(p.TTL); err != nil {
+			return fmt.Errorf("ungültige ttl: %w", err)
+		}
+	}
+	return nil
+}
+
+func (p CredentialProvider) refreshBefore() (time.Duration, error) {
+	if p.RefreshBefore == "" {
+		return defaultRefreshBefore, nil
+	}
+	d, err := time.ParseDuration(p.RefreshBefore)
+	if err != nil {
+		return 0, fmt.Errorf("ungültiges refresh_before: %w", err)
+	}
+	return d, nil
+}
+
+// fingerprint ändert sich, sobald der Provider anders konfiguriert wird
+func (p CredentialProvider) fingerprint() string {
+	data, _ := json.Marshal(p)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (p CredentialProvider) cachePath() (string, error) {
+	dir, err := stateDir("credentials")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, unsafeFileChars.ReplaceAllString(p.Name, "_")+".json"), nil
+}
+
+// load liefert die Credentials aus dem Cache oder ruft den Provider auf.
+// Parallele Aufrufe warten auf eine gemeinsame Sperre, sodass nur einer aktualisiert.
+func (p CredentialProvider) load(now time.Time) (map[string]string, error) {
+	refreshBefore, err := p.refreshBefore()
+	if err != nil {
+		return nil, err
+	}
+	path, err := p.cachePath()
+	if err != nil {
+		return nil, err
+	}
+
+	if values, ok := p.readCache(path, now, refreshBefore); ok {
+		return values, nil
+	}
+
+	unlock, err := LockFile(path, credentialLockWait)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Ein anderer Prozess hat eventuell gerade aktualisiert
+	if values, ok := p.readCache(path, time.Now(), refreshBefore); ok {
+		return values, nil
+	}
+
+	cache, err := p.fetch(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !cache.ExpiresAt.IsZero() {
+		data, err := json.Marshal(cache)
+		if err != nil {
+			return nil, err
+		}
+		if err := WriteFileAtomic(path, data, 0600); err != nil {
+			return nil, err
+		}
+	}
+	return cache.Values, nil
+}
+
+func (p CredentialProvider) readCache(path string, now time.Time, refreshBefore time.Duration) (map[string]string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var cache credentialCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, false
+	}
+	if cache.Fingerprint != p.fingerprint() || !now.Add(refreshBefore).Before(cache.ExpiresAt) {
+		return nil, false
+	}
+	return cache.Values, true
+}
+
+// fetch führt den Provider aus und liest Werte und Ablaufzeitpunkt aus der JSON-Ausgabe
+func (p CredentialProvider) fetch(now time.Time) (*credentialCache, error) {
+	cmd, err := NewCommand(p.Command, p.Args, p.Executor)
+	if err != nil {
+		return nil, err
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	cache := &credentialCache{Fingerprint: p.fingerprint(), Values: make(map[string]string)}
+
+	keys := make([]string, 0, len(p.Env))
+	for key := range p.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := extractJSON(stdout.Bytes(), p.Env[key])
+		if err != nil {
+			return nil, err
+		}
+		cache.Values[key] = value
+	}
+
+	expiryField := p.ExpiryField
+	if expiryField == "" {
+		expiryField = defaultExpiryField
+	}
+	if raw, err := extractJSON(stdout.Bytes(), expiryField); err == nil && raw != "" {
+		if cache.ExpiresAt, err = parseExpiry(raw); err != nil {
+			return nil, err
+		}
+	} else if p.TTL != "" {
+		ttl, _ := time.ParseDuration(p.TTL)
+		cache.ExpiresAt = now.Add(ttl)
+	}
+	return cache, nil
+}
+
+// parseExpiry akzeptiert RFC3339-Zeitpunkte und Unix-Sekunden
+func parseExpiry(raw string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(raw))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ablaufzeitpunkt %q weder RFC3339 noch Unix-Sekunden", raw)
+	}
+	return t, nil
+}
@@ -1,4 +0,0 @@
-module ProxyBuild/proxy
-
-go 1.24
-
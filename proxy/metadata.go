@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// MetadataSection enthält die Build-Metadaten im Bundle. Beim Compile-Build
+// werden sie zusätzlich per -ldflags "-X" in den Runner geschrieben.
+const MetadataSection = "meta"
+
+// BuildMetadata beschreibt, wie ein Proxy gebaut wurde
+type BuildMetadata struct {
+	ProxyBuildVersion string `json:"proxybuild_version"`
+	ConfigSHA256      string `json:"config_sha256"`
+	BuildTime         string `json:"build_time"`           // RFC 3339, UTC
+	GitCommit         string `json:"git_commit,omitempty"` // Commit des Repositories der Konfiguration, "-dirty" bei Änderungen
+	Target            string `json:"target"`               // GOOS/GOARCH
+	Name              string `json:"name,omitempty"`       // Name des Executables ohne .exe, muss zum Update-Feed passen
+}
+
+// EncodeMetadata serialisiert die Metadaten als base64, damit sie ohne
+// Quoting als -X-Wert in -ldflags passen
+func EncodeMetadata(meta *BuildMetadata) string {
+	data, _ := json.Marshal(meta)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// DecodeMetadata liest Metadaten aus EncodeMetadata
+func DecodeMetadata(encoded string) (*BuildMetadata, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("ungültige Build-Metadaten: %w", err)
+	}
+	var meta BuildMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("ungültige Build-Metadaten: %w", err)
+	}
+	return &meta, nil
+}
+
+// BundleMetadata liefert die Metadaten eines Runners. stamped ist der per
+// -ldflags gesetzte Wert, sonst wird der Abschnitt im Bundle gelesen.
+// Proxies älterer ProxyBuild-Versionen haben keine Metadaten (nil).
+func BundleMetadata(sections map[string][]byte, stamped string) (*BuildMetadata, error) {
+	if stamped != "" {
+		return DecodeMetadata(stamped)
+	}
+	data, ok := sections[MetadataSection]
+	if !ok {
+		return nil, nil
+	}
+	return DecodeMetadata(string(data))
+}
+
+// WriteMetadata gibt die Metadaten für --proxy-version und -inspect aus
+func WriteMetadata(w io.Writer, meta *BuildMetadata) {
+	if meta == nil {
+		fmt.Fprintln(w, "Metadaten:     keine (mit einer älteren ProxyBuild-Version gebaut)")
+		return
+	}
+	gitCommit := meta.GitCommit
+	if gitCommit == "" {
+		gitCommit = "unbekannt"
+	}
+	fmt.Fprintf(w, "ProxyBuild:    %s\n", meta.ProxyBuildVersion)
+	fmt.Fprintf(w, "Konfiguration: sha256:%s\n", meta.ConfigSHA256)
+	fmt.Fprintf(w, "Git-Commit:    %s\n", gitCommit)
+	fmt.Fprintf(w, "Build-Zeit:    %s\n", meta.BuildTime)
+	fmt.Fprintf(w, "Ziel:          %s\n", meta.Target)
+	if meta.Name != "" {
+		fmt.Fprintf(w, "Name:          %s\n", meta.Name)
+	}
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Signaturen und öffentliche Schlüssel im Format von minisign. Signiert wird die
+// Datei selbst (Algorithmus "Ed"), dazu ein signierter Kommentar ("trusted comment").
+
+const minisignAlgorithm = "Ed"
+
+// MinisignPublicKey ist ein öffentlicher ed25519-Schlüssel mit minisign-Schlüssel-ID
+type MinisignPublicKey struct {
+	KeyID [8]byte
+	Key   ed25519.PublicKey
+}
+
+// NewMinisignPublicKey leitet die Schlüssel-ID aus dem öffentlichen Schlüssel ab
+func NewMinisignPublicKey(key ed25519.PublicKey) *MinisignPublicKey {
+	sum := sha256.Sum256(key)
+	pub := &MinisignPublicKey{Key: key}
+	copy(pub.KeyID[:], sum[:8])
+	return pub
+}
+
+// ID liefert die Schlüssel-ID so, wie minisign sie anzeigt
+func (k *MinisignPublicKey) ID() string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(k.KeyID[:]))
+}
+
+// String liefert den Schlüssel als base64-Zeile, wie sie minisign -P erwartet
+func (k *MinisignPublicKey) String() string {
+	data := append([]byte(minisignAlgorithm), k.KeyID[:]...)
+	return base64.StdEncoding.EncodeToString(append(data, k.Key...))
+}
+
+// Encode liefert den Inhalt einer .pub-Datei
+func (k *MinisignPublicKey) Encode() []byte {
+	return []byte(fmt.Sprintf("untrusted comment: minisign public key %s\n%s\n", k.ID(), k.String()))
+}
+
+// ParseMinisignPublicKey liest eine .pub-Datei oder die base64-Zeile allein
+func ParseMinisignPublicKey(data []byte) (*MinisignPublicKey, error) {
+	line := ""
+	for _, l := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "untrusted comment:") {
+			line = l
+			break
+		}
+	}
+	raw, err := base64.StdEncoding.DecodeString(line)
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize {
+		return nil, errors.New("ungültiger öffentlicher Schlüssel (minisign-Format erwartet)")
+	}
+	if string(raw[:2]) != minisignAlgorithm {
+		return nil, fmt.Errorf("nicht unterstützter Schlüsseltyp %q", raw[:2])
+	}
+	pub := &MinisignPublicKey{Key: ed25519.PublicKey(raw[10:])}
+	copy(pub.KeyID[:], raw[2:10])
+	return pub, nil
+}
+
+// SignMinisign signiert message und liefert den Inhalt einer .minisig-Datei
+func SignMinisign(key ed25519.PrivateKey, message []byte, trustedComment string) []byte {
+	pub := NewMinisignPublicKey(key.Public().(ed25519.PublicKey))
+	signature := ed25519.Sign(key, message)
+	global := ed25519.Sign(key, append(append([]byte{}, signature...), trustedComment...))
+
+	data := append([]byte(minisignAlgorithm), pub.KeyID[:]...)
+	data = append(data, signature...)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "untrusted comment: signature from ProxyBuild secret key %s\n", pub.ID())
+	fmt.Fprintf(&out, "%s\n", base64.StdEncoding.EncodeToString(data))
+	fmt.Fprintf(&out, "trusted comment: %s\n", trustedComment)
+	fmt.Fprintf(&out, "%s\n", base64.StdEncoding.EncodeToString(global))
+	return out.Bytes()
+}
+
+// Verify prüft eine .minisig-Signatur über message und liefert den signierten Kommentar
+func (k *MinisignPublicKey) Verify(message, sigFile []byte) (string, error) {
+	lines := strings.Split(strings.TrimRight(string(sigFile), "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return "", errors.New("ungültiges Signaturformat")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return "", errors.New("ungültige Signatur")
+	}
+	switch string(raw[:2]) {
+	case minisignAlgorithm:
+	case "ED":
+		return "", errors.New("vorgehashte Signaturen (minisign ED) werden nicht unterstützt, mit minisign -l signieren")
+	default:
+		return "", fmt.Errorf("unbekannter Signaturalgorithmus %q", raw[:2])
+	}
+	if !bytes.Equal(raw[2:10], k.KeyID[:]) {
+		other := &MinisignPublicKey{}
+		copy(other.KeyID[:], raw[2:10])
+		return "", fmt.Errorf("signiert mit Schlüssel %s, erwartet %s", other.ID(), k.ID())
+	}
+	signature := raw[10:]
+	if !ed25519.Verify(k.Key, message, signature) {
+		return "", errors.New("Signatur ungültig, die Datei wurde verändert")
+	}
+
+	trusted := strings.TrimSuffix(strings.TrimPrefix(lines[2], "trusted comment: "), "\r")
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || !ed25519.Verify(k.Key, append(append([]byte{}, signature...), trusted...), global) {
+		return "", errors.New("signierter Kommentar ungültig")
+	}
+	return trusted, nil
+}
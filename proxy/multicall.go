@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Ein Multi-Call-Executable enthält mehrere Konfigurationen in den Abschnitten
+// "config/<name>" statt einer in "config". Welche gilt, entscheidet der Name,
+// unter dem es aufgerufen wird (Symlink oder Hardlink), oder das erste Argument.
+
+// MultiCallPrefix ist der Präfix der Konfigurationsabschnitte eines Multi-Call-Bundles
+const MultiCallPrefix = "config/"
+
+// MultiCallNames liefert die Befehlsnamen eines Multi-Call-Bundles sortiert,
+// bei einem Bundle mit einer einzelnen Konfiguration nil
+func MultiCallNames(sections map[string][]byte) []string {
+	var names []string
+	for section := range sections {
+		if name, ok := strings.CutPrefix(section, MultiCallPrefix); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SelectCommand wählt für den Aufruf args (wie os.Args) einen der Befehlsnamen:
+// zuerst über den Namen des Executables ohne .exe, dann über das erste Argument,
+// das dabei entfernt wird. Passt keiner, ist der Name leer. Zurück kommen der
+// Name und die Argumente für den Proxy.
+func SelectCommand(names []string, args []string) (string, []string) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	if len(names) == 0 {
+		return "", args[1:]
+	}
+	invoked := strings.TrimSuffix(filepath.Base(args[0]), ".exe")
+	for _, name := range names {
+		if name == invoked {
+			return name, args[1:]
+		}
+	}
+	if len(args) > 1 {
+		for _, name := range names {
+			if name == args[1] {
+				return name, args[2:]
+			}
+		}
+	}
+	return "", args[1:]
+}
+
+// LoadBundleConfigFor liest die Konfiguration des Befehls name aus einem
+// Multi-Call-Bundle, mit leerem Namen die einzelne Konfiguration
+func LoadBundleConfigFor(sections map[string][]byte, name string) (*Config, error) {
+	if name == "" {
+		return LoadBundleConfig(sections)
+	}
+	data, ok := sections[MultiCallPrefix+name]
+	if !ok {
+		return nil, fmt.Errorf("bundle enthält keine Konfiguration für %q", name)
+	}
+	return LoadConfig(data)
+}
+
+// BundleConfigSHA256 liefert die Prüfsumme der eingebetteten Konfiguration. Bei
+// einem Multi-Call-Bundle zählen alle Konfigurationen samt ihren Namen.
+func BundleConfigSHA256(sections map[string][]byte) string {
+	if data, ok := sections["config"]; ok {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+	configs := make(map[string][]byte)
+	for section, data := range sections {
+		if strings.HasPrefix(section, MultiCallPrefix) {
+			configs[section] = data
+		}
+	}
+	sum := sha256.Sum256(EncodeBundle(configs))
+	return hex.EncodeToString(sum[:])
+}
+
+// InstallLinks legt in dir für jeden Befehlsnamen einen Symlink auf das laufende
+// Executable an. Wo Symlinks nicht erlaubt sind (Windows ohne Entwicklermodus),
+// wird ein Hardlink versucht. Vorhandene Links auf das Executable bleiben stehen,
+// andere Dateien werden nicht überschrieben.
+func InstallLinks(w io.Writer, dir string, names []string) error {
+	if len(names) == 0 {
+		return errors.New("kein Multi-Call-Executable, es gibt keine Befehle zum Verlinken")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, name := range names {
+		if runtime.GOOS == "windows" {
+			name += ".exe"
+		}
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); err == nil {
+			if SameFile(link, exe) {
+				fmt.Fprintf(w, "✓ %s (vorhanden)\n", link)
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%s existiert bereits und zeigt nicht auf %s", link, exe))
+			continue
+		}
+		if err := LinkExecutable(exe, link); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Fprintf(w, "✓ %s -> %s\n", link, exe)
+	}
+	return errors.Join(errs...)
+}
+
+// SameFile prüft, ob path (auch über Symlinks) dieselbe Datei wie target ist
+func SameFile(path, target string) bool {
+	a, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	b, err := os.Stat(target)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(a, b)
+}
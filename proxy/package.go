@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Paketformate, die ProxyBuild beim Release-Build erzeugen kann
+const (
+	PackageDeb   = "deb"
+	PackageRPM   = "rpm"
+	PackageBrew  = "brew"
+	PackageScoop = "scoop"
+)
+
+// PackageConfig beschreibt, wie ein Proxy als Paket ausgeliefert wird
+type PackageConfig struct {
+	Name        string `json:"name,omitempty"` // Paketname, Standard ist der Name des Executables
+	Version     string `json:"version"`
+	Release     string `json:"release,omitempty"` // Paket-Release für deb und rpm, Standard "1"
+	Maintainer  string `json:"maintainer,omitempty"`
+	Description string `json:"description,omitempty"`
+	Homepage    string `json:"homepage,omitempty"`
+	License     string `json:"license,omitempty"`
+	Vendor      string `json:"vendor,omitempty"`
+
+	BinDir   string   `json:"bin_dir,omitempty"`  // Installationsverzeichnis, Standard /usr/bin
+	Symlinks []string `json:"symlinks,omitempty"` // Namen (in shim_dir) oder absolute Pfade, die auf den Proxy zeigen
+	ShimDir  string   `json:"shim_dir,omitempty"` // Verzeichnis der Symlinks ohne Pfad, Standard /usr/lib/<name>/bin
+
+	Formats     []string `json:"formats,omitempty"`      // deb, rpm, brew, scoop
+	URLTemplate string   `json:"url_template,omitempty"` // Download-URL der Archive für Homebrew und Scoop
+}
+
+// PackageFormats liefert die zu erzeugenden Formate. Ohne Angabe sind das deb und
+// rpm sowie Homebrew und Scoop, sofern eine Download-URL konfiguriert ist.
+func (p *PackageConfig) PackageFormats() []string {
+	if len(p.Formats) > 0 {
+		return p.Formats
+	}
+	formats := []string{PackageDeb, PackageRPM}
+	if p.URLTemplate != "" {
+		formats = append(formats, PackageBrew, PackageScoop)
+	}
+	return formats
+}
+
+// SymlinkPaths liefert die absoluten Pfade der Symlinks. Namen ohne Pfad
+// landen in dir, bei deb und rpm ist das shim_dir. In bin_dir würden sie mit
+// dem Paket des ursprünglichen Befehls kollidieren, /usr/local gehört nicht
+// in Systempakete.
+func (p *PackageConfig) SymlinkPaths(dir string) []string {
+	paths := make([]string, 0, len(p.Symlinks))
+	for _, link := range p.Symlinks {
+		if !path.IsAbs(link) {
+			link = path.Join(dir, link)
+		}
+		paths = append(paths, link)
+	}
+	return paths
+}
+
+func (p *PackageConfig) validate() error {
+	if p.Version == "" {
+		return errors.New("version fehlt")
+	}
+	if strings.ContainsAny(p.Version, " -/") {
+		return fmt.Errorf("ungültige version %q (keine Leerzeichen, '-' oder '/')", p.Version)
+	}
+	if p.BinDir != "" && !path.IsAbs(p.BinDir) {
+		return fmt.Errorf("bin_dir muss ein absoluter Pfad sein: %q", p.BinDir)
+	}
+	if p.ShimDir != "" && !path.IsAbs(p.ShimDir) {
+		return fmt.Errorf("shim_dir muss ein absoluter Pfad sein: %q", p.ShimDir)
+	}
+	if p.ShimDir != "" && strings.ContainsAny(p.ShimDir, " '\"$`\\") {
+		return fmt.Errorf("shim_dir darf keine Leerzeichen oder Anführungszeichen enthalten: %q", p.ShimDir)
+	}
+
+	for _, format := range p.Formats {
+		switch format {
+		case PackageDeb, PackageRPM:
+		case PackageBrew, PackageScoop:
+			if p.URLTemplate == "" {
+				return fmt.Errorf("format %s benötigt url_template", format)
+			}
+		default:
+			return fmt.Errorf("unbekanntes format %q (deb, rpm, brew oder scoop)", format)
+		}
+	}
+
+	// Symlinks mit dem Namen des Basis-Commands überspringt der Proxy beim
+	// Suchen im PATH, siehe resolveBaseCommand
+	for _, link := range p.Symlinks {
+		if link == "" || strings.HasSuffix(link, "/") {
+			return fmt.Errorf("ungültiger Symlink %q", link)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Mit base_command_path und base_command_sha256 ist das Basis-Command
+// festgelegt. Der Proxy führt dann nur genau diese Datei aus und verweigert
+// den Aufruf, wenn ein anderer Befehl gleichen Namens im PATH vorne steht oder
+// die Datei ausgetauscht wurde. ProxyBuild -pin trägt beides beim Build ein.
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// absPathPattern erkennt absolute Pfade für jedes Zielsystem, auch unabhängig
+// vom Betriebssystem, auf dem gebaut wird
+var absPathPattern = regexp.MustCompile(`^(/|[A-Za-z]:[\\/]|\\\\)`)
+
+// validatePin prüft base_command_path und base_command_sha256
+func (c *Config) validatePin() error {
+	if c.BaseCommandPath == "" && c.BaseCommandSHA256 == "" {
+		return nil
+	}
+	var errs []error
+	if c.BaseCommandPath != "" && !absPathPattern.MatchString(c.BaseCommandPath) {
+		errs = append(errs, fmt.Errorf("base_command_path muss ein absoluter Pfad sein: %q", c.BaseCommandPath))
+	}
+	if c.BaseCommandSHA256 != "" && !sha256Pattern.MatchString(c.BaseCommandSHA256) {
+		errs = append(errs, fmt.Errorf("base_command_sha256: erwartet 64 Hex-Zeichen in Kleinbuchstaben, erhalten %q", c.BaseCommandSHA256))
+	}
+	if name, _ := splitBaseCommand(c.BaseCommand, c.Executor); name == "" || strings.Contains(name, "{{") {
+		errs = append(errs, fmt.Errorf("base_command: mit base_command_path oder base_command_sha256 muss der Befehl feststehen, erhalten %q", c.BaseCommand))
+	}
+	return errors.Join(errs...)
+}
+
+// PinBaseCommand sucht das Basis-Command auf diesem Rechner wie der Proxy zur
+// Laufzeit und setzt base_command_path und base_command_sha256. Ein bereits
+// gesetzter base_command_path bleibt erhalten, nur die Prüfsumme wird ergänzt.
+func PinBaseCommand(c *Config) error {
+	if len(c.BaseCommandAlternatives) > 0 {
+		return fmt.Errorf("base_command hat mehrere Alternativen (%s), gepinnt werden kann nur ein einzelner Befehl", strings.Join(c.BaseCommandAlternatives, ", "))
+	}
+	name, _ := splitBaseCommand(c.BaseCommand, c.Executor)
+	if name == "" || strings.Contains(name, "{{") {
+		return fmt.Errorf("base_command %q: nur ein fester Befehl lässt sich pinnen", c.BaseCommand)
+	}
+
+	path := c.BaseCommandPath
+	if path == "" {
+		var err error
+		if path, err = lookBaseCommand(name); err != nil {
+			return err
+		}
+		if path == "" {
+			return fmt.Errorf("%s nicht im PATH gefunden", name)
+		}
+	}
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return fmt.Errorf("base_command_path: %w", err)
+	}
+	c.BaseCommandPath = path
+	c.BaseCommandSHA256 = sum
+	return nil
+}
+
+// resolvePinnedBaseCommand ersetzt den Befehl in command durch
+// base_command_path, nachdem geprüft ist, dass im PATH kein anderer Befehl
+// vorne steht und die Prüfsumme stimmt
+func resolvePinnedBaseCommand(c *Config, command string) (string, error) {
+	name, rest := splitBaseCommand(command, c.Executor)
+	found, err := lookBaseCommand(name)
+	if err != nil {
+		return "", err
+	}
+
+	path := c.BaseCommandPath
+	switch {
+	case path == "" && found == "":
+		return "", fmt.Errorf("%s nicht gefunden, die Prüfsumme aus base_command_sha256 lässt sich nicht prüfen", name)
+	case path == "":
+		path = found
+	case found != "" && !SameFile(found, path):
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("gepinntes base_command %s fehlt, stattdessen gefunden: %s. Ist der Befehl umgezogen, den Proxy mit -pin neu bauen", path, found)
+		}
+		return "", fmt.Errorf("%s wird verdeckt: erwartet %s, gefunden %s. Den Eintrag im PATH vor %s entfernen oder, wenn der neue Befehl gewollt ist, den Proxy mit -pin neu bauen", name, path, found, filepath.Dir(path))
+	}
+
+	if c.BaseCommandSHA256 != "" {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return "", fmt.Errorf("gepinntes base_command: %w", err)
+		}
+		if sum != c.BaseCommandSHA256 {
+			return "", fmt.Errorf("%s wurde verändert: erwartet sha256:%s, erhalten sha256:%s. Nach einem gewollten Update den Proxy mit -pin neu bauen", path, c.BaseCommandSHA256, sum)
+		}
+	}
+	return joinBaseCommand(path, rest, c.Executor), nil
+}
+
+// lookBaseCommand liefert den Pfad von name: bei einem Pfad diesen, sonst den
+// ersten Treffer im PATH ohne Proxy und Shims. Leer, wenn es keinen gibt.
+func lookBaseCommand(name string) (string, error) {
+	if strings.ContainsAny(name, `/\`) {
+		path, err := filepath.Abs(name)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return "", nil
+		}
+		return path, nil
+	}
+	path, _, err := lookPathSkipping(name)
+	return path, err
+}
+
+// fileSHA256 liefert die hex-kodierte SHA-256-Prüfsumme einer Datei
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -1,11 +1,16 @@
 package proxy
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
+	"time"
 )
 
 // Config definiert die Konfiguration für Command-Hooks
@@ -14,6 +19,31 @@ type Config struct {
 	Executor    Executor          `json:"executor"`
 	Hooks       map[string][]Hook `json:"hooks"`
 	EnvVars     map[string]string `json:"env_vars"`
+
+	BaseCommandAlternatives []string `json:"-"`                            // base_command als Liste, siehe Config.UnmarshalJSON
+	BaseCommandProbe        []string `json:"base_command_probe,omitempty"` // Argumente, mit denen geprüft wird, ob eine Alternative funktioniert
+
+	BaseCommandPath   string `json:"base_command_path,omitempty"`   // Nur genau diese Datei als Basis-Command ausführen
+	BaseCommandSHA256 string `json:"base_command_sha256,omitempty"` // Erwartete Prüfsumme des Basis-Commands
+
+	Credentials []CredentialProvider `json:"credentials,omitempty"` // Zwischengespeicherte Credentials für das Basis-Command
+	Secrets     *SecretsConfig       `json:"secrets,omitempty"`     // Verschlüsselte Werte, die zur Laufzeit entschlüsselt werden
+
+	BuildEnvAllowlist []string `json:"build_env_allowlist,omitempty"` // Nur diese Umgebungsvariablen werden beim Build ersetzt
+
+	Package *PackageConfig `json:"package,omitempty"` // Metadaten für deb/rpm-Pakete, Homebrew und Scoop
+
+	BuildHooks []BuildHook `json:"build_hooks,omitempty"` // Commands, die ProxyBuild vor und nach dem Build ausführt
+	SmokeTests []SmokeTest `json:"smoke_tests,omitempty"` // Aufrufe des gebauten Executables, die der Build prüft
+
+	EmbedDirs []string `json:"embed_dirs,omitempty"` // Verzeichnisse relativ zur Konfiguration, die in das Executable gepackt werden
+
+	Update *UpdateConfig `json:"update,omitempty"` // Feed für --proxy-update und die regelmäßige Update-Prüfung
+
+	// Zur Laufzeit gesetzt: das Archiv der embed_dirs aus dem Bundle oder, ohne
+	// Bundle (ProxyBuild -config), das Verzeichnis der Konfigurationsdatei
+	Assets   []byte `json:"-"`
+	AssetDir string `json:"-"`
 }
 
 type Executor string
@@ -30,6 +60,13 @@ type Hook struct {
 	Executor   Executor   `json:"executor"`
 	When       string     `json:"when"`       // "before" oder "after"
 	Conditions Conditions `json:"conditions"` // Optionale Bedingungen
+
+	CaptureAs string          `json:"capture_as,omitempty"` // Ausgabe als Variable für spätere Hooks speichern
+	Capture   *CaptureOptions `json:"capture,omitempty"`    // Optionale Verarbeitung der gespeicherten Ausgabe
+
+	// Match ersetzt Conditions in Konfigurationen, die beim Build als Go-Code
+	// generiert wurden (-codegen). os_match ist dort bereits aufgelöst.
+	Match func(args []string, hadError bool) bool `json:"-"`
 }
 
 // Conditions definiert Bedingungen, unter denen ein Hook ausgeführt wird
@@ -40,6 +77,18 @@ type Conditions struct {
 	OsMatch     []string `json:"os_match"`     // Hook nur ausführen, wenn OS partitive übereinstimmt
 }
 
+// LoadConfig parst und validiert eine Konfiguration im JSON-Format
+func LoadConfig(data []byte) (*Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
 // Run führt den Proxy mit der gegebenen Konfiguration aus
 func Run(config *Config, args []string) error {
 	// Bestimme den Sub-Command (erstes Argument)
@@ -48,29 +97,66 @@ func Run(config *Config, args []string) error {
 		subCommand = args[0]
 	}
 
+	depth, err := nextDepth(config.BaseCommand)
+	if err != nil {
+		return err
+	}
+
 	osString := runtime.GOOS
+	start := time.Now()
+	state := newRunState(subCommand, args, start)
+	// Gilt für Hooks und Basis-Command, ist aber keine Template-Variable
+	state.env[DepthEnv] = depth
+
+	if err := resolveSecrets(config.Secrets, state); err != nil {
+		return err
+	}
+	if err := resolveAssets(config, state); err != nil {
+		return err
+	}
+	if err := resolveCredentials(config.Credentials, state); err != nil {
+		return state.redact(err)
+	}
 
 	// Führe "before" Hooks aus
 	if hooks, exists := config.Hooks[subCommand]; exists {
 		for _, hook := range hooks {
 			if hook.When == "before" && ShouldExecuteHook(hook, args, false, osString) {
-				if err := executeHook(hook); err != nil {
-					return fmt.Errorf("fehler beim Ausführen des before-Hooks: %w", err)
+				if err := executeHook(hook, state); err != nil {
+					return state.redact(fmt.Errorf("fehler beim Ausführen des before-Hooks: %w", err))
 				}
 			}
 		}
 	}
 
-	// Config EnvVars
-	configEnv := config.EnvVars
+	baseCommand, err := selectBaseCommand(config, state.ctx)
+	if err != nil {
+		return state.redact(fmt.Errorf("fehler im base_command: %w", err))
+	}
+	if config.BaseCommandPath != "" || config.BaseCommandSHA256 != "" {
+		baseCommand, err = resolvePinnedBaseCommand(config, baseCommand)
+	} else {
+		baseCommand, err = resolveBaseCommand(baseCommand, config.Executor)
+	}
+	if err != nil {
+		return state.redact(fmt.Errorf("fehler im base_command: %w", err))
+	}
 
-	if configEnv == nil {
-		configEnv = make(map[string]string)
+	// Config EnvVars, die Werte landen in der Umgebung und werden nicht gequotet
+	configEnv := make(map[string]string)
+	for key, value := range config.EnvVars {
+		if configEnv[key], err = renderTemplate(value, state.ctx, ExecutorDirect); err != nil {
+			return state.redact(fmt.Errorf("fehler in env_vars.%s: %w", key, err))
+		}
 	}
 
 	for _, kV := range os.Environ() {
-		key := strings.Split(kV, "=")[0]
-		value := strings.Split(kV, "=")[1]
+		key, value, _ := strings.Cut(kV, "=")
+		configEnv[key] = value
+	}
+
+	// Gespeicherte Hook-Ausgaben haben Vorrang
+	for key, value := range state.env {
 		configEnv[key] = value
 	}
 
@@ -79,14 +165,16 @@ func Run(config *Config, args []string) error {
 		overloaded = append(overloaded, fmt.Sprintf("%s=%s", key, value))
 	}
 
-	err := execute(config.BaseCommand, args, config.Executor, overloaded)
+	err = execute(baseCommand, args, config.Executor, overloaded, os.Stderr)
+	state.ctx.ExitCode = exitCode(err)
+	state.ctx.Duration = time.Since(start)
 
 	// Führe "after" Hooks aus
 	if hooks, exists := config.Hooks[subCommand]; exists {
 		for _, hook := range hooks {
 			if hook.When == "after" && ShouldExecuteHook(hook, args, err != nil, osString) {
-				if err := executeHook(hook); err != nil {
-					return fmt.Errorf("fehler beim Ausführen des after-Hooks: %w", err)
+				if err := executeHook(hook, state); err != nil {
+					return state.redact(fmt.Errorf("fehler beim Ausführen des after-Hooks: %w", err))
 				}
 			}
 		}
@@ -97,6 +185,10 @@ func Run(config *Config, args []string) error {
 
 // ShouldExecuteHook überprüft, ob ein Hook ausgeführt werden soll basierend auf den Bedingungen
 func ShouldExecuteHook(hook Hook, args []string, hadError bool, os string) bool {
+	if hook.Match != nil {
+		return hook.Match(args, hadError)
+	}
+
 	// Check if one of the supplies OS's matches
 	anyOsMatch := false
 	if hook.Conditions.OsMatch != nil {
@@ -152,12 +244,92 @@ func ShouldExecuteHook(hook Hook, args []string, hadError bool, os string) bool
 	return true
 }
 
-func executeHook(hook Hook) error {
-	return execute(hook.Command, hook.Args, hook.Executor, nil)
+func executeHook(hook Hook, state *runState) error {
+	// Expandiere Templates direkt vor der Ausführung
+	command, err := renderTemplate(hook.Command, state.ctx, hook.Executor)
+	if err != nil {
+		return err
+	}
+	if command, err = assetCommand(command, state.ctx.AssetDir, hook.Executor); err != nil {
+		return err
+	}
+	args := make([]string, len(hook.Args))
+	for i, arg := range hook.Args {
+		if args[i], err = renderTemplate(arg, state.ctx, hook.Executor); err != nil {
+			return err
+		}
+	}
+
+	if hook.CaptureAs == "" {
+		stderr, flush := state.stderr()
+		defer flush()
+		return execute(command, args, hook.Executor, state.environ(), stderr)
+	}
+
+	// Ausgabe speichern statt anzeigen
+	cmd, err := NewCommand(command, args, hook.Executor)
+	if err != nil {
+		return err
+	}
+	secret := hook.Capture != nil && hook.Capture.Secret
+	stderr, flush := state.stderr()
+	var held *redactWriter
+	if secret {
+		// Der geheime Wert steht erst nach dem Hook fest, bis dahin wird die
+		// Fehlerausgabe zurückgehalten
+		held = &redactWriter{w: os.Stderr, secrets: state.secrets, hold: true}
+		stderr, flush = held, held.Flush
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	cmd.Stdin = os.Stdin
+	cmd.Env = state.environ()
+	runErr := cmd.Run()
+
+	value, err := processCapture(stdout.Bytes(), hook.Capture)
+	if held != nil && err == nil && value != "" {
+		held.secrets = append(append([]string{}, held.secrets...), value)
+	}
+	flush()
+	if runErr != nil {
+		return runErr
+	}
+	if err != nil {
+		return fmt.Errorf("capture_as %s: %w", hook.CaptureAs, err)
+	}
+	state.set(hook.CaptureAs, value, secret)
+	return nil
+}
+
+// exitCode ermittelt den Exit-Code des Basis-Commands für Templates
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
 }
 
-func execute(command string, args []string, executor Executor, env []string) error {
+func execute(command string, args []string, executor Executor, env []string, stderr io.Writer) error {
+	cmd, err := NewCommand(command, args, executor)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = stderr
+	cmd.Stdin = os.Stdin
+	if env != nil {
+		cmd.Env = env
+	}
+	return cmd.Run()
+}
 
+// NewCommand erstellt den Prozess für Command und Args je nach Executor
+func NewCommand(command string, args []string, executor Executor) (*exec.Cmd, error) {
 	if executor == "" {
 		executor = ExecutorShell
 	}
@@ -173,24 +345,10 @@ func execute(command string, args []string, executor Executor, env []string) err
 			shellCmd = "/bin/sh"
 			shellArgs = []string{"-c", command + " " + strings.Join(args, " ")}
 		}
-		cmd := exec.Command(shellCmd, shellArgs...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Stdin = os.Stdin
-		if env != nil {
-			cmd.Env = env
-		}
-		return cmd.Run()
+		return exec.Command(shellCmd, shellArgs...), nil
 	} else if executor == ExecutorDirect {
-		cmd := exec.Command(command, args...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Stdin = os.Stdin
-		if env != nil {
-			cmd.Env = env
-		}
-		return cmd.Run()
+		return exec.Command(command, args...), nil
 	}
 
-	return fmt.Errorf("unbekannter Executor-Typ: %s", executor)
+	return nil, fmt.Errorf("unbekannter Executor-Typ: %s", executor)
 }
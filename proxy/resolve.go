@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Liegt ein Proxy unter dem Namen seines Basis-Commands im PATH (Shim,
+// Symlink aus package.symlinks, Multi-Call-Link), würde "docker-compose" wieder
+// den Proxy starten. Deshalb sucht der Proxy das Basis-Command selbst im PATH
+// und überspringt dabei sein eigenes Executable und die Shim-Verzeichnisse.
+// Für alle anderen Wege zurück zum Proxy (Wrapper-Skripte, Hooks) zählt
+// DepthEnv die Verschachtelung mit.
+
+// DepthEnv enthält die Anzahl der verschachtelten Proxy-Aufrufe
+const DepthEnv = "PROXYBUILD_DEPTH"
+
+// MaxDepth ist die höchste erlaubte Verschachtelung von Proxy-Aufrufen
+const MaxDepth = 8
+
+// nextDepth liefert den Wert von DepthEnv für die Kindprozesse und bricht ab,
+// wenn der Proxy sich zu oft selbst aufgerufen hat
+func nextDepth(baseCommand string) (string, error) {
+	depth, _ := strconv.Atoi(os.Getenv(DepthEnv))
+	if depth >= MaxDepth {
+		return "", fmt.Errorf("%d verschachtelte Proxy-Aufrufe (%s), base_command %q ruft vermutlich den Proxy selbst auf", depth, DepthEnv, baseCommand)
+	}
+	return strconv.Itoa(depth + 1), nil
+}
+
+// resolveBaseCommand ersetzt den Befehlsnamen am Anfang von command durch den
+// Pfad des echten Befehls, wenn zuerst der Proxy selbst oder ein Shim im PATH
+// steht. Befehle mit Pfad und Namen, die im PATH nicht vorkommen (z.B.
+// Shell-Builtins), bleiben unverändert.
+func resolveBaseCommand(command string, executor Executor) (string, error) {
+	name, rest := splitBaseCommand(command, executor)
+	if name == "" || strings.ContainsAny(name, `/\=$"'`) {
+		return command, nil
+	}
+
+	path, skipped, err := lookPathSkipping(name)
+	if err != nil {
+		return "", err
+	}
+	if path == "" || len(skipped) == 0 {
+		return command, nil
+	}
+	return joinBaseCommand(path, rest, executor), nil
+}
+
+// splitBaseCommand trennt den Befehl vom Rest. Mit dem direct-Executor ist
+// das ganze base_command der Befehl.
+func splitBaseCommand(command string, executor Executor) (name, rest string) {
+	if executor == ExecutorDirect {
+		return command, ""
+	}
+	trimmed := strings.TrimLeft(command, " \t")
+	if i := strings.IndexAny(trimmed, " \t"); i >= 0 {
+		return trimmed[:i], trimmed[i:]
+	}
+	return trimmed, ""
+}
+
+// joinBaseCommand setzt den Pfad des Befehls wieder vor den Rest
+func joinBaseCommand(path, rest string, executor Executor) string {
+	if executor == ExecutorDirect {
+		return path
+	}
+	return quoteShellWord(path) + rest
+}
+
+// lookPathSkipping sucht name in den PATH-Verzeichnissen wie exec.LookPath,
+// überspringt aber den Proxy selbst und Shims. skipped enthält die
+// übersprungenen Treffer, path ist leer, wenn name nirgends im PATH liegt.
+func lookPathSkipping(name string) (path string, skipped []string, err error) {
+	self, _ := os.Executable()
+	var shimDirs []string
+	shimDirsLoaded := false
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" || !filepath.IsAbs(dir) {
+			continue
+		}
+		candidate, err := exec.LookPath(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		if self != "" && SameFile(candidate, self) {
+			skipped = append(skipped, candidate)
+			continue
+		}
+		if !shimDirsLoaded {
+			shimDirs, shimDirsLoaded = ShimDirs(), true
+		}
+		if containsPath(shimDirs, dir) {
+			skipped = append(skipped, candidate)
+			continue
+		}
+		return candidate, skipped, nil
+	}
+	if len(skipped) > 0 {
+		return "", skipped, fmt.Errorf("%s: im PATH gibt es nur den Proxy selbst bzw. Shims (%s), der echte Befehl fehlt", name, strings.Join(skipped, ", "))
+	}
+	return "", nil, nil
+}
+
+// containsPath prüft, ob dir in dirs vorkommt
+func containsPath(dirs []string, dir string) bool {
+	for _, d := range dirs {
+		if samePath(d, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wörter aus diesen Zeichen brauchen weder für /bin/sh noch für cmd Quotes.
+// % fehlt, cmd expandiert %NAME% auch in Quotes.
+var plainShellWord = regexp.MustCompile(`^[A-Za-z0-9_@+=:,./\\-]+$`)
+
+// quoteShellWord quotet einen Pfad für /bin/sh bzw. cmd
+func quoteShellWord(word string) string {
+	quoted, err := QuoteShellWord(word, runtime.GOOS)
+	if err != nil {
+		// Nur Zeilenumbrüche für cmd, die kein Pfad enthält
+		return strconv.Quote(word)
+	}
+	return quoted
+}
+
+// QuoteShellWord quotet word als ein Argument für /bin/sh bzw. für cmd, wenn
+// goos "windows" ist. Zeilenumbrüche lassen sich für cmd nicht quoten.
+func QuoteShellWord(word, goos string) (string, error) {
+	if plainShellWord.MatchString(word) {
+		return word, nil
+	}
+	if goos != "windows" {
+		return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'", nil
+	}
+	if strings.ContainsAny(word, "\r\n") {
+		return "", fmt.Errorf("%q enthält einen Zeilenumbruch und lässt sich für cmd nicht quoten", word)
+	}
+
+	// In Quotes sind & | < > ( ) ^ für cmd wirkungslos, nicht aber % und !.
+	// Diese und " stehen deshalb außerhalb der Quotes mit ^ davor. Für das
+	// Zerlegen der Argumente durch das Programm wird " zu \" und Backslashes
+	// vor einem " werden verdoppelt.
+	var b strings.Builder
+	b.WriteByte('"')
+	backslashes := 0
+	for _, r := range word {
+		switch r {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			b.WriteString(strings.Repeat(`\`, 2*backslashes) + `"\^""`)
+		case '%', '!':
+			b.WriteString(strings.Repeat(`\`, 2*backslashes) + `"^` + string(r) + `"`)
+		default:
+			b.WriteString(strings.Repeat(`\`, backslashes))
+			b.WriteRune(r)
+		}
+		backslashes = 0
+	}
+	b.WriteString(strings.Repeat(`\`, 2*backslashes) + `"`)
+	return b.String(), nil
+}
@@ -0,0 +1,239 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// SecretsConfig enthält Werte, die verschlüsselt in der Konfiguration liegen und
+// erst zur Laufzeit entschlüsselt werden
+type SecretsConfig struct {
+	KeyEnv  string `json:"key_env,omitempty"`  // Umgebungsvariable mit dem Schlüssel, Standard PROXYBUILD_SECRETS_KEY
+	KeyFile string `json:"key_file,omitempty"` // Datei mit dem Schlüssel, "~" wird expandiert
+
+	Names      []string `json:"names"`                // Namen der Secrets, unverschlüsselt für die Validierung
+	KDF        string   `json:"kdf"`                  // "hkdf-sha256" für Schlüssel, "pbkdf2-sha256" für Passphrasen
+	Iterations int      `json:"iterations,omitempty"` // Nur für pbkdf2-sha256
+	Salt       string   `json:"salt"`
+	Nonce      string   `json:"nonce"`
+	Data       string   `json:"data"` // AES-256-GCM, Base64
+}
+
+const (
+	DefaultSecretsKeyEnv = "PROXYBUILD_SECRETS_KEY"
+
+	kdfHKDF          = "hkdf-sha256"
+	kdfPBKDF2        = "pbkdf2-sha256"
+	pbkdf2Iterations = 600000
+)
+
+// ErrNoSecretsKey wird geliefert, wenn kein Schlüssel gefunden wurde und nicht gefragt werden darf
+var ErrNoSecretsKey = errors.New("kein Schlüssel für die Secrets gefunden")
+
+// ReadKey ermittelt den Schlüssel aus Umgebungsvariable, Schlüsseldatei oder
+// per Passphrase-Abfrage. passphrase ist true, wenn der Schlüssel abgefragt wurde.
+func (s *SecretsConfig) ReadKey(prompt bool) (key []byte, passphrase bool, err error) {
+	keyEnv := s.KeyEnv
+	if keyEnv == "" {
+		keyEnv = DefaultSecretsKeyEnv
+	}
+	if value := os.Getenv(keyEnv); value != "" {
+		return []byte(value), false, nil
+	}
+
+	if s.KeyFile != "" {
+		path := s.KeyFile
+		if rest, ok := strings.CutPrefix(path, "~"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, rest)
+			}
+		}
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return []byte(strings.TrimSpace(string(data))), false, nil
+		}
+		if !prompt || !errors.Is(err, os.ErrNotExist) {
+			return nil, false, fmt.Errorf("schlüsseldatei: %w", err)
+		}
+	}
+
+	if !prompt {
+		return nil, false, ErrNoSecretsKey
+	}
+	value, err := promptPassphrase("Passphrase für Secrets: ")
+	if err != nil {
+		return nil, false, err
+	}
+	return []byte(value), true, nil
+}
+
+// EncryptSecrets verschlüsselt values mit AES-256-GCM. Für abgefragte
+// Passphrasen wird PBKDF2 verwendet, für Schlüssel aus Datei oder Umgebung HKDF.
+func EncryptSecrets(values map[string]string, key []byte, passphrase bool) (*SecretsConfig, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if !captureNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("ungültiger Secret-Name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	secrets := &SecretsConfig{Names: names, KDF: kdfHKDF}
+	if passphrase {
+		secrets.KDF = kdfPBKDF2
+		secrets.Iterations = pbkdf2Iterations
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	secrets.Salt = base64.StdEncoding.EncodeToString(salt)
+
+	aead, err := secrets.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	secrets.Nonce = base64.StdEncoding.EncodeToString(nonce)
+
+	plaintext, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := aead.Seal(nil, nonce, plaintext, secrets.additionalData())
+	secrets.Data = base64.StdEncoding.EncodeToString(ciphertext)
+	return secrets, nil
+}
+
+// Decrypt entschlüsselt die Secrets und prüft, dass sie zu den Namen passen
+func (s *SecretsConfig) Decrypt(key []byte) (map[string]string, error) {
+	aead, err := s.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := base64.StdEncoding.DecodeString(s.Nonce)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return nil, errors.New("ungültige Nonce")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(s.Data)
+	if err != nil {
+		return nil, fmt.Errorf("ungültige Daten: %w", err)
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, s.additionalData())
+	if err != nil {
+		return nil, errors.New("entschlüsselung fehlgeschlagen (falscher Schlüssel oder veränderte Daten)")
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal(plaintext, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// additionalData bindet die unverschlüsselten Namen an den Ciphertext
+func (s *SecretsConfig) additionalData() []byte {
+	return []byte(s.KDF + "\n" + strings.Join(s.Names, "\n"))
+}
+
+func (s *SecretsConfig) aead(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, ErrNoSecretsKey
+	}
+	salt, err := base64.StdEncoding.DecodeString(s.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("ungültiges Salt: %w", err)
+	}
+
+	var derived []byte
+	switch s.KDF {
+	case kdfHKDF:
+		derived, err = hkdf.Key(sha256.New, key, salt, "proxybuild secrets", 32)
+	case kdfPBKDF2:
+		derived, err = pbkdf2.Key(sha256.New, string(key), salt, s.Iterations, 32)
+	default:
+		return nil, fmt.Errorf("unbekannte Schlüsselableitung %q", s.KDF)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// resolveSecrets entschlüsselt die Secrets und macht sie als Umgebungsvariablen verfügbar
+func resolveSecrets(secrets *SecretsConfig, state *runState) error {
+	if secrets == nil {
+		return nil
+	}
+	key, _, err := secrets.ReadKey(true)
+	if err != nil {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	values, err := secrets.Decrypt(key)
+	if err != nil {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	for _, name := range secrets.Names {
+		state.set(name, values[name], true)
+	}
+	return nil
+}
+
+// promptPassphrase liest eine Passphrase vom Terminal, ohne sie anzuzeigen
+func promptPassphrase(prompt string) (string, error) {
+	ttyPath := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		ttyPath = "CONIN$"
+	}
+	tty, err := os.OpenFile(ttyPath, os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("kein Terminal für die Passphrase-Abfrage: %w", err)
+	}
+	defer tty.Close()
+
+	fmt.Fprint(os.Stderr, prompt)
+	if runtime.GOOS != "windows" {
+		if err := stty(tty, "-echo"); err == nil {
+			defer stty(tty, "echo")
+		}
+	}
+
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func stty(tty *os.File, arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = tty
+	return cmd.Run()
+}
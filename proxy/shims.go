@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Shims sind Links mit dem Namen des ursprünglichen Befehls (z.B.
+// docker-compose), die auf einen Proxy zeigen. Liegt das Shim-Verzeichnis im
+// PATH vor dem echten Befehl, ruft "docker-compose" den Proxy auf.
+// ProxyBuild -install legt sie an und trägt sie in die Registry ein, die auch
+// der Proxy liest: Verzeichnisse mit Shims überspringt er, wenn er das echte
+// Basis-Command sucht.
+
+// ShimDirEnv überschreibt das Standard-Shim-Verzeichnis für Installer und Runtime
+const ShimDirEnv = "PROXYBUILD_SHIM_DIR"
+
+// InstalledShim ist ein Eintrag der Shim-Registry
+type InstalledShim struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`   // Absoluter Pfad des Shims
+	Target    string    `json:"target"` // Absoluter Pfad des Proxys
+	ShimDir   string    `json:"shim_dir"`
+	Installed time.Time `json:"installed"`
+}
+
+// ShimRegistry ist der Inhalt von shims.json
+type ShimRegistry struct {
+	Shims []InstalledShim `json:"shims"`
+}
+
+// DefaultShimDir liefert das Shim-Verzeichnis: $PROXYBUILD_SHIM_DIR oder
+// ~/.local/proxybuild/bin (unter Windows %LocalAppData%\proxybuild\bin)
+func DefaultShimDir() (string, error) {
+	if dir := os.Getenv(ShimDirEnv); dir != "" {
+		return filepath.Abs(dir)
+	}
+	if runtime.GOOS == "windows" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(base, "proxybuild", "bin"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "proxybuild", "bin"), nil
+}
+
+// ShimRegistryPath liefert den Pfad von shims.json im Konfigurationsverzeichnis
+// des Benutzers. Anders als der Cache darf die Registry nicht verloren gehen.
+func ShimRegistryPath() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("Konfigurationsverzeichnis nicht ermittelbar: %w", err)
+	}
+	return filepath.Join(base, "proxybuild", "shims.json"), nil
+}
+
+// LoadShimRegistry liest die Registry, fehlt sie, ist sie leer
+func LoadShimRegistry() (*ShimRegistry, error) {
+	path, err := ShimRegistryPath()
+	if err != nil {
+		return nil, err
+	}
+	var registry ShimRegistry
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &registry, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &registry); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &registry, nil
+}
+
+// Save schreibt die Registry sortiert nach Verzeichnis und Name
+func (r *ShimRegistry) Save() error {
+	path, err := ShimRegistryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	sort.Slice(r.Shims, func(i, j int) bool {
+		if r.Shims[i].ShimDir != r.Shims[j].ShimDir {
+			return r.Shims[i].ShimDir < r.Shims[j].ShimDir
+		}
+		return r.Shims[i].Name < r.Shims[j].Name
+	})
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, append(data, '\n'), 0644)
+}
+
+// Find liefert den Eintrag für den Shim unter path
+func (r *ShimRegistry) Find(path string) (*InstalledShim, bool) {
+	for i := range r.Shims {
+		if samePath(r.Shims[i].Path, path) {
+			return &r.Shims[i], true
+		}
+	}
+	return nil, false
+}
+
+// Remove entfernt den Eintrag für den Shim unter path
+func (r *ShimRegistry) Remove(path string) {
+	shims := r.Shims[:0]
+	for _, shim := range r.Shims {
+		if !samePath(shim.Path, path) {
+			shims = append(shims, shim)
+		}
+	}
+	r.Shims = shims
+}
+
+// ShimDirs liefert das Standard-Shim-Verzeichnis und alle Verzeichnisse aus
+// der Registry. Ein Fehler beim Lesen der Registry wird ignoriert, das
+// Standardverzeichnis ist dann trotzdem enthalten.
+func ShimDirs() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		key := filepath.Clean(dir)
+		if runtime.GOOS == "windows" {
+			key = strings.ToLower(key)
+		}
+		if dir != "" && !seen[key] {
+			seen[key] = true
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+	}
+	if dir, err := DefaultShimDir(); err == nil {
+		add(dir)
+	}
+	if registry, err := LoadShimRegistry(); err == nil {
+		for _, shim := range registry.Shims {
+			add(shim.ShimDir)
+		}
+	}
+	return dirs
+}
+
+// ShimFileName ergänzt name unter Windows um .exe
+func ShimFileName(name string) string {
+	if runtime.GOOS == "windows" && !strings.HasSuffix(strings.ToLower(name), ".exe") {
+		return name + ".exe"
+	}
+	return name
+}
+
+// LinkExecutable legt link als Symlink auf target an. Wo Symlinks nicht
+// erlaubt sind (Windows ohne Entwicklermodus), wird ein Hardlink versucht.
+func LinkExecutable(target, link string) error {
+	if err := os.Symlink(target, link); err != nil {
+		if linkErr := os.Link(target, link); linkErr != nil {
+			return fmt.Errorf("%s: %w", link, err)
+		}
+	}
+	return nil
+}
+
+// samePath vergleicht bereinigte Pfade, unter Windows ohne Groß-/Kleinschreibung
+func samePath(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
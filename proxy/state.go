@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// Nach dieser Zeit ohne Änderung gilt eine Lock-Datei als verwaist (z.B. nach
+	// einem Absturz). Deutlich länger als jede Wartezeit auf eine Sperre.
+	staleLockAge = 10 * time.Minute
+	// So oft frischt der Inhaber einer Sperre ihre Änderungszeit auf, damit auch
+	// ein lange laufender Provider nicht als verwaist gilt
+	lockRefreshInterval = 30 * time.Second
+)
+
+// stateDir liefert das benutzereigene Verzeichnis für Cache- und Zustandsdateien
+func stateDir(sub string) (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("cache-Verzeichnis nicht ermittelbar: %w", err)
+	}
+	dir := filepath.Join(base, "proxybuild", sub)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// LockFile sperrt path prozessübergreifend über eine daneben liegende .lock-Datei.
+// Solange die Sperre gehalten wird, wird ihre Änderungszeit aufgefrischt. Die
+// zurückgegebene Funktion gibt die Sperre wieder frei.
+func LockFile(path string, timeout time.Duration) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(timeout)
+
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, _ = f.WriteString(strconv.Itoa(os.Getpid()))
+			_ = f.Close()
+			return holdLock(lockPath), nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		// Verwaiste Sperren übernehmen
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > staleLockAge && removeStaleLock(lockPath) {
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("zeitüberschreitung beim Warten auf %s", lockPath)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// holdLock frischt die Änderungszeit von lockPath auf, bis die zurückgegebene
+// Funktion die Sperre freigibt
+func holdLock(lockPath string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				_ = os.Chtimes(lockPath, now, now)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		_ = os.Remove(lockPath)
+	}
+}
+
+// removeStaleLock entfernt eine verwaiste Sperre. Nur wer die .takeover-Sperre
+// daneben anlegen kann, darf übernehmen, und prüft unter ihr die Änderungszeit
+// erneut. So entfernt kein Prozess eine Sperre, die ein anderer gerade neu
+// angelegt hat. Das Ergebnis ist false, wenn gerade ein anderer übernimmt.
+func removeStaleLock(lockPath string) bool {
+	guardPath := lockPath + ".takeover"
+	f, err := os.OpenFile(guardPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		// Ein anderer Prozess übernimmt gerade. Auch die .takeover-Sperre kann
+		// nach einem Absturz verwaisen.
+		if info, statErr := os.Stat(guardPath); statErr == nil && time.Since(info.ModTime()) > staleLockAge {
+			_ = os.Remove(guardPath)
+		}
+		return false
+	}
+	_ = f.Close()
+	defer os.Remove(guardPath)
+
+	if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLockAge {
+		_ = os.Remove(lockPath)
+	}
+	return true
+}
+
+// WriteFileAtomic schreibt eine Datei über eine temporäre Datei und Rename,
+// damit parallele Leser nie eine halb geschriebene Datei sehen
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,404 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// TemplateContext enthält die Variablen, die in Command und Args eines Hooks
+// per {{.Feld}} verwendet werden können
+type TemplateContext struct {
+	SubCommand string
+	Args       []string
+	ExitCode   int           // Nur in "after" Hooks
+	Duration   time.Duration // Nur in "after" Hooks
+	Env        map[string]string
+	Cwd        string
+	Timestamp  string            // Startzeitpunkt im RFC3339-Format
+	Vars       map[string]string // Mit capture_as gespeicherte Ausgaben früherer Hooks
+	AssetDir   string            // Verzeichnis mit den entpackten embed_dirs
+}
+
+// templateFuncs stehen in allen Templates zur Verfügung. index ersetzt die
+// eingebaute Funktion: Ein fehlendes Argument ergibt "" statt eines Fehlers.
+var templateFuncs = template.FuncMap{
+	"join":  templateJoin,
+	"quote": templateQuote,
+	"raw":   templateRaw,
+	"index": templateIndex,
+}
+
+// Felder, die vor der Ausführung des Basis-Commands bereits bekannt sind
+var beforeTemplateFields = []string{"SubCommand", "Args", "Env", "Cwd", "Timestamp", "Vars", "AssetDir"}
+
+// Felder, die erst nach der Ausführung des Basis-Commands bekannt sind
+var afterTemplateFields = []string{"ExitCode", "Duration"}
+
+func newTemplateContext(subCommand string, args []string, start time.Time) *TemplateContext {
+	env := make(map[string]string)
+	for _, kV := range os.Environ() {
+		if key, value, ok := strings.Cut(kV, "="); ok {
+			env[key] = value
+		}
+	}
+	cwd, _ := os.Getwd()
+
+	return &TemplateContext{
+		SubCommand: subCommand,
+		Args:       args,
+		Env:        env,
+		Cwd:        cwd,
+		Timestamp:  start.Format(time.RFC3339),
+	}
+}
+
+// Validate prüft die Konfiguration beim Laden, damit Fehler nicht erst zur Laufzeit auffallen
+func (c *Config) Validate() error {
+	var errs []error
+
+	subCommands := make([]string, 0, len(c.Hooks))
+	for subCommand := range c.Hooks {
+		subCommands = append(subCommands, subCommand)
+	}
+	sort.Strings(subCommands)
+
+	// Variablen, die vor dem Basis-Command in irgendeinem Sub-Command gesetzt werden können
+	baseVars := make(map[string]bool)
+
+	// Secrets und Credentials stehen von Anfang an zur Verfügung
+	credentialVars := make(map[string]bool)
+	if c.Secrets != nil {
+		for _, name := range c.Secrets.Names {
+			credentialVars[name] = true
+			baseVars[name] = true
+		}
+	}
+	for i, provider := range c.Credentials {
+		if err := provider.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("credentials[%d]: %w", i, err))
+		}
+		for key := range provider.Env {
+			credentialVars[key] = true
+			baseVars[key] = true
+		}
+	}
+
+	if c.Package != nil {
+		if err := c.Package.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("package: %w", err))
+		}
+	}
+	if err := validateBuildHooks(c.BuildHooks); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateSmokeTests(c.SmokeTests, c.BaseCommand, c.BaseCommandPath != "" || c.BaseCommandSHA256 != ""); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.validateAlternatives(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.validatePin(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.validateEmbedDirs(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Update != nil {
+		if err := c.Update.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("update: %w", err))
+		}
+	}
+
+	for _, subCommand := range subCommands {
+		hooks := c.Hooks[subCommand]
+		vars := make(map[string]bool)
+		for key := range credentialVars {
+			vars[key] = true
+		}
+
+		// Erst alle "before" Hooks in Ausführungsreihenfolge, dann alle "after" Hooks
+		for _, when := range []string{"before", "after"} {
+			allowed := append([]string{}, beforeTemplateFields...)
+			if when == "after" {
+				allowed = append(allowed, afterTemplateFields...)
+			}
+
+			for i, hook := range hooks {
+				if hook.When != when {
+					continue
+				}
+				location := fmt.Sprintf("hooks.%s[%d]", subCommand, i)
+
+				fields := append([]string{hook.Command}, hook.Args...)
+				for j, field := range fields {
+					if err := validateTemplate(field, allowed, vars); err != nil {
+						fieldLocation := "command"
+						if j > 0 {
+							fieldLocation = fmt.Sprintf("args[%d]", j-1)
+						}
+						errs = append(errs, fmt.Errorf("%s.%s: %w", location, fieldLocation, err))
+					}
+				}
+
+				if hook.CaptureAs != "" {
+					if !captureNamePattern.MatchString(hook.CaptureAs) {
+						errs = append(errs, fmt.Errorf("%s.capture_as: ungültiger Name %q", location, hook.CaptureAs))
+					}
+					vars[hook.CaptureAs] = true
+					if when == "before" {
+						baseVars[hook.CaptureAs] = true
+					}
+				}
+			}
+		}
+	}
+
+	if err := validateTemplate(c.BaseCommand, beforeTemplateFields, baseVars); err != nil {
+		errs = append(errs, fmt.Errorf("base_command: %w", err))
+	}
+	// Die erste Alternative ist BaseCommand
+	for i, alternative := range c.BaseCommandAlternatives {
+		if i == 0 {
+			continue
+		}
+		if err := validateTemplate(alternative, beforeTemplateFields, baseVars); err != nil {
+			errs = append(errs, fmt.Errorf("base_command[%d]: %w", i, err))
+		}
+	}
+	for key, value := range c.EnvVars {
+		if err := validateTemplate(value, beforeTemplateFields, baseVars); err != nil {
+			errs = append(errs, fmt.Errorf("env_vars.%s: %w", key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateTemplate parst ein Template und prüft, ob nur bekannte Felder und
+// vorher gespeicherte Variablen verwendet werden
+func validateTemplate(text string, allowed []string, vars map[string]bool) error {
+	if !strings.Contains(text, "{{") {
+		return nil
+	}
+
+	tmpl, err := template.New("hook").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("ungültiges Template: %w", err)
+	}
+
+	var problems []error
+	walkTemplateFields(tmpl.Tree.Root, func(ident []string) {
+		for _, name := range allowed {
+			if ident[0] != name {
+				continue
+			}
+			if name == "Vars" && len(ident) > 1 && !vars[ident[1]] {
+				problems = append(problems, fmt.Errorf("variable .Vars.%s wird vorher von keinem Hook mit capture_as gesetzt", ident[1]))
+			}
+			return
+		}
+		problems = append(problems, fmt.Errorf("unbekanntes Template-Feld .%s (verfügbar: .%s)", ident[0], strings.Join(allowed, ", .")))
+	})
+
+	if len(problems) > 0 {
+		return problems[0]
+	}
+	return nil
+}
+
+// walkTemplateFields ruft visit für jeden Feldzugriff auf, der direkt auf dem Kontext
+// ausgewertet wird. In range/with ändert sich der Punkt, dort wird nicht geprüft.
+func walkTemplateFields(node parse.Node, visit func(ident []string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkTemplateFields(child, visit)
+		}
+	case *parse.ActionNode:
+		walkTemplateFields(n.Pipe, visit)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walkTemplateFields(cmd, visit)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkTemplateFields(arg, visit)
+		}
+	case *parse.FieldNode:
+		visit(n.Ident)
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			visit(n.Ident[1:])
+		}
+	case *parse.ChainNode:
+		walkTemplateFields(n.Node, visit)
+	case *parse.IfNode:
+		walkTemplateFields(n.Pipe, visit)
+		walkTemplateFields(n.List, visit)
+		walkTemplateFields(n.ElseList, visit)
+	case *parse.RangeNode:
+		walkTemplateFields(n.Pipe, visit)
+		walkTemplateFields(n.ElseList, visit)
+	case *parse.WithNode:
+		walkTemplateFields(n.Pipe, visit)
+		walkTemplateFields(n.ElseList, visit)
+	case *parse.TemplateNode:
+		walkTemplateFields(n.Pipe, visit)
+	}
+}
+
+// renderTemplate expandiert ein Template mit dem aktuellen Kontext. Für den
+// shell-Executor wird jede Ausgabe gequotet, damit Args, Env und Vars keine
+// Shell-Syntax einschleusen. Listen werden mit Leerzeichen verbunden.
+func renderTemplate(text string, ctx *TemplateContext, executor Executor) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("hook").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	escape := "raw"
+	if executor == "" || executor == ExecutorShell {
+		escape = "quote"
+	}
+	escapeTemplateActions(tmpl.Tree.Root, escape)
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, ctx); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// escapeTemplateActions hängt an jede Ausgabe-Aktion die Funktion escape an,
+// wie html/template. Endet die Aktion schon mit quote oder raw, bleibt sie.
+func escapeTemplateActions(node parse.Node, escape string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			escapeTemplateActions(child, escape)
+		}
+	case *parse.ActionNode:
+		// Zuweisungen wie {{$x := .Args}} geben nichts aus
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		last := n.Pipe.Cmds[len(n.Pipe.Cmds)-1]
+		if ident, ok := last.Args[0].(*parse.IdentifierNode); ok && (ident.Ident == "quote" || ident.Ident == "raw") {
+			return
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(escape).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		escapeTemplateActions(n.List, escape)
+		escapeTemplateActions(n.ElseList, escape)
+	case *parse.RangeNode:
+		escapeTemplateActions(n.List, escape)
+		escapeTemplateActions(n.ElseList, escape)
+	case *parse.WithNode:
+		escapeTemplateActions(n.List, escape)
+		escapeTemplateActions(n.ElseList, escape)
+	}
+}
+
+// templateJoin verbindet eine Liste mit sep: {{join "," .Args}} oder {{.Args | join " "}}
+func templateJoin(sep string, list []string) string {
+	return strings.Join(list, sep)
+}
+
+// templateQuote quotet ein Argument oder jedes Element einer Liste für die
+// Shell. Beim shell-Executor hängt renderTemplate es an jede Ausgabe an.
+func templateQuote(value any) (string, error) {
+	words := []string{templateRaw(value)}
+	if list, ok := value.([]string); ok {
+		words = list
+	}
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		var err error
+		if quoted[i], err = QuoteShellWord(word, runtime.GOOS); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+// templateRaw gibt einen Wert ungequotet aus, Listen mit Leerzeichen verbunden:
+// {{raw .Env.FLAGS}} übernimmt beim shell-Executor bewusst Shell-Syntax
+func templateRaw(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, " ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// templateIndex arbeitet wie das eingebaute index, liefert aber für einen
+// Index außerhalb der Liste oder einen fehlenden Schlüssel "" statt eines Fehlers
+func templateIndex(item any, keys ...any) (any, error) {
+	v := reflect.ValueOf(item)
+	for _, key := range keys {
+		for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return "", nil
+			}
+			v = v.Elem()
+		}
+		k := reflect.ValueOf(key)
+		switch v.Kind() {
+		case reflect.Slice, reflect.Array:
+			var i int64
+			switch k.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				i = k.Int()
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				i = int64(k.Uint())
+			default:
+				return nil, fmt.Errorf("index: ungültiger Index %v für eine Liste", key)
+			}
+			if i < 0 || i >= int64(v.Len()) {
+				return "", nil
+			}
+			v = v.Index(int(i))
+		case reflect.Map:
+			if !k.IsValid() || !k.Type().AssignableTo(v.Type().Key()) {
+				return nil, fmt.Errorf("index: ungültiger Schlüssel %v", key)
+			}
+			if v = v.MapIndex(k); !v.IsValid() {
+				return "", nil
+			}
+		case reflect.Invalid:
+			return "", nil
+		default:
+			return nil, fmt.Errorf("index: %s lässt sich nicht indizieren", v.Type())
+		}
+	}
+	return v.Interface(), nil
+}
@@ -0,0 +1,450 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Ein Update-Feed ist ein Verzeichnis (lokal, Netzlaufwerk oder per http(s)):
+//
+//	<channel>.json          UpdateFeed mit einem Eintrag je Ziel
+//	<channel>.json.minisig  Signatur mit dem Schlüssel aus update.public_key
+//	<channel>/<os>-<arch>/… die Executables
+//
+// ProxyBuild -publish <feed> legt Executables dort ab und signiert den Feed.
+
+// DefaultUpdateChannel ist der Kanal ohne update.channel
+const DefaultUpdateChannel = "stable"
+
+// PreviousSuffix kennzeichnet die beim Update aufbewahrte vorherige Version
+const PreviousSuffix = ".previous"
+
+// Grenzen für Downloads aus dem Feed
+const (
+	updateTimeout      = 60 * time.Second
+	updateMaxFeedSize  = 1 << 20
+	updateMaxFileSize  = 512 << 20
+	minUpdateInterval  = time.Minute
+	autoUpdateLockWait = 100 * time.Millisecond
+)
+
+var updateChannelPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// UpdateConfig aktiviert --proxy-update und die optionale regelmäßige Prüfung
+type UpdateConfig struct {
+	Feed          string `json:"feed"`                     // URL oder Verzeichnis des Feeds
+	Channel       string `json:"channel,omitempty"`        // Standard: stable
+	PublicKey     string `json:"public_key"`               // minisign-Schlüssel, mit dem der Feed signiert ist
+	CheckInterval string `json:"check_interval,omitempty"` // z.B. "24h", leer: nur --proxy-update
+}
+
+// UpdateFeed ist der signierte Inhalt von <channel>.json
+type UpdateFeed struct {
+	Name      string           `json:"name"`
+	Channel   string           `json:"channel"`
+	Sequence  int64            `json:"sequence"` // Wird von jedem -publish erhöht
+	Artifacts []UpdateArtifact `json:"artifacts"`
+}
+
+// UpdateArtifact ist das Executable für ein Ziel
+type UpdateArtifact struct {
+	Target       string `json:"target"` // GOOS/GOARCH
+	File         string `json:"file"`   // relativ zum Feed, mit "/" getrennt
+	SHA256       string `json:"sha256"`
+	Size         int64  `json:"size"`
+	BuildTime    string `json:"build_time"` // RFC 3339, ältere Builds werden nicht installiert
+	ConfigSHA256 string `json:"config_sha256,omitempty"`
+}
+
+// ChannelName liefert den Kanal, ohne Angabe DefaultUpdateChannel
+func (u *UpdateConfig) ChannelName() string {
+	if u.Channel == "" {
+		return DefaultUpdateChannel
+	}
+	return u.Channel
+}
+
+// FeedFile ist der Name der Feed-Datei des Kanals
+func (u *UpdateConfig) FeedFile() string {
+	return u.ChannelName() + ".json"
+}
+
+// Interval liefert den Abstand der automatischen Prüfung, 0 ohne check_interval
+func (u *UpdateConfig) Interval() time.Duration {
+	interval, _ := time.ParseDuration(u.CheckInterval)
+	return interval
+}
+
+func (u *UpdateConfig) validate() error {
+	var errs []error
+	if u.Feed == "" {
+		errs = append(errs, errors.New("feed fehlt"))
+	}
+	if !updateChannelPattern.MatchString(u.ChannelName()) {
+		errs = append(errs, fmt.Errorf("channel %q: nur Kleinbuchstaben, Ziffern, '.', '_' und '-' erlaubt", u.Channel))
+	}
+	if _, err := ParseMinisignPublicKey([]byte(u.PublicKey)); err != nil {
+		errs = append(errs, fmt.Errorf("public_key: %w", err))
+	}
+	if u.CheckInterval != "" {
+		interval, err := time.ParseDuration(u.CheckInterval)
+		if err != nil || interval < minUpdateInterval {
+			errs = append(errs, fmt.Errorf("check_interval %q: Dauer von mindestens %s erwartet, z.B. \"24h\"", u.CheckInterval, minUpdateInterval))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Artifact liefert den Eintrag für target
+func (f *UpdateFeed) Artifact(target string) (*UpdateArtifact, bool) {
+	for i := range f.Artifacts {
+		if f.Artifacts[i].Target == target {
+			return &f.Artifacts[i], true
+		}
+	}
+	return nil, false
+}
+
+// FetchUpdateFeed lädt den Feed des Kanals und prüft seine Signatur
+func FetchUpdateFeed(u *UpdateConfig) (*UpdateFeed, error) {
+	pub, err := ParseMinisignPublicKey([]byte(u.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	data, err := readUpdateFile(u.Feed, u.FeedFile(), updateMaxFeedSize)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := readUpdateFile(u.Feed, u.FeedFile()+".minisig", updateMaxFeedSize)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pub.Verify(data, sig); err != nil {
+		return nil, fmt.Errorf("%s: %w", u.FeedFile(), err)
+	}
+	var feed UpdateFeed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("%s: %w", u.FeedFile(), err)
+	}
+	// Ein signierter Feed eines anderen Kanals darf nicht untergeschoben werden
+	if feed.Channel != u.ChannelName() {
+		return nil, fmt.Errorf("%s gehört zum Kanal %q, erwartet %q", u.FeedFile(), feed.Channel, u.ChannelName())
+	}
+	return &feed, nil
+}
+
+// readUpdateFile liest name relativ zum Feed, per http(s) oder aus dem Dateisystem
+func readUpdateFile(feed, name string, limit int64) ([]byte, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return nil, fmt.Errorf("ungültiger Pfad %q im Feed", name)
+	}
+	var r io.Reader
+	if strings.HasPrefix(feed, "http://") || strings.HasPrefix(feed, "https://") {
+		url := strings.TrimSuffix(feed, "/") + "/" + clean
+		resp, err := (&http.Client{Timeout: updateTimeout}).Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: %s", url, resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(filepath.Join(feed, filepath.FromSlash(clean)))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s ist größer als %d Bytes", name, limit)
+	}
+	return data, nil
+}
+
+// UpdateResult beschreibt das Ergebnis von Update
+type UpdateResult struct {
+	Updated  bool
+	Artifact *UpdateArtifact
+	Reason   string // Warum nicht aktualisiert wurde
+}
+
+// Update prüft den Feed und ersetzt das laufende Executable, wenn dort ein
+// anderer, nicht älterer Build für diese Plattform liegt. meta sind die
+// Build-Metadaten des laufenden Proxys. Die vorherige Version bleibt als
+// <executable>.previous.
+func Update(u *UpdateConfig, meta *BuildMetadata) (*UpdateResult, error) {
+	exe, err := currentExecutable()
+	if err != nil {
+		return nil, err
+	}
+	state, statePath, err := loadUpdateState(exe)
+	if err != nil {
+		return nil, err
+	}
+	result, err := update(u, meta, state, "")
+	if saveErr := saveUpdateState(statePath, state); err == nil {
+		err = saveErr
+	}
+	return result, err
+}
+
+// update installiert den Build aus dem Feed, außer seine Prüfsumme ist skip.
+// Die Sequenz des Feeds wird in state vermerkt, der Aufrufer speichert state.
+func update(u *UpdateConfig, meta *BuildMetadata, state *updateState, skip string) (*UpdateResult, error) {
+	exe, err := currentExecutable()
+	if err != nil {
+		return nil, err
+	}
+	feed, err := FetchUpdateFeed(u)
+	if err != nil {
+		return nil, err
+	}
+	// Derselbe Schlüssel kann Feeds mehrerer Proxies signieren
+	name := strings.TrimSuffix(filepath.Base(exe), ".exe")
+	if meta != nil && meta.Name != "" {
+		name = meta.Name
+	}
+	if feed.Name != name {
+		return nil, fmt.Errorf("der Feed gehört zu %s, nicht zu %s", feed.Name, name)
+	}
+	// Schutz vor dem Zurückspielen eines älteren, gültig signierten Feeds
+	if feed.Sequence < state.Sequence {
+		return &UpdateResult{Reason: fmt.Sprintf("der Feed ist älter als der zuletzt gesehene (Sequenz %d, zuletzt %d)", feed.Sequence, state.Sequence)}, nil
+	}
+	state.Sequence = feed.Sequence
+	target := runtime.GOOS + "/" + runtime.GOARCH
+	artifact, ok := feed.Artifact(target)
+	if !ok {
+		return nil, fmt.Errorf("Kanal %s enthält kein Executable für %s", u.ChannelName(), target)
+	}
+
+	current, err := os.ReadFile(exe)
+	if err != nil {
+		return nil, err
+	}
+	if sha256Hex(current) == strings.ToLower(artifact.SHA256) {
+		return &UpdateResult{Artifact: artifact, Reason: "bereits aktuell"}, nil
+	}
+	if skip != "" && strings.EqualFold(artifact.SHA256, skip) {
+		return &UpdateResult{Artifact: artifact, Reason: "nach einem Rollback übersprungen"}, nil
+	}
+	// Auch ein neuer Feed darf keinen älteren Build als den laufenden installieren
+	if isOlderBuild(artifact.BuildTime, meta) {
+		return &UpdateResult{Artifact: artifact, Reason: fmt.Sprintf("der Feed enthält einen älteren Build (%s)", artifact.BuildTime)}, nil
+	}
+
+	data, err := readUpdateFile(u.Feed, artifact.File, updateMaxFileSize)
+	if err != nil {
+		return nil, err
+	}
+	if actual := sha256Hex(data); actual != strings.ToLower(artifact.SHA256) {
+		return nil, fmt.Errorf("%s: Prüfsumme stimmt nicht: erwartet sha256:%s, erhalten sha256:%s", artifact.File, artifact.SHA256, actual)
+	}
+	if err := replaceExecutable(exe, data); err != nil {
+		return nil, err
+	}
+	return &UpdateResult{Updated: true, Artifact: artifact}, nil
+}
+
+// Rollback stellt die beim letzten Update aufbewahrte Version wieder her. Die
+// ersetzte Version wird zur neuen vorherigen Version und von der automatischen
+// Prüfung nicht erneut installiert.
+func Rollback() (string, error) {
+	exe, err := currentExecutable()
+	if err != nil {
+		return "", err
+	}
+	previous, err := os.ReadFile(exe + PreviousSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("keine vorherige Version vorhanden (%s)", exe+PreviousSuffix)
+	}
+	if err != nil {
+		return "", err
+	}
+	current, err := os.ReadFile(exe)
+	if err != nil {
+		return "", err
+	}
+	if err := replaceExecutable(exe, previous); err != nil {
+		return "", err
+	}
+	state, statePath, err := loadUpdateState(exe)
+	if err == nil {
+		state.SkipSHA256 = sha256Hex(current)
+		err = saveUpdateState(statePath, state)
+	}
+	return exe, err
+}
+
+// updateState merkt sich je Executable die letzte automatische Prüfung und
+// die höchste gesehene Sequenz des Feeds
+type updateState struct {
+	LastCheck  time.Time `json:"last_check"`
+	Sequence   int64     `json:"sequence,omitempty"`
+	SkipSHA256 string    `json:"skip_sha256,omitempty"` // Nach einem Rollback nicht automatisch installieren
+}
+
+// AutoUpdate prüft höchstens einmal je check_interval auf ein Update. Fehler
+// werden nur gemeldet, der Aufruf des Proxys ist davon nicht betroffen.
+func AutoUpdate(w io.Writer, u *UpdateConfig, meta *BuildMetadata) {
+	interval := u.Interval()
+	if interval <= 0 {
+		return
+	}
+	exe, err := currentExecutable()
+	if err != nil {
+		return
+	}
+	state, statePath, err := loadUpdateState(exe)
+	if err != nil || time.Since(state.LastCheck) < interval {
+		return
+	}
+	// Läuft bereits eine Prüfung in einem anderen Prozess, wird nicht gewartet
+	unlock, err := LockFile(statePath, autoUpdateLockWait)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	state.LastCheck = time.Now()
+	if err := saveUpdateState(statePath, state); err != nil {
+		return
+	}
+
+	result, err := update(u, meta, state, state.SkipSHA256)
+	if saveErr := saveUpdateState(statePath, state); err == nil {
+		err = saveErr
+	}
+	if err != nil {
+		fmt.Fprintf(w, "Hinweis: Update-Prüfung fehlgeschlagen: %v\n", err)
+		return
+	}
+	if result.Updated {
+		fmt.Fprintf(w, "Hinweis: %s wurde aktualisiert (Build %s), gilt ab dem nächsten Aufruf\n", filepath.Base(exe), result.Artifact.BuildTime)
+	}
+}
+
+// loadUpdateState liest den Zustand für exe, fehlt er, ist er leer
+func loadUpdateState(exe string) (*updateState, string, error) {
+	dir, err := stateDir("update")
+	if err != nil {
+		return nil, "", err
+	}
+	statePath := filepath.Join(dir, sha256Hex([]byte(exe))[:16]+".json")
+	var state updateState
+	data, err := os.ReadFile(statePath)
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, "", err
+	}
+	return &state, statePath, nil
+}
+
+func saveUpdateState(statePath string, state *updateState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(statePath, data, 0600)
+}
+
+// isOlderBuild meldet, ob buildTime vor dem Build des laufenden Proxys liegt.
+// Gleich alte Builds, etwa mit demselben SOURCE_DATE_EPOCH, gelten nicht als
+// älter. Ohne Metadaten ist kein Build älter.
+func isOlderBuild(buildTime string, meta *BuildMetadata) bool {
+	candidate, err := time.Parse(time.RFC3339, buildTime)
+	if err != nil {
+		return true
+	}
+	if meta == nil || meta.BuildTime == "" {
+		return false
+	}
+	current, err := time.Parse(time.RFC3339, meta.BuildTime)
+	return err == nil && candidate.Before(current)
+}
+
+// currentExecutable liefert den Pfad des laufenden Executables ohne Symlinks
+func currentExecutable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// replaceExecutable ersetzt exe atomar durch data und bewahrt die bisherige
+// Version als exe+PreviousSuffix auf. Unter Windows lässt sich ein laufendes
+// Executable nicht überschreiben, aber umbenennen.
+func replaceExecutable(exe string, data []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".update-*")
+	if err != nil {
+		return fmt.Errorf("Verzeichnis von %s ist nicht beschreibbar: %w", exe, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	previous := exe + PreviousSuffix
+	if err := os.Remove(previous); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		if err := os.Rename(exe, previous); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), exe); err != nil {
+			os.Rename(previous, exe)
+			return err
+		}
+		return nil
+	}
+	if err := os.Link(exe, previous); err != nil {
+		current, err := os.ReadFile(exe)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(previous, current, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), exe)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
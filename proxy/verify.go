@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Richtlinien, wenn die Signatur der eingebetteten Konfiguration nicht stimmt
+const (
+	PolicyRefuse  = "refuse"   // Nicht ausführen
+	PolicyNoHooks = "no-hooks" // Nur das in den Runner kompilierte Basis-Command ohne Hooks, env_vars und Credentials ausführen
+)
+
+// ConfigSignatureSection enthält die Signatur über alle übrigen Abschnitte des Bundles
+const ConfigSignatureSection = "config.sig"
+
+// ValidatePolicy prüft den Namen einer Richtlinie
+func ValidatePolicy(policy string) error {
+	switch policy {
+	case PolicyRefuse, PolicyNoHooks:
+		return nil
+	default:
+		return fmt.Errorf("unbekannte Richtlinie %q (refuse oder no-hooks)", policy)
+	}
+}
+
+// signedPayload serialisiert alle Abschnitte außer der Signatur selbst
+func signedPayload(sections map[string][]byte) []byte {
+	unsigned := make(map[string][]byte, len(sections))
+	for name, data := range sections {
+		if name != ConfigSignatureSection {
+			unsigned[name] = data
+		}
+	}
+	return EncodeBundle(unsigned)
+}
+
+// SignBundle fügt den Abschnitten eine Signatur über ihren Inhalt hinzu
+func SignBundle(sections map[string][]byte, key ed25519.PrivateKey) {
+	sections[ConfigSignatureSection] = SignMinisign(key, signedPayload(sections), "config sha256:"+BundleConfigSHA256(sections))
+}
+
+// VerifyBundle prüft die Signatur der Abschnitte mit dem öffentlichen Schlüssel
+// (base64 im minisign-Format)
+func VerifyBundle(sections map[string][]byte, publicKey string) error {
+	key, err := ParseMinisignPublicKey([]byte(publicKey))
+	if err != nil {
+		return err
+	}
+	sig, ok := sections[ConfigSignatureSection]
+	if !ok {
+		return errors.New("die eingebettete Konfiguration ist nicht signiert")
+	}
+	if _, err := key.Verify(signedPayload(sections), sig); err != nil {
+		return fmt.Errorf("eingebettete Konfiguration: %w", err)
+	}
+	return nil
+}
+
+// LoadTrustedConfig lädt die Konfiguration aus dem Bundle. Ist ein öffentlicher
+// Schlüssel eingebaut, muss die Signatur stimmen, sonst entscheidet policy.
+// baseCommands sind die mit TrustedBaseCommands in den Runner kompilierten
+// Basis-Commands für die Richtlinie no-hooks.
+func LoadTrustedConfig(sections map[string][]byte, publicKey, policy, baseCommands string) (*Config, error) {
+	return LoadTrustedConfigFor(sections, "", publicKey, policy, baseCommands)
+}
+
+// LoadTrustedConfigFor lädt wie LoadTrustedConfig die Konfiguration des Befehls
+// name aus einem Multi-Call-Bundle. Die Signatur deckt alle Konfigurationen ab.
+func LoadTrustedConfigFor(sections map[string][]byte, name, publicKey, policy, baseCommands string) (*Config, error) {
+	return trustConfig(sections, name, publicKey, policy, baseCommands, func() (*Config, error) {
+		return LoadBundleConfigFor(sections, name)
+	})
+}
+
+// TrustGeneratedConfig prüft die Signatur wie LoadTrustedConfigFor, verwendet aber
+// die beim Build als Go-Code generierte Konfiguration statt des JSON im Bundle
+func TrustGeneratedConfig(config *Config, sections map[string][]byte, name, publicKey, policy, baseCommands string) (*Config, error) {
+	return trustConfig(sections, name, publicKey, policy, baseCommands, func() (*Config, error) {
+		return config, nil
+	})
+}
+
+func trustConfig(sections map[string][]byte, name, publicKey, policy, baseCommands string, load func() (*Config, error)) (*Config, error) {
+	// Die embed_dirs gehören zu allen Konfigurationen des Bundles
+	load = withAssets(sections, load)
+	if publicKey == "" {
+		return load()
+	}
+
+	verifyErr := VerifyBundle(sections, publicKey)
+	if verifyErr == nil {
+		return load()
+	}
+	if policy != PolicyNoHooks {
+		return nil, fmt.Errorf("%w, Ausführung verweigert", verifyErr)
+	}
+
+	// Aus dem manipulierten Bundle wird nichts übernommen, auch nicht das Basis-Command
+	config, err := trustedBaseCommand(baseCommands, name)
+	if err != nil {
+		return nil, fmt.Errorf("%v, Ausführung verweigert: %w", verifyErr, err)
+	}
+	fmt.Fprintf(os.Stderr, "Warnung: %v, führe das eingebaute Basis-Command %s ohne Hooks aus\n", verifyErr, config.BaseCommand)
+	return config, nil
+}
+
+// TrustedBaseCommands liefert die Basis-Commands aller Konfigurationen des
+// Bundles ohne Hooks, kodiert für -ldflags "-X". Mit der Richtlinie no-hooks
+// führt der Runner bei ungültiger Signatur nur diese aus.
+func TrustedBaseCommands(sections map[string][]byte) (string, error) {
+	names := MultiCallNames(sections)
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	trusted := make(map[string]*Config, len(names))
+	for _, name := range names {
+		config, err := LoadBundleConfigFor(sections, name)
+		if err != nil {
+			return "", err
+		}
+		trusted[name] = config.withoutHooks()
+	}
+	data, err := json.Marshal(trusted)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// trustedBaseCommand liefert das eingebaute Basis-Command des Befehls name
+func trustedBaseCommand(baseCommands, name string) (*Config, error) {
+	if baseCommands == "" {
+		return nil, errors.New("im Runner ist kein Basis-Command eingebaut")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(baseCommands)
+	if err != nil {
+		return nil, fmt.Errorf("eingebaute Basis-Commands: %w", err)
+	}
+	var trusted map[string]*Config
+	if err := json.Unmarshal(data, &trusted); err != nil {
+		return nil, fmt.Errorf("eingebaute Basis-Commands: %w", err)
+	}
+	config, ok := trusted[name]
+	if !ok || config == nil {
+		return nil, fmt.Errorf("im Runner ist kein Basis-Command für %q eingebaut", name)
+	}
+	return config, nil
+}
+
+// withAssets ergänzt die von load gelieferte Konfiguration um das Archiv der embed_dirs
+func withAssets(sections map[string][]byte, load func() (*Config, error)) func() (*Config, error) {
+	return func() (*Config, error) {
+		config, err := load()
+		if err == nil {
+			config.Assets = sections[AssetsSection]
+		}
+		return config, err
+	}
+}
+
+// withoutHooks liefert eine Konfiguration, die nur das Basis-Command ausführt
+func (c *Config) withoutHooks() *Config {
+	return &Config{
+		BaseCommand:             c.BaseCommand,
+		BaseCommandAlternatives: c.BaseCommandAlternatives,
+		BaseCommandProbe:        c.BaseCommandProbe,
+		Executor:                c.Executor,
+		BaseCommandPath:         c.BaseCommandPath,
+		BaseCommandSHA256:       c.BaseCommandSHA256,
+	}
+}
+
+// WriteVerifyReport gibt für --proxy-verify aus, welche Konfiguration eingebettet
+// ist und ob ihre Signatur stimmt
+func WriteVerifyReport(w io.Writer, sections map[string][]byte, publicKey, policy string) error {
+	if exe, err := os.Executable(); err == nil {
+		fmt.Fprintf(w, "Executable:    %s\n", exe)
+	}
+	fmt.Fprintf(w, "Konfiguration: sha256:%s\n", BundleConfigSHA256(sections))
+	if names := MultiCallNames(sections); len(names) > 0 {
+		fmt.Fprintf(w, "Befehle:       %s\n", strings.Join(names, ", "))
+	}
+
+	if publicKey == "" {
+		fmt.Fprintln(w, "Signatur:      nicht geprüft (ohne -sign-key gebaut)")
+		return nil
+	}
+	key, err := ParseMinisignPublicKey([]byte(publicKey))
+	if err != nil {
+		return err
+	}
+	if policy != PolicyNoHooks {
+		policy = PolicyRefuse
+	}
+	fmt.Fprintf(w, "Schlüssel:     %s\n", key.ID())
+	fmt.Fprintf(w, "Richtlinie:    %s\n", policy)
+
+	if err := VerifyBundle(sections, publicKey); err != nil {
+		fmt.Fprintf(w, "Signatur:      UNGÜLTIG (%v)\n", err)
+		return err
+	}
+	fmt.Fprintln(w, "Signatur:      gültig")
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"ProxyBuild/proxy"
+)
+
+// -publish <dir> legt die gebauten Executables im Update-Feed der update-Section
+// ab und signiert <channel>.json neu. Einträge anderer Ziele bleiben erhalten,
+// so können Builds für verschiedene Plattformen nacheinander veröffentlicht werden.
+
+// checkMultiCallUpdate prüft, dass die update-Section in allen Konfigurationen
+// eines Multi-Call-Executables gleich ist, es gibt nur ein Executable zu ersetzen
+func checkMultiCallUpdate(commands []multiCallCommand) error {
+	for _, cmd := range commands[1:] {
+		if !reflect.DeepEqual(cmd.Config.Update, commands[0].Config.Update) {
+			return fmt.Errorf("update muss in allen Konfigurationen gleich sein, %s weicht von %s ab", cmd.ConfigFile, commands[0].ConfigFile)
+		}
+	}
+	return nil
+}
+
+// checkPublish prüft vor dem Build, ob mit -publish veröffentlicht werden kann
+func checkPublish(opts BuildOptions, input *buildInput, signer ed25519.PrivateKey) error {
+	if opts.Publish == "" {
+		return nil
+	}
+	update := input.Config.Update
+	if update == nil {
+		return errors.New("-publish braucht eine update-Section in der Konfiguration")
+	}
+	if signer == nil {
+		return errors.New("-publish braucht -sign-key, damit der Feed signiert werden kann")
+	}
+	expected, err := proxy.ParseMinisignPublicKey([]byte(update.PublicKey))
+	if err != nil {
+		return fmt.Errorf("update.public_key: %w", err)
+	}
+	if actual := proxy.NewMinisignPublicKey(signer.Public().(ed25519.PublicKey)); actual.String() != expected.String() {
+		return fmt.Errorf("der Schlüssel aus -sign-key (%s) passt nicht zu update.public_key (%s)", actual.ID(), expected.ID())
+	}
+	return nil
+}
+
+// publishUpdate kopiert die Executables (Ziel → Pfad) in den Feed und
+// schreibt den signierten Feed des Kanals
+func publishUpdate(opts BuildOptions, input *buildInput, binaries map[string]string) error {
+	name := input.Metadata.Name
+	update := input.Config.Update
+	channel := update.ChannelName()
+	feedPath := filepath.Join(opts.Publish, update.FeedFile())
+
+	feed := proxy.UpdateFeed{Name: name, Channel: channel}
+	if data, err := os.ReadFile(feedPath); err == nil {
+		if err := json.Unmarshal(data, &feed); err != nil {
+			return fmt.Errorf("%s: %w", feedPath, err)
+		}
+		if feed.Name != name {
+			return fmt.Errorf("%s gehört zu %s, nicht zu %s", feedPath, feed.Name, name)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	targets := make([]string, 0, len(binaries))
+	for target := range binaries {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		data, err := os.ReadFile(binaries[target])
+		if err != nil {
+			return err
+		}
+		rel := channel + "/" + strings.ReplaceAll(target, "/", "-") + "/" + filepath.Base(binaries[target])
+		dest := filepath.Join(opts.Publish, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		// Proxies laden das Executable womöglich gerade herunter
+		if err := proxy.WriteFileAtomic(dest, data, 0755); err != nil {
+			return err
+		}
+		sum, err := fileSHA256(dest)
+		if err != nil {
+			return err
+		}
+		artifact := proxy.UpdateArtifact{
+			Target:       target,
+			File:         rel,
+			SHA256:       sum,
+			Size:         int64(len(data)),
+			BuildTime:    input.Metadata.BuildTime,
+			ConfigSHA256: input.Metadata.ConfigSHA256,
+		}
+		if existing, ok := feed.Artifact(target); ok {
+			*existing = artifact
+		} else {
+			feed.Artifacts = append(feed.Artifacts, artifact)
+		}
+	}
+	sort.Slice(feed.Artifacts, func(i, j int) bool { return feed.Artifacts[i].Target < feed.Artifacts[j].Target })
+	// Proxies lehnen Feeds mit kleinerer Sequenz als der zuletzt gesehenen ab
+	feed.Sequence++
+
+	data, err := json.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if err := writeSignedFeed(feedPath, data, signData(input.signer, data, filepath.Base(feedPath))); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Update-Feed aktualisiert: %s (%s)\n", feedPath, strings.Join(targets, ", "))
+	return nil
+}
+
+// writeSignedFeed schreibt Feed und Signatur erst in temporäre Dateien und
+// benennt sie dann kurz nacheinander um, die Signatur zuerst. Proxies sehen so
+// nie einen halb geschriebenen Feed und höchstens kurz eine Signatur, die noch
+// nicht zum Feed passt, was sie als Fehler melden statt ein Update einzuspielen.
+func writeSignedFeed(feedPath string, feed, signature []byte) error {
+	sigPath := feedPath + signatureSuffix
+	sigTmp, err := writeTempFile(sigPath, signature)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sigTmp)
+	feedTmp, err := writeTempFile(feedPath, feed)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(feedTmp)
+
+	if err := os.Rename(sigTmp, sigPath); err != nil {
+		return err
+	}
+	return os.Rename(feedTmp, feedPath)
+}
+
+// writeTempFile schreibt data in eine temporäre Datei neben path
+func writeTempFile(path string, data []byte) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Archivformate für Release-Artefakte
+const (
+	ArchiveAuto  = "auto" // zip für Windows, sonst tar.gz
+	ArchiveTarGz = "tar.gz"
+	ArchiveZip   = "zip"
+)
+
+// DefaultNameTemplate benennt die Archive, die Endung wird angehängt
+const DefaultNameTemplate = "{{.Name}}_{{.OS}}_{{.Arch}}"
+
+// allTargets sind die Plattformen, die mit -targets all gebaut werden
+var allTargets = []string{
+	"linux/amd64", "linux/arm64", "linux/386", "linux/arm",
+	"darwin/amd64", "darwin/arm64",
+	"windows/amd64", "windows/arm64", "windows/386",
+	"freebsd/amd64", "freebsd/arm64",
+	"openbsd/amd64", "openbsd/arm64",
+}
+
+// ReleaseOptions steuern den Build für mehrere Zielplattformen
+type ReleaseOptions struct {
+	Targets      []string // Ziele im Format os/arch
+	Jobs         int      // Maximale Anzahl paralleler Builds
+	OutputDir    string   // Verzeichnis für Archive, SHA256SUMS und Manifest
+	Archive      string   // auto, tar.gz oder zip
+	NameTemplate string   // text/template mit .Name, .OS und .Arch
+}
+
+// ReleaseArtifact beschreibt das Ergebnis für ein Ziel im Manifest
+type ReleaseArtifact struct {
+	Target  string `json:"target"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	Binary  string `json:"binary,omitempty"`
+	Archive string `json:"archive,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	SBOM    string `json:"sbom,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ReleaseManifest wird als manifest.json in das Ausgabeverzeichnis geschrieben
+type ReleaseManifest struct {
+	Name      string            `json:"name"`
+	Config    string            `json:"config"`
+	Artifacts []ReleaseArtifact `json:"artifacts"`
+	Packages  []ReleasePackage  `json:"packages,omitempty"`
+}
+
+// parseTargets zerlegt die Liste aus -targets. "all" steht für alle bekannten Ziele.
+func parseTargets(value string) ([]string, error) {
+	var targets []string
+	seen := make(map[string]bool)
+	for _, target := range strings.Split(value, ",") {
+		target = strings.TrimSpace(target)
+		if target == "" {
+			continue
+		}
+		expanded := []string{target}
+		if target == "all" {
+			expanded = allTargets
+		}
+		for _, t := range expanded {
+			goos, goarch, ok := strings.Cut(t, "/")
+			if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+				return nil, fmt.Errorf("ungültiges Ziel %q (erwartet os/arch)", t)
+			}
+			if !seen[t] {
+				seen[t] = true
+				targets = append(targets, t)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("keine Ziele angegeben")
+	}
+	return targets, nil
+}
+
+// buildRelease baut die Konfiguration für alle Ziele, verpackt jedes Executable
+// und schreibt SHA256SUMS und manifest.json. Schlägt ein Ziel fehl, werden die
+// übrigen trotzdem gebaut und der Fehler im Manifest vermerkt.
+func buildRelease(opts BuildOptions, release ReleaseOptions) error {
+	signer, err := loadSigner(&opts)
+	if err != nil {
+		return err
+	}
+
+	input, err := prepareBundle(opts, signer)
+	if err != nil {
+		return err
+	}
+	config := input.Config
+	if err := checkPublish(opts, input, signer); err != nil {
+		return err
+	}
+
+	if release.Jobs < 1 {
+		release.Jobs = runtime.NumCPU()
+	}
+	if release.NameTemplate == "" {
+		release.NameTemplate = DefaultNameTemplate
+	}
+	nameTmpl, err := template.New("name").Option("missingkey=error").Parse(release.NameTemplate)
+	if err != nil {
+		return fmt.Errorf("ungültiges Namens-Template: %w", err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(opts.OutputName), ".exe")
+	if opts.OutputName == "" {
+		name = input.defaultOutputName("")
+	}
+
+	// Archivnamen vorab bestimmen, damit Kollisionen vor dem Build auffallen
+	artifacts := make([]ReleaseArtifact, len(release.Targets))
+	archives := make(map[string]string)
+	for i, target := range release.Targets {
+		goos, goarch, _ := strings.Cut(target, "/")
+		format, err := archiveFormat(release.Archive, goos)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := nameTmpl.Execute(&buf, map[string]string{"Name": name, "OS": goos, "Arch": goarch}); err != nil {
+			return fmt.Errorf("Namens-Template: %w", err)
+		}
+		archive := buf.String() + "." + format
+		if other, ok := archives[archive]; ok {
+			return fmt.Errorf("Namens-Template ergibt für %s und %s denselben Namen %s", other, target, archive)
+		}
+		archives[archive] = target
+
+		binary := name
+		if goos == "windows" {
+			binary += ".exe"
+		}
+		artifacts[i] = ReleaseArtifact{Target: target, OS: goos, Arch: goarch, Binary: binary, Archive: archive}
+	}
+
+	if err := os.MkdirAll(release.OutputDir, 0755); err != nil {
+		return err
+	}
+	outputDir, err := filepath.Abs(release.OutputDir)
+	if err != nil {
+		return err
+	}
+	if err := runBuildHooks("before", opts, input, outputDir, strings.Join(release.Targets, ",")); err != nil {
+		return err
+	}
+	workDir, err := os.MkdirTemp(release.OutputDir, ".build-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, release.Jobs)
+	for i := range artifacts {
+		wg.Add(1)
+		go func(artifact *ReleaseArtifact) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if err := buildReleaseTarget(opts, input, workDir, release.OutputDir, artifact); err != nil {
+				// Die SBOM eines fehlgeschlagenen Ziels gehört nicht ins Release
+				if artifact.SBOM != "" {
+					_ = os.Remove(filepath.Join(release.OutputDir, artifact.SBOM))
+				}
+				artifact.Error = err.Error()
+				artifact.Archive = ""
+				artifact.SBOM = ""
+			}
+		}(&artifacts[i])
+	}
+	wg.Wait()
+
+	manifest := ReleaseManifest{Name: name, Config: filepath.Base(opts.ConfigFile), Artifacts: artifacts}
+	if len(input.Commands) > 0 {
+		var files []string
+		for _, cmd := range input.Commands {
+			files = append(files, filepath.Base(cmd.ConfigFile))
+		}
+		manifest.Config = strings.Join(files, ",")
+	}
+	if config.Package != nil {
+		manifest.Packages = buildPackages(config.Package, name, artifacts, workDir, release.OutputDir, buildTime(opts))
+	}
+
+	if err := writeReleaseFiles(release.OutputDir, manifest); err != nil {
+		return err
+	}
+	if signer != nil {
+		if err := signFiles(signer, releaseFiles(release.OutputDir, manifest)...); err != nil {
+			return fmt.Errorf("Signieren: %w", err)
+		}
+	}
+
+	// Zusammenfassung über alle Ziele, auch wenn einzelne fehlgeschlagen sind
+	var failed int
+	fmt.Println("\nErgebnis:")
+	for _, artifact := range artifacts {
+		if artifact.Error != "" {
+			failed++
+			fmt.Printf("  ✗ %s: %s\n", artifact.Target, artifact.Error)
+			continue
+		}
+		fmt.Printf("  ✓ %s: %s\n", artifact.Target, filepath.Join(release.OutputDir, artifact.Archive))
+	}
+	var failedPackages int
+	for _, pkg := range manifest.Packages {
+		label := pkg.Format
+		if pkg.Target != "" {
+			label += " " + pkg.Target
+		}
+		if pkg.Error != "" {
+			failedPackages++
+			fmt.Printf("  ✗ %s: %s\n", label, pkg.Error)
+			continue
+		}
+		fmt.Printf("  ✓ %s: %s\n", label, filepath.Join(release.OutputDir, pkg.File))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d von %d Zielen fehlgeschlagen", failed, len(artifacts))
+	}
+	if failedPackages > 0 {
+		return fmt.Errorf("%d von %d Paketen fehlgeschlagen", failedPackages, len(manifest.Packages))
+	}
+	if opts.Publish != "" {
+		binaries := make(map[string]string, len(artifacts))
+		for _, artifact := range artifacts {
+			binaries[artifact.Target] = filepath.Join(workDir, artifact.OS+"_"+artifact.Arch, artifact.Binary)
+		}
+		if err := publishUpdate(opts, input, binaries); err != nil {
+			return fmt.Errorf("Veröffentlichen: %w", err)
+		}
+	}
+	return runBuildHooks("after", opts, input, outputDir, strings.Join(release.Targets, ","))
+}
+
+// buildReleaseTarget baut ein Ziel im Arbeitsverzeichnis und verpackt es nach outputDir
+func buildReleaseTarget(opts BuildOptions, input *buildInput, workDir, outputDir string, artifact *ReleaseArtifact) error {
+	targetOpts := opts
+	targetOpts.GOOS = artifact.OS
+	targetOpts.GOARCH = artifact.Arch
+
+	dir := filepath.Join(workDir, artifact.OS+"_"+artifact.Arch)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	binaryPath, err := filepath.Abs(filepath.Join(dir, artifact.Binary))
+	if err != nil {
+		return err
+	}
+	bundle := input.BundleFor(targetOpts)
+	if err := writeExecutable(targetOpts, bundle, binaryPath); err != nil {
+		return err
+	}
+	if opts.VerifyReproducible {
+		if err := verifyReproducible(targetOpts, bundle, binaryPath); err != nil {
+			return err
+		}
+	}
+	if err := runSmokeTests(targetOpts, input, binaryPath); err != nil {
+		return err
+	}
+	if opts.SBOM != "" {
+		base := filepath.Join(outputDir, strings.TrimSuffix(strings.TrimSuffix(artifact.Archive, ".zip"), ".tar.gz"))
+		sbomPath, err := writeSBOM(opts.SBOM, binaryPath, base, input, targetOpts)
+		if err != nil {
+			return fmt.Errorf("SBOM: %w", err)
+		}
+		artifact.SBOM = filepath.Base(sbomPath)
+	}
+
+	archivePath := filepath.Join(outputDir, artifact.Archive)
+	if err := writeArchive(archivePath, binaryPath, artifact.Binary, buildTime(opts)); err != nil {
+		return fmt.Errorf("Archiv: %w", err)
+	}
+
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return err
+	}
+	artifact.Size = info.Size()
+	artifact.SHA256, err = fileSHA256(archivePath)
+	return err
+}
+
+// fileSHA256 liefert die hex-kodierte SHA-256-Prüfsumme einer Datei
+func fileSHA256(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// archiveFormat bestimmt das Archivformat für ein Ziel
+func archiveFormat(format, goos string) (string, error) {
+	switch format {
+	case "", ArchiveAuto:
+		if goos == "windows" {
+			return ArchiveZip, nil
+		}
+		return ArchiveTarGz, nil
+	case ArchiveTarGz, "tgz":
+		return ArchiveTarGz, nil
+	case ArchiveZip:
+		return ArchiveZip, nil
+	default:
+		return "", fmt.Errorf("unbekanntes Archivformat %q (auto, tar.gz oder zip)", format)
+	}
+}
+
+// writeArchive verpackt das Executable unter dem Namen name als tar.gz oder zip.
+// Geschrieben wird in eine temporäre Datei daneben, damit ein Fehler kein
+// halbes Archiv im Ausgabeverzeichnis hinterlässt.
+func writeArchive(archivePath, binaryPath, name string, modTime time.Time) error {
+	info, err := os.Stat(binaryPath)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(binaryPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(archivePath), "."+filepath.Base(archivePath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	if strings.HasSuffix(archivePath, ".zip") {
+		zw := zip.NewWriter(out)
+		header := &zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: modTime,
+		}
+		header.SetMode(0755)
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, src); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		return finishArchive(out, archivePath)
+	}
+
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0755,
+		Size:     info.Size(),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, src); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return finishArchive(out, archivePath)
+}
+
+// finishArchive schließt die temporäre Datei out und benennt sie in archivePath um
+func finishArchive(out *os.File, archivePath string) error {
+	if err := out.Chmod(0644); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), archivePath)
+}
+
+// releaseFiles liefert alle erfolgreich erstellten Dateien eines Releases
+// einschließlich SHA256SUMS und manifest.json
+func releaseFiles(dir string, manifest ReleaseManifest) []string {
+	var files []string
+	for _, artifact := range manifest.Artifacts {
+		if artifact.Error == "" {
+			files = append(files, filepath.Join(dir, artifact.Archive))
+		}
+		if artifact.SBOM != "" {
+			files = append(files, filepath.Join(dir, artifact.SBOM))
+		}
+	}
+	for _, pkg := range manifest.Packages {
+		if pkg.Error == "" {
+			files = append(files, filepath.Join(dir, pkg.File))
+		}
+	}
+	return append(files, filepath.Join(dir, "SHA256SUMS"), filepath.Join(dir, "manifest.json"))
+}
+
+// writeReleaseFiles schreibt SHA256SUMS (im Format von sha256sum) und manifest.json
+func writeReleaseFiles(dir string, manifest ReleaseManifest) error {
+	// Archive und Pakete, sortiert nach Dateiname
+	files := make(map[string]string)
+	for _, artifact := range manifest.Artifacts {
+		if artifact.Error == "" {
+			files[artifact.Archive] = artifact.SHA256
+		}
+		if artifact.SBOM != "" {
+			sum, err := fileSHA256(filepath.Join(dir, artifact.SBOM))
+			if err != nil {
+				return err
+			}
+			files[artifact.SBOM] = sum
+		}
+	}
+	for _, pkg := range manifest.Packages {
+		if pkg.Error == "" {
+			files[pkg.File] = pkg.SHA256
+		}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sums strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sums, "%s  %s\n", files[name], name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "SHA256SUMS"), []byte(sums.String()), 0644); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "manifest.json"), append(data, '\n'), 0644)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proxyBuildVersion liefert die Version dieses ProxyBuild-Executables
+func proxyBuildVersion() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return version
+}
+
+// buildTime liefert den Zeitstempel für Metadaten, Archive, Pakete und SBOMs.
+// Ohne -build-time-now ist er reproduzierbar: SOURCE_DATE_EPOCH, sonst die Zeit
+// des Commits, in dem die Konfiguration liegt, sonst 1970-01-01.
+func buildTime(opts BuildOptions) time.Time {
+	if opts.BuildTimeNow {
+		return time.Now().UTC()
+	}
+	if t, ok := sourceDateEpoch(); ok {
+		return t
+	}
+	if t, ok := configCommitTime(opts.ConfigFile); ok {
+		return t
+	}
+	return time.Unix(0, 0).UTC()
+}
+
+// configCommitTime liefert die Zeit des Commits, in dem die Konfiguration liegt
+func configCommitTime(configFile string) (time.Time, bool) {
+	dir := filepath.Dir(configFile)
+	out, err := exec.Command("git", "-C", dir, "show", "-s", "--format=%ct", "HEAD").Output()
+	if err != nil {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0).UTC(), true
+}
+
+// sourceDateEpoch liefert die Zeit aus SOURCE_DATE_EPOCH, falls gesetzt und gültig
+func sourceDateEpoch() (time.Time, bool) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warnung: ungültiges SOURCE_DATE_EPOCH %q wird ignoriert\n", epoch)
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0).UTC(), true
+}
+
+// verifyReproducible baut das Executable ein zweites Mal ohne Stub-Cache und
+// vergleicht es Byte für Byte mit outputPath
+func verifyReproducible(opts BuildOptions, bundle []byte, outputPath string) error {
+	opts, err := withTrustedBaseCommands(opts, bundle)
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "proxybuild-verify-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	fmt.Println("Prüfe Reproduzierbarkeit mit einem zweiten Build...")
+	rebuilt := filepath.Join(dir, filepath.Base(outputPath))
+	switch buildStrategy(opts) {
+	case StrategyStub:
+		// Den Stub frisch kompilieren, statt ihn aus dem Cache zu kopieren
+		stubPath := filepath.Join(dir, stubName(opts))
+		if err := compileRunner(opts, nil, stubPath); err != nil {
+			return err
+		}
+		stub, err := os.ReadFile(stubPath)
+		if err != nil {
+			return err
+		}
+		data, err := appendBundle(stub, bundle)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(rebuilt, data, 0755); err != nil {
+			return err
+		}
+	default:
+		if err := compileRunner(opts, bundle, rebuilt); err != nil {
+			return err
+		}
+	}
+
+	first, err := os.ReadFile(outputPath)
+	if err != nil {
+		return err
+	}
+	second, err := os.ReadFile(rebuilt)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(first, second) {
+		return fmt.Errorf("nicht reproduzierbar: %s (sha256 %x, %d Bytes) und zweiter Build (sha256 %x, %d Bytes) unterscheiden sich ab Byte %d",
+			filepath.Base(outputPath), sha256.Sum256(first), len(first), sha256.Sum256(second), len(second), firstDifference(first, second))
+	}
+	fmt.Printf("✓ Reproduzierbar: beide Builds ergeben sha256 %x\n", sha256.Sum256(first))
+	return nil
+}
+
+// firstDifference liefert den ersten Offset, an dem sich a und b unterscheiden
+func firstDifference(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return min(len(a), len(b))
+}
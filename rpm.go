@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+)
+
+// rpmArch übersetzt GOARCH in die RPM-Architektur
+var rpmArch = map[string]string{
+	"amd64":   "x86_64",
+	"arm64":   "aarch64",
+	"386":     "i686",
+	"arm":     "armv7hl",
+	"ppc64le": "ppc64le",
+	"riscv64": "riscv64",
+	"s390x":   "s390x",
+}
+
+// Datentypen der Einträge im RPM-Header
+const (
+	rpmInt16       = 3
+	rpmInt32       = 4
+	rpmString      = 6
+	rpmBin         = 7
+	rpmStringArray = 8
+	rpmI18NString  = 9
+)
+
+// Tags im Signatur- und im Haupt-Header
+const (
+	rpmTagHeaderSignatures  = 62
+	rpmTagHeaderImmutable   = 63
+	rpmTagHeaderI18NTable   = 100
+	rpmSigTagSHA1           = 269
+	rpmSigTagSHA256         = 273
+	rpmSigTagSize           = 1000
+	rpmSigTagMD5            = 1004
+	rpmSigTagPayloadSize    = 1007
+	rpmTagName              = 1000
+	rpmTagVersion           = 1001
+	rpmTagRelease           = 1002
+	rpmTagSummary           = 1004
+	rpmTagDescription       = 1005
+	rpmTagBuildTime         = 1006
+	rpmTagBuildHost         = 1007
+	rpmTagSize              = 1009
+	rpmTagVendor            = 1011
+	rpmTagLicense           = 1014
+	rpmTagPackager          = 1015
+	rpmTagGroup             = 1016
+	rpmTagURL               = 1020
+	rpmTagOS                = 1021
+	rpmTagArch              = 1022
+	rpmTagFileSizes         = 1028
+	rpmTagFileModes         = 1030
+	rpmTagFileRdevs         = 1033
+	rpmTagFileMtimes        = 1034
+	rpmTagFileDigests       = 1035
+	rpmTagFileLinkTos       = 1036
+	rpmTagFileFlags         = 1037
+	rpmTagFileUsername      = 1039
+	rpmTagFileGroupname     = 1040
+	rpmTagSourceRPM         = 1044
+	rpmTagProvideName       = 1047
+	rpmTagRequireFlags      = 1048
+	rpmTagRequireName       = 1049
+	rpmTagRequireVersion    = 1050
+	rpmTagRPMVersion        = 1064
+	rpmTagFileDevices       = 1095
+	rpmTagFileInodes        = 1096
+	rpmTagFileLangs         = 1097
+	rpmTagProvideFlags      = 1112
+	rpmTagProvideVersion    = 1113
+	rpmTagDirIndexes        = 1116
+	rpmTagBaseNames         = 1117
+	rpmTagDirNames          = 1118
+	rpmTagPayloadFormat     = 1124
+	rpmTagPayloadCompressor = 1125
+	rpmTagPayloadFlags      = 1126
+	rpmTagFileDigestAlgo    = 5011
+)
+
+const (
+	rpmSenseEqual  = 1 << 3
+	rpmSenseLess   = 1 << 1
+	rpmSenseRPMLib = 1 << 24
+
+	rpmDigestSHA256 = 8
+
+	rpmFileConfig = 1 << 0 // %config: bei Änderungen durch den Administrator nicht überschreiben
+)
+
+// rpmFile ist eine Datei oder ein Symlink im Paket
+type rpmFile struct {
+	path  string
+	mode  uint16
+	data  []byte
+	link  string
+	flags uint32
+}
+
+// writeRPM erzeugt ein RPM-Paket (Format v3 mit gzip-komprimiertem cpio-Payload)
+func writeRPM(w io.Writer, pkg packageInput) error {
+	arch, ok := rpmArch[pkg.Arch]
+	if !ok {
+		return fmt.Errorf("rpm: keine RPM-Architektur für %s", pkg.Arch)
+	}
+
+	files := []rpmFile{{path: pkg.BinaryPath(), mode: 0100755, data: pkg.Binary}}
+	for _, link := range pkg.Symlinks {
+		files = append(files, rpmFile{path: link, mode: 0120777, data: []byte(pkg.BinaryPath()), link: pkg.BinaryPath()})
+	}
+	if script, data := pkg.ProfileScript(); script != "" {
+		files = append(files, rpmFile{path: script, mode: 0100644, data: data, flags: rpmFileConfig})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+
+	payload, payloadSize, err := rpmPayload(files, pkg)
+	if err != nil {
+		return err
+	}
+	header := rpmMainHeader(files, pkg, arch).encode(rpmTagHeaderImmutable)
+
+	headerSHA1 := sha1.Sum(header)
+	headerSHA256 := sha256.Sum256(header)
+	md5sum := md5.New()
+	md5sum.Write(header)
+	md5sum.Write(payload)
+
+	sig := newRPMHeader()
+	sig.addString(rpmSigTagSHA1, fmt.Sprintf("%x", headerSHA1))
+	sig.addString(rpmSigTagSHA256, fmt.Sprintf("%x", headerSHA256))
+	sig.addInt32(rpmSigTagSize, uint32(len(header)+len(payload)))
+	sig.addBin(rpmSigTagMD5, md5sum.Sum(nil))
+	sig.addInt32(rpmSigTagPayloadSize, uint32(payloadSize))
+	signature := sig.encode(rpmTagHeaderSignatures)
+	// Der Haupt-Header beginnt an einer durch 8 teilbaren Position
+	if rest := len(signature) % 8; rest != 0 {
+		signature = append(signature, make([]byte, 8-rest)...)
+	}
+
+	for _, part := range [][]byte{rpmLead(pkg), signature, header, payload} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rpmLead erzeugt den 96 Byte langen Vorspann
+func rpmLead(pkg packageInput) []byte {
+	lead := make([]byte, 96)
+	copy(lead, []byte{0xed, 0xab, 0xee, 0xdb, 3, 0})
+	binary.BigEndian.PutUint16(lead[6:], 0) // Binärpaket
+	binary.BigEndian.PutUint16(lead[8:], 1)
+	copy(lead[10:75], fmt.Sprintf("%s-%s-%s", pkg.Name, pkg.Version, pkg.Release))
+	binary.BigEndian.PutUint16(lead[76:], 1) // Linux
+	binary.BigEndian.PutUint16(lead[78:], 5) // Signatur im Header-Format
+	return lead
+}
+
+// rpmMainHeader beschreibt Paket, Abhängigkeiten und Dateien
+func rpmMainHeader(files []rpmFile, pkg packageInput, arch string) *rpmHeader {
+	h := newRPMHeader()
+	h.addStringArray(rpmTagHeaderI18NTable, []string{"C"})
+	h.addString(rpmTagName, pkg.Name)
+	h.addString(rpmTagVersion, pkg.Version)
+	h.addString(rpmTagRelease, pkg.Release)
+	h.addI18N(rpmTagSummary, pkg.Summary())
+	description := pkg.Description
+	if description == "" {
+		description = pkg.Summary()
+	}
+	h.addI18N(rpmTagDescription, description)
+	h.addInt32(rpmTagBuildTime, uint32(pkg.ModTime.Unix()))
+	h.addString(rpmTagBuildHost, "proxybuild")
+	h.addI18N(rpmTagGroup, "Unspecified")
+	license := pkg.License
+	if license == "" {
+		license = "unknown"
+	}
+	h.addString(rpmTagLicense, license)
+	if pkg.Vendor != "" {
+		h.addString(rpmTagVendor, pkg.Vendor)
+	}
+	if pkg.Maintainer != "" {
+		h.addString(rpmTagPackager, pkg.Maintainer)
+	}
+	if pkg.Homepage != "" {
+		h.addString(rpmTagURL, pkg.Homepage)
+	}
+	h.addString(rpmTagOS, "linux")
+	h.addString(rpmTagArch, arch)
+	h.addString(rpmTagSourceRPM, fmt.Sprintf("%s-%s-%s.src.rpm", pkg.Name, pkg.Version, pkg.Release))
+	h.addString(rpmTagRPMVersion, "4.0")
+	h.addString(rpmTagPayloadFormat, "cpio")
+	h.addString(rpmTagPayloadCompressor, "gzip")
+	h.addString(rpmTagPayloadFlags, "9")
+
+	h.addStringArray(rpmTagProvideName, []string{pkg.Name})
+	h.addInt32(rpmTagProvideFlags, rpmSenseEqual)
+	h.addStringArray(rpmTagProvideVersion, []string{pkg.Version + "-" + pkg.Release})
+
+	// Formatmerkmale, die rpm zum Installieren kennen muss
+	requires := [][2]string{
+		{"rpmlib(CompressedFileNames)", "3.0.4-1"},
+		{"rpmlib(FileDigests)", "4.6.0-1"},
+		{"rpmlib(PayloadFilesHavePrefix)", "4.0-1"},
+	}
+	var names, versions []string
+	var flags []uint32
+	for _, req := range requires {
+		names = append(names, req[0])
+		versions = append(versions, req[1])
+		flags = append(flags, rpmSenseLess|rpmSenseEqual|rpmSenseRPMLib)
+	}
+	h.addStringArray(rpmTagRequireName, names)
+	h.addStringArray(rpmTagRequireVersion, versions)
+	h.addInt32(rpmTagRequireFlags, flags...)
+
+	var (
+		sizes, mtimes, fileFlags, devices, inodes, dirIndexes []uint32
+		modes, rdevs                                          []uint16
+		digests, links, users, groups, langs, baseNames, dirs []string
+		total                                                 uint32
+	)
+	dirIndex := make(map[string]uint32)
+	for i, file := range files {
+		size := uint32(len(file.data))
+		total += size
+		sizes = append(sizes, size)
+		modes = append(modes, file.mode)
+		rdevs = append(rdevs, 0)
+		mtimes = append(mtimes, uint32(pkg.ModTime.Unix()))
+		digest := ""
+		if file.link == "" {
+			digest = fmt.Sprintf("%x", sha256.Sum256(file.data))
+		}
+		digests = append(digests, digest)
+		links = append(links, file.link)
+		fileFlags = append(fileFlags, file.flags)
+		users = append(users, "root")
+		groups = append(groups, "root")
+		devices = append(devices, 1)
+		inodes = append(inodes, uint32(i+1))
+		langs = append(langs, "")
+
+		dir := path.Dir(file.path) + "/"
+		index, ok := dirIndex[dir]
+		if !ok {
+			index = uint32(len(dirs))
+			dirIndex[dir] = index
+			dirs = append(dirs, dir)
+		}
+		dirIndexes = append(dirIndexes, index)
+		baseNames = append(baseNames, path.Base(file.path))
+	}
+	h.addInt32(rpmTagSize, total)
+	h.addInt32(rpmTagFileSizes, sizes...)
+	h.addInt16(rpmTagFileModes, modes...)
+	h.addInt16(rpmTagFileRdevs, rdevs...)
+	h.addInt32(rpmTagFileMtimes, mtimes...)
+	h.addStringArray(rpmTagFileDigests, digests)
+	h.addStringArray(rpmTagFileLinkTos, links)
+	h.addInt32(rpmTagFileFlags, fileFlags...)
+	h.addStringArray(rpmTagFileUsername, users)
+	h.addStringArray(rpmTagFileGroupname, groups)
+	h.addInt32(rpmTagFileDevices, devices...)
+	h.addInt32(rpmTagFileInodes, inodes...)
+	h.addStringArray(rpmTagFileLangs, langs)
+	h.addInt32(rpmTagDirIndexes, dirIndexes...)
+	h.addStringArray(rpmTagBaseNames, baseNames)
+	h.addStringArray(rpmTagDirNames, dirs)
+	h.addInt32(rpmTagFileDigestAlgo, rpmDigestSHA256)
+	return h
+}
+
+// rpmPayload erzeugt das gzip-komprimierte cpio-Archiv (newc) und liefert
+// zusätzlich dessen unkomprimierte Größe
+func rpmPayload(files []rpmFile, pkg packageInput) ([]byte, int, error) {
+	var archive bytes.Buffer
+	writeEntry := func(ino int, name string, mode uint32, data []byte) {
+		fmt.Fprintf(&archive, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+			ino, mode, 0, 0, 1, uint32(pkg.ModTime.Unix()), len(data), 0, 0, 0, 0, len(name)+1, 0)
+		archive.WriteString(name)
+		archive.WriteByte(0)
+		for archive.Len()%4 != 0 {
+			archive.WriteByte(0)
+		}
+		archive.Write(data)
+		for archive.Len()%4 != 0 {
+			archive.WriteByte(0)
+		}
+	}
+	for i, file := range files {
+		writeEntry(i+1, "."+file.path, uint32(file.mode), file.data)
+	}
+	writeEntry(0, "TRAILER!!!", 0, nil)
+
+	var compressed bytes.Buffer
+	gw, err := gzip.NewWriterLevel(&compressed, gzip.BestCompression)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := gw.Write(archive.Bytes()); err != nil {
+		return nil, 0, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, 0, err
+	}
+	return compressed.Bytes(), archive.Len(), nil
+}
+
+// rpmHeader sammelt die Einträge eines Header-Abschnitts
+type rpmHeader struct {
+	entries map[int]rpmEntry
+}
+
+type rpmEntry struct {
+	typ   int
+	count int
+	data  []byte
+}
+
+func newRPMHeader() *rpmHeader {
+	return &rpmHeader{entries: make(map[int]rpmEntry)}
+}
+
+func (h *rpmHeader) addString(tag int, value string) {
+	h.entries[tag] = rpmEntry{typ: rpmString, count: 1, data: append([]byte(value), 0)}
+}
+
+func (h *rpmHeader) addI18N(tag int, value string) {
+	h.entries[tag] = rpmEntry{typ: rpmI18NString, count: 1, data: append([]byte(value), 0)}
+}
+
+func (h *rpmHeader) addStringArray(tag int, values []string) {
+	var data []byte
+	for _, value := range values {
+		data = append(append(data, value...), 0)
+	}
+	h.entries[tag] = rpmEntry{typ: rpmStringArray, count: len(values), data: data}
+}
+
+func (h *rpmHeader) addInt32(tag int, values ...uint32) {
+	data := make([]byte, 0, 4*len(values))
+	for _, value := range values {
+		data = binary.BigEndian.AppendUint32(data, value)
+	}
+	h.entries[tag] = rpmEntry{typ: rpmInt32, count: len(values), data: data}
+}
+
+func (h *rpmHeader) addInt16(tag int, values ...uint16) {
+	data := make([]byte, 0, 2*len(values))
+	for _, value := range values {
+		data = binary.BigEndian.AppendUint16(data, value)
+	}
+	h.entries[tag] = rpmEntry{typ: rpmInt16, count: len(values), data: data}
+}
+
+func (h *rpmHeader) addBin(tag int, value []byte) {
+	h.entries[tag] = rpmEntry{typ: rpmBin, count: len(value), data: value}
+}
+
+// encode serialisiert den Header. Der erste Index-Eintrag ist die Region regionTag,
+// deren Daten am Ende stehen und auf den Anfang des Index zurückverweisen.
+func (h *rpmHeader) encode(regionTag int) []byte {
+	tags := make([]int, 0, len(h.entries))
+	for tag := range h.entries {
+		tags = append(tags, tag)
+	}
+	sort.Ints(tags)
+
+	var store bytes.Buffer
+	offsets := make([]int, len(tags))
+	for i, tag := range tags {
+		entry := h.entries[tag]
+		align := 1
+		switch entry.typ {
+		case rpmInt16:
+			align = 2
+		case rpmInt32:
+			align = 4
+		}
+		for store.Len()%align != 0 {
+			store.WriteByte(0)
+		}
+		offsets[i] = store.Len()
+		store.Write(entry.data)
+	}
+
+	indexCount := len(tags) + 1
+	regionOffset := store.Len()
+	region := make([]byte, 16)
+	binary.BigEndian.PutUint32(region[0:], uint32(regionTag))
+	binary.BigEndian.PutUint32(region[4:], rpmBin)
+	binary.BigEndian.PutUint32(region[8:], uint32(int32(-16*indexCount)))
+	binary.BigEndian.PutUint32(region[12:], 16)
+	store.Write(region)
+
+	out := []byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0}
+	out = binary.BigEndian.AppendUint32(out, uint32(indexCount))
+	out = binary.BigEndian.AppendUint32(out, uint32(store.Len()))
+	index := func(tag, typ, offset, count int) {
+		out = binary.BigEndian.AppendUint32(out, uint32(tag))
+		out = binary.BigEndian.AppendUint32(out, uint32(typ))
+		out = binary.BigEndian.AppendUint32(out, uint32(offset))
+		out = binary.BigEndian.AppendUint32(out, uint32(count))
+	}
+	index(regionTag, rpmBin, regionOffset, 16)
+	for i, tag := range tags {
+		entry := h.entries[tag]
+		index(tag, entry.typ, offsets[i], entry.count)
+	}
+	return append(out, store.Bytes()...)
+}
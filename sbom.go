@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// SBOM-Formate
+const (
+	SBOMCycloneDX = "cyclonedx"
+	SBOMSPDX      = "spdx"
+)
+
+// sbomExtension liefert die Dateiendung für ein SBOM-Format
+func sbomExtension(format string) (string, error) {
+	switch format {
+	case SBOMCycloneDX:
+		return ".cdx.json", nil
+	case SBOMSPDX:
+		return ".spdx.json", nil
+	default:
+		return "", fmt.Errorf("unbekanntes SBOM-Format %q (cyclonedx oder spdx)", format)
+	}
+}
+
+// sbomSubject fasst zusammen, was eine SBOM über ein gebautes Executable aussagt
+type sbomSubject struct {
+	Name         string
+	SHA256       string
+	ConfigSHA256 string
+	Target       string
+	GoVersion    string
+	Modules      []*debug.Module
+	Created      time.Time
+}
+
+// writeSBOM beschreibt das Executable binaryPath und schreibt die SBOM nach
+// base + Endung des Formats
+func writeSBOM(format, binaryPath, base string, input *buildInput, opts BuildOptions) (string, error) {
+	ext, err := sbomExtension(format)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(binaryPath)
+	if err != nil {
+		return "", err
+	}
+	info, err := buildinfo.ReadFile(binaryPath)
+	if err != nil {
+		return "", fmt.Errorf("Go-Modulinformationen nicht lesbar: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	subject := sbomSubject{
+		Name:         strings.TrimSuffix(filepath.Base(binaryPath), ".exe"),
+		SHA256:       hex.EncodeToString(sum[:]),
+		ConfigSHA256: input.ConfigSHA256(),
+		Target:       targetOS(opts) + "/" + targetArch(opts),
+		GoVersion:    info.GoVersion,
+		Modules:      info.Deps,
+		Created:      buildTime(opts),
+	}
+
+	var document any
+	if format == SBOMCycloneDX {
+		document = cycloneDX(subject)
+	} else {
+		document = spdx(subject)
+	}
+	out, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := base + ext
+	return path, os.WriteFile(path, append(out, '\n'), 0644)
+}
+
+// moduleVersion liefert die Version eines Moduls, lokale Module ohne Version erhalten v0.0.0
+func moduleVersion(m *debug.Module) string {
+	if m.Version == "" || m.Version == "(devel)" {
+		return "v0.0.0"
+	}
+	return m.Version
+}
+
+func modulePURL(m *debug.Module) string {
+	return fmt.Sprintf("pkg:golang/%s@%s", m.Path, moduleVersion(m))
+}
+
+// cycloneDX erzeugt ein Dokument im Format CycloneDX 1.5
+func cycloneDX(s sbomSubject) map[string]any {
+	properties := []map[string]string{
+		{"name": "proxybuild:version", "value": proxyBuildVersion()},
+		{"name": "proxybuild:config:sha256", "value": s.ConfigSHA256},
+		{"name": "proxybuild:target", "value": s.Target},
+		{"name": "proxybuild:go:version", "value": s.GoVersion},
+	}
+	mainRef := "proxy:" + s.Name
+
+	var components []map[string]any
+	var refs []string
+	for _, m := range s.Modules {
+		component := map[string]any{
+			"type":    "library",
+			"bom-ref": modulePURL(m),
+			"name":    m.Path,
+			"version": moduleVersion(m),
+			"purl":    modulePURL(m),
+		}
+		if m.Replace != nil {
+			component["properties"] = []map[string]string{{"name": "go:replace", "value": m.Replace.Path}}
+		}
+		components = append(components, component)
+		refs = append(refs, modulePURL(m))
+	}
+	components = append(components, map[string]any{
+		"type":    "platform",
+		"bom-ref": "go:" + s.GoVersion,
+		"name":    "go",
+		"version": strings.TrimPrefix(s.GoVersion, "go"),
+	})
+	refs = append(refs, "go:"+s.GoVersion)
+
+	return map[string]any{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": "urn:uuid:" + uuidFromHash(s.SHA256),
+		"version":      1,
+		"metadata": map[string]any{
+			"timestamp": s.Created.Format(time.RFC3339),
+			"tools": map[string]any{
+				"components": []map[string]string{{"type": "application", "name": "ProxyBuild", "version": proxyBuildVersion()}},
+			},
+			"component": map[string]any{
+				"type":       "application",
+				"bom-ref":    mainRef,
+				"name":       s.Name,
+				"hashes":     []map[string]string{{"alg": "SHA-256", "content": s.SHA256}},
+				"properties": properties,
+			},
+		},
+		"components":   components,
+		"dependencies": []map[string]any{{"ref": mainRef, "dependsOn": refs}},
+	}
+}
+
+// spdx erzeugt ein Dokument im Format SPDX 2.3
+func spdx(s sbomSubject) map[string]any {
+	mainID := "SPDXRef-Package-" + spdxID(s.Name)
+	packages := []map[string]any{{
+		"name":                  s.Name,
+		"SPDXID":                mainID,
+		"downloadLocation":      "NOASSERTION",
+		"filesAnalyzed":         false,
+		"primaryPackagePurpose": "APPLICATION",
+		"checksums":             []map[string]string{{"algorithm": "SHA256", "checksumValue": s.SHA256}},
+		"comment": fmt.Sprintf("Erstellt mit ProxyBuild %s für %s, Konfiguration sha256:%s, %s",
+			proxyBuildVersion(), s.Target, s.ConfigSHA256, s.GoVersion),
+	}}
+	relationships := []map[string]string{{
+		"spdxElementId":      "SPDXRef-DOCUMENT",
+		"relationshipType":   "DESCRIBES",
+		"relatedSpdxElement": mainID,
+	}}
+
+	for _, m := range s.Modules {
+		id := "SPDXRef-Package-" + spdxID(m.Path)
+		packages = append(packages, map[string]any{
+			"name":             m.Path,
+			"SPDXID":           id,
+			"versionInfo":      moduleVersion(m),
+			"downloadLocation": "NOASSERTION",
+			"filesAnalyzed":    false,
+			"externalRefs": []map[string]string{{
+				"referenceCategory": "PACKAGE-MANAGER",
+				"referenceType":     "purl",
+				"referenceLocator":  modulePURL(m),
+			}},
+		})
+		relationships = append(relationships, map[string]string{
+			"spdxElementId":      mainID,
+			"relationshipType":   "DEPENDS_ON",
+			"relatedSpdxElement": id,
+		})
+	}
+
+	return map[string]any{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              s.Name,
+		"documentNamespace": "https://proxybuild.invalid/spdx/" + s.Name + "-" + s.SHA256,
+		"creationInfo": map[string]any{
+			"created":  s.Created.Format(time.RFC3339),
+			"creators": []string{"Tool: ProxyBuild-" + proxyBuildVersion()},
+		},
+		"packages":      packages,
+		"relationships": relationships,
+	}
+}
+
+// spdxID ersetzt Zeichen, die in SPDX-IDs nicht erlaubt sind
+func spdxID(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, name)
+}
+
+// uuidFromHash leitet eine stabile UUID aus einer hex-Prüfsumme ab
+func uuidFromHash(sum string) string {
+	b, _ := hex.DecodeString(sum[:32])
+	b[6] = b[6]&0x0f | 0x50 // Version 5 (namensbasiert)
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
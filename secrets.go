@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"ProxyBuild/proxy"
+)
+
+// SecretsKeyOptions überschreiben die Schlüsselquelle aus der Konfiguration
+type SecretsKeyOptions struct {
+	KeyFile string
+	KeyEnv  string
+}
+
+func (o SecretsKeyOptions) apply(secrets *proxy.SecretsConfig) {
+	if o.KeyFile != "" {
+		secrets.KeyFile = o.KeyFile
+	}
+	if o.KeyEnv != "" {
+		secrets.KeyEnv = o.KeyEnv
+	}
+}
+
+// editSecrets entschlüsselt den secrets-Abschnitt in eine temporäre Datei,
+// öffnet sie im Editor und schreibt das Ergebnis verschlüsselt zurück
+func editSecrets(configFile string, keyOpts SecretsKeyOptions) error {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(configFile)
+	if err != nil {
+		return err
+	}
+
+	// Als RawMessage laden, damit alle anderen Felder unverändert bleiben
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	secrets := &proxy.SecretsConfig{}
+	if section, ok := raw["secrets"]; ok {
+		if err := json.Unmarshal(section, secrets); err != nil {
+			return fmt.Errorf("ungültiger secrets-Abschnitt: %w", err)
+		}
+	}
+	// -secrets-key-file/-secrets-key-env gelten nur für diesen Aufruf. In die
+	// Konfiguration kommen sie nur, wenn sie noch keine Schlüsselquelle nennt.
+	keyFile, keyEnv := secrets.KeyFile, secrets.KeyEnv
+	keyOpts.apply(secrets)
+	if keyFile == "" && keyEnv == "" {
+		keyFile, keyEnv = secrets.KeyFile, secrets.KeyEnv
+	}
+
+	key, passphrase, err := secrets.ReadKey(true)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]string)
+	if secrets.Data != "" {
+		if values, err = secrets.Decrypt(key); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "proxybuild-secrets-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	plaintext, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(append(plaintext, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := runEditor(tmp.Name()); err != nil {
+		return fmt.Errorf("editor: %w", err)
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return err
+	}
+	values = make(map[string]string)
+	if err := json.Unmarshal(edited, &values); err != nil {
+		return fmt.Errorf("secrets müssen ein JSON-Objekt aus Strings sein, nichts geändert: %w", err)
+	}
+
+	encrypted, err := proxy.EncryptSecrets(values, key, passphrase)
+	if err != nil {
+		return err
+	}
+	encrypted.KeyFile = keyFile
+	encrypted.KeyEnv = keyEnv
+
+	// Nur den secrets-Wert ersetzen, Reihenfolge und Formatierung der übrigen
+	// Konfiguration bleiben erhalten
+	updated, err := spliceTopLevelValue(data, "secrets", encrypted)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(configFile, updated, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ %d Secrets verschlüsselt in %s gespeichert\n", len(values), configFile)
+	return nil
+}
+
+// spliceTopLevelValue ersetzt in einem JSON-Objekt den Wert von key durch
+// value, eingerückt wie die Zeile des Schlüssels. Fehlt key, wird er als
+// letztes Feld angehängt. Alle anderen Bytes bleiben unverändert.
+func spliceTopLevelValue(data []byte, key string, value any) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errors.New("die Konfiguration ist kein JSON-Objekt")
+	}
+	fields := 0
+	indent := "  "
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var current json.RawMessage
+		if err := dec.Decode(&current); err != nil {
+			return nil, err
+		}
+		fields++
+		end := int(dec.InputOffset())
+		start := end - len(current)
+		lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+		if lineIndent := data[lineStart : len(data)-len(bytes.TrimLeft(data[lineStart:], " \t"))]; lineStart > 0 && len(lineIndent) > 0 {
+			indent = string(lineIndent)
+		}
+		if tok != key {
+			continue
+		}
+		encoded, err := json.MarshalIndent(value, indent, indent)
+		if err != nil {
+			return nil, err
+		}
+		return append(append(append([]byte{}, data[:start]...), encoded...), data[end:]...), nil
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	// Vor der schließenden Klammer anhängen, eingerückt wie das letzte Feld
+	closing := bytes.LastIndexByte(data[:dec.InputOffset()], '}')
+	head := bytes.TrimRight(data[:closing], " \t\r\n")
+	encoded, err := json.MarshalIndent(value, indent, indent)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	out.Write(head)
+	if fields > 0 {
+		out.WriteByte(',')
+	}
+	fmt.Fprintf(&out, "\n%s%q: %s\n", indent, key, encoded)
+	out.Write(data[closing:])
+	return out.Bytes(), nil
+}
+
+// runEditor öffnet path in $VISUAL bzw. $EDITOR
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	// Editoren wie "code --wait" bringen eigene Argumente mit
+	parts := strings.Fields(editor)
+	cmd := exec.Command(parts[0], append(parts[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// checkSecrets prüft beim Build, ob sich die Secrets entschlüsseln lassen,
+// sofern ein Schlüssel ohne Abfrage verfügbar ist
+func checkSecrets(config *proxy.Config, keyOpts SecretsKeyOptions) error {
+	if config.Secrets == nil {
+		return nil
+	}
+
+	secrets := *config.Secrets
+	keyOpts.apply(&secrets)
+	key, _, err := secrets.ReadKey(false)
+	if errors.Is(err, proxy.ErrNoSecretsKey) {
+		fmt.Printf("Hinweis: %d Secrets werden verschlüsselt eingebettet und erst zur Laufzeit entschlüsselt\n", len(secrets.Names))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := secrets.Decrypt(key); err != nil {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"ProxyBuild/proxy"
+)
+
+// substitution beschreibt eine beim Build ersetzte Umgebungsvariable
+type substitution struct {
+	Path  string // Pfad in der Konfiguration, z.B. hooks.up[0].args[1]
+	Key   string
+	Value string
+}
+
+// secretFinding beschreibt einen ersetzten Wert, der wie ein Credential aussieht
+type secretFinding struct {
+	Path   string
+	Key    string
+	Reason string
+}
+
+func (f secretFinding) String() string {
+	return fmt.Sprintf("%s: $%s (%s)", f.Path, f.Key, f.Reason)
+}
+
+// Bekannte Namen von Umgebungsvariablen, die Credentials enthalten
+var knownSecretEnvNames = map[string]bool{
+	"GITHUB_TOKEN":          true,
+	"GH_TOKEN":              true,
+	"GITLAB_TOKEN":          true,
+	"CI_JOB_TOKEN":          true,
+	"AWS_ACCESS_KEY_ID":     true,
+	"AWS_SECRET_ACCESS_KEY": true,
+	"AWS_SESSION_TOKEN":     true,
+	"AZURE_CLIENT_SECRET":   true,
+	"NPM_TOKEN":             true,
+	"NUGET_API_KEY":         true,
+	"DOCKER_PASSWORD":       true,
+	"SLACK_TOKEN":           true,
+	"ACTIONS_RUNTIME_TOKEN": true,
+}
+
+// Namensbestandteile, die auf Credentials hindeuten
+var secretEnvNameParts = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "API_KEY", "APIKEY", "PRIVATE_KEY", "CREDENTIAL"}
+
+// Typische Präfixe von Tokens bekannter Anbieter
+var secretValuePrefixes = []string{
+	"ghp_", "gho_", "ghu_", "ghs_", "ghr_", "github_pat_",
+	"glpat-", "xoxb-", "xoxp-", "xoxa-", "xapp-",
+	"AKIA", "ASIA", "sk_live_", "rk_live_", "sk-", "AIza", "npm_",
+	"-----BEGIN",
+}
+
+// resolveBuildEnv ersetzt $KEY und %KEY% in allen Strings der Konfiguration,
+// auch in Schlüsseln wie Sub-Command-Namen und env_vars, und liefert die serialisierte Konfiguration sowie alle vorgenommenen Ersetzungen
+func resolveBuildEnv(config *proxy.Config, environ []string) ([]byte, []substitution, error) {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tree any
+	if err := json.Unmarshal(raw, &tree); err != nil {
+		return nil, nil, err
+	}
+
+	env := make(map[string]string)
+	var keys []string
+	for _, envVar := range environ {
+		key, val, ok := strings.Cut(envVar, "=")
+		if !ok || key == "" || !buildEnvAllowed(key, config.BuildEnvAllowlist) {
+			continue
+		}
+		env[key] = val
+		keys = append(keys, key)
+	}
+	// Längere Namen zuerst, damit $HOME nicht den Anfang von $HOMEBREW_PREFIX ersetzt
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	var subs []substitution
+	if tree, err = substituteEnv(tree, "", keys, env, &subs); err != nil {
+		return nil, nil, err
+	}
+
+	raw, err = json.Marshal(tree)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Zurück in die Struktur, damit die Feldreihenfolge stabil bleibt
+	var resolved proxy.Config
+	if err := json.Unmarshal(raw, &resolved); err != nil {
+		return nil, nil, err
+	}
+	configData, err := json.MarshalIndent(resolved, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	return configData, subs, nil
+}
+
+// buildEnvAllowed prüft eine Variable gegen die build_env_allowlist (Glob-Muster erlaubt)
+func buildEnvAllowed(key string, allowlist []string) bool {
+	if len(allowlist) == 0 {
+		return true
+	}
+	for _, pattern := range allowlist {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func substituteEnv(node any, nodePath string, keys []string, env map[string]string, subs *[]substitution) (any, error) {
+	switch v := node.(type) {
+	case map[string]any:
+		fields := make([]string, 0, len(v))
+		for field := range v {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		// Erst alle Schlüssel umbenennen, damit Kollisionen unabhängig von der
+		// Reihenfolge auffallen
+		renamedTo := make(map[string]string, len(fields))
+		renamedFrom := make(map[string]string, len(fields))
+		for _, field := range fields {
+			// Schlüssel wie hooks.$SUB oder env_vars.$NAME werden ebenfalls ersetzt
+			renamed := substituteEnvString(field, joinConfigPath(nodePath, field)+" (Schlüssel)", keys, env, subs)
+			if other, exists := renamedFrom[renamed]; exists {
+				return nil, fmt.Errorf("%s und %s ergeben nach dem Ersetzen der Umgebungsvariablen beide %s", joinConfigPath(nodePath, other), joinConfigPath(nodePath, field), joinConfigPath(nodePath, renamed))
+			}
+			renamedTo[field] = renamed
+			renamedFrom[renamed] = field
+		}
+		result := make(map[string]any, len(v))
+		for _, field := range fields {
+			renamed := renamedTo[field]
+			value, err := substituteEnv(v[field], joinConfigPath(nodePath, renamed), keys, env, subs)
+			if err != nil {
+				return nil, err
+			}
+			result[renamed] = value
+		}
+		return result, nil
+	case []any:
+		for i := range v {
+			var err error
+			if v[i], err = substituteEnv(v[i], fmt.Sprintf("%s[%d]", nodePath, i), keys, env, subs); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case string:
+		return substituteEnvString(v, nodePath, keys, env, subs), nil
+	default:
+		return v, nil
+	}
+}
+
+func joinConfigPath(nodePath, field string) string {
+	if nodePath == "" {
+		return field
+	}
+	return nodePath + "." + field
+}
+
+// substituteEnvString ersetzt die Variablen in einem einzelnen String
+func substituteEnvString(v, nodePath string, keys []string, env map[string]string, subs *[]substitution) string {
+	for _, key := range keys {
+		// Windows Env Syntax: %key%, Unix Env Syntax: $key
+		windowsSyntax := fmt.Sprintf("%%%s%%", key)
+		unixSyntax := fmt.Sprintf("$%s", key)
+		if !strings.Contains(v, unixSyntax) && !strings.Contains(v, windowsSyntax) {
+			continue
+		}
+		v = strings.ReplaceAll(v, unixSyntax, env[key])
+		v = strings.ReplaceAll(v, windowsSyntax, env[key])
+		*subs = append(*subs, substitution{Path: nodePath, Key: key, Value: env[key]})
+	}
+	return v
+}
+
+// scanSubstitutions sucht unter den ersetzten Werten nach möglichen Credentials
+func scanSubstitutions(subs []substitution) []secretFinding {
+	var findings []secretFinding
+	for _, sub := range subs {
+		if reason := secretReason(sub.Key, sub.Value); reason != "" {
+			findings = append(findings, secretFinding{Path: sub.Path, Key: sub.Key, Reason: reason})
+		}
+	}
+	return findings
+}
+
+func secretReason(key, value string) string {
+	if value == "" {
+		return ""
+	}
+	upperKey := strings.ToUpper(key)
+	if knownSecretEnvNames[upperKey] {
+		return "bekannter Secret-Name"
+	}
+	for _, part := range secretEnvNameParts {
+		if strings.Contains(upperKey, part) {
+			return "Name enthält " + part
+		}
+	}
+	for _, prefix := range secretValuePrefixes {
+		if containsTokenPrefix(value, prefix) {
+			return "Token-Präfix " + prefix
+		}
+	}
+	if looksHighEntropy(value) {
+		return fmt.Sprintf("hohe Entropie (%.1f Bit/Zeichen)", shannonEntropy(value))
+	}
+	return ""
+}
+
+// containsTokenPrefix sucht prefix am Anfang eines Worts irgendwo im Wert, z.B.
+// in "Bearer ghp_..." oder "token=AKIA...". Danach müssen noch mehr als 8
+// Zeichen bis zum nächsten Leerzeichen oder Anführungszeichen folgen.
+func containsTokenPrefix(value, prefix string) bool {
+	for offset := 0; ; {
+		i := strings.Index(value[offset:], prefix)
+		if i < 0 {
+			return false
+		}
+		start := offset + i
+		offset = start + 1
+		// "task-runner" enthält sk-, ist aber kein Token
+		if start > 0 && isWordChar(value[start-1]) {
+			continue
+		}
+		rest := value[start+len(prefix):]
+		if end := strings.IndexAny(rest, " \t\r\n\"'"); end >= 0 {
+			rest = rest[:end]
+		}
+		if len(rest) > 8 || strings.HasPrefix(prefix, "-----") {
+			return true
+		}
+	}
+}
+
+func isWordChar(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// looksHighEntropy erkennt zufällig wirkende Token-Strings. Pfade und Sätze
+// werden durch die Zeichen- und Ziffernprüfung ausgeschlossen.
+func looksHighEntropy(value string) bool {
+	if len(value) < 20 {
+		return false
+	}
+	hasDigit, hasLetter := false, false
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+			hasLetter = true
+		case strings.ContainsRune("+/=_-.", r):
+		default:
+			return false
+		}
+	}
+	return hasDigit && hasLetter && shannonEntropy(value) >= 4.0
+}
+
+func shannonEntropy(value string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range value {
+		counts[r]++
+	}
+	total := float64(len([]rune(value)))
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / total
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ProxyBuild/proxy"
+)
+
+// signatureSuffix wird an signierte Dateien angehängt, wie bei minisign
+const signatureSuffix = ".minisig"
+
+// loadSigner lädt den Schlüssel aus -sign-key vor dem Build, damit ein Fehler
+// nicht erst nach dem Kompilieren auffällt. Der öffentliche Schlüssel wird in
+// opts übernommen und in den Runner kompiliert. Ohne -sign-key ist das Ergebnis nil.
+func loadSigner(opts *BuildOptions) (ed25519.PrivateKey, error) {
+	if opts.SignKey == "" {
+		return nil, nil
+	}
+	if opts.TamperPolicy == "" {
+		opts.TamperPolicy = proxy.PolicyRefuse
+	}
+	if err := proxy.ValidatePolicy(opts.TamperPolicy); err != nil {
+		return nil, err
+	}
+	key, err := loadSigningKey(opts.SignKey)
+	if err != nil {
+		return nil, err
+	}
+	opts.configPublicKey = proxy.NewMinisignPublicKey(key.Public().(ed25519.PublicKey)).String()
+	return key, nil
+}
+
+// withTrustedBaseCommands übernimmt mit -tamper-policy no-hooks die
+// Basis-Commands aus bundle in opts, damit sie in den Runner kompiliert werden.
+// Bei manipulierter Konfiguration führt der Runner nur diese aus.
+func withTrustedBaseCommands(opts BuildOptions, bundle []byte) (BuildOptions, error) {
+	if opts.configPublicKey == "" || opts.TamperPolicy != proxy.PolicyNoHooks {
+		return opts, nil
+	}
+	sections, err := proxy.DecodeBundle(bundle)
+	if err != nil {
+		return opts, err
+	}
+	if opts.trustedBaseCommands, err = proxy.TrustedBaseCommands(sections); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// loadSigningKey liest einen ed25519-Schlüssel im PKCS#8-PEM-Format
+// (z.B. von "openssl genpkey -algorithm ed25519" oder -gen-sign-key)
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s: kein PEM-Block \"PRIVATE KEY\" gefunden", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: kein ed25519-Schlüssel", path)
+	}
+	return ed, nil
+}
+
+// generateSigningKey erzeugt ein Schlüsselpaar: path (privat, PEM) und
+// path ohne .pem plus .pub (öffentlich, minisign-Format)
+func generateSigningKey(path string) (string, error) {
+	pubPath := strings.TrimSuffix(path, ".pem") + ".pub"
+	for _, p := range []string{path, pubPath} {
+		if _, err := os.Stat(p); err == nil {
+			return "", fmt.Errorf("%s existiert bereits", p)
+		}
+	}
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(pubPath, proxy.NewMinisignPublicKey(pub).Encode(), 0644); err != nil {
+		return "", err
+	}
+	return pubPath, nil
+}
+
+// signFiles schreibt für jede Datei eine Signatur <datei>.minisig
+func signFiles(key ed25519.PrivateKey, paths ...string) error {
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path+signatureSuffix, signData(key, data, filepath.Base(path)), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Signiert: %s\n", path+signatureSuffix)
+	}
+	return nil
+}
+
+// loadPublicKey liest einen öffentlichen Schlüssel aus einer .pub-Datei, einer
+// PEM-Datei oder direkt aus der base64-Zeile
+func loadPublicKey(spec string) (*proxy.MinisignPublicKey, error) {
+	if spec == "" {
+		return nil, errors.New("-pubkey fehlt")
+	}
+	data, err := os.ReadFile(spec)
+	if err != nil {
+		// Kein Dateiname, dann als Schlüssel selbst verwenden
+		return proxy.ParseMinisignPublicKey([]byte(spec))
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", spec, err)
+		}
+		ed, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: kein ed25519-Schlüssel", spec)
+		}
+		return proxy.NewMinisignPublicKey(ed), nil
+	}
+	return proxy.ParseMinisignPublicKey(data)
+}
+
+// verifyFile prüft die Signatur <path>.minisig mit dem angegebenen öffentlichen Schlüssel
+func verifyFile(path, pubkey string) error {
+	key, err := loadPublicKey(pubkey)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sig, err := os.ReadFile(path + signatureSuffix)
+	if err != nil {
+		return fmt.Errorf("Signatur nicht gefunden: %w", err)
+	}
+	trusted, err := key.Verify(data, sig)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	fmt.Printf("✓ Signatur gültig (Schlüssel %s)\n", key.ID())
+	fmt.Printf("  %s\n", trusted)
+	return nil
+}
+
+// signData signiert data als Datei name, mit SOURCE_DATE_EPOCH als Zeitstempel,
+// sofern gesetzt
+func signData(key ed25519.PrivateKey, data []byte, name string) []byte {
+	timestamp := time.Now()
+	if t, ok := sourceDateEpoch(); ok {
+		timestamp = t
+	}
+	trusted := fmt.Sprintf("timestamp:%d\tfile:%s", timestamp.Unix(), name)
+	return proxy.SignMinisign(key, data, trusted)
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Konfigurationen für -build können aus anderen Quellen kommen. Sie werden vor
+// dem Laden in ein temporäres Verzeichnis geholt, danach läuft der Build wie
+// mit einer lokalen Datei:
+//
+//	git+file:///pfad/repo//configs/docker.json@v1.2  Datei aus einem Git-Repository
+//	https://host/config.json#sha256=<hex>             Download mit erwarteter Prüfsumme
+//	tools.tar.gz//configs/docker.json                 Datei aus einem Archiv mit Assets
+//
+// Ohne Pfad nach "//" wird config.json verwendet. Archive können selbst per
+// https oder aus Git kommen.
+
+const (
+	// defaultSourceConfig ist die Konfiguration in Repositories und Archiven ohne Pfadangabe
+	defaultSourceConfig = "config.json"
+
+	sourceDownloadTimeout = 60 * time.Second
+	sourceMaxDownloadSize = 64 << 20
+)
+
+// withConfigSources holt die Quellen aus opts.ConfigFiles, ruft build mit den
+// lokalen Pfaden auf und räumt danach auf
+func withConfigSources(opts BuildOptions, build func(BuildOptions) error) error {
+	files, cleanup, err := fetchConfigSources(opts.ConfigFiles)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+	opts.ConfigFile = files[0]
+	opts.ConfigFiles = files
+	return build(opts)
+}
+
+// fetchConfigSources holt alle entfernten oder archivierten Quellen und liefert
+// die lokalen Pfade in derselben Reihenfolge. cleanup entfernt die temporären
+// Verzeichnisse und muss auch im Fehlerfall aufgerufen werden.
+func fetchConfigSources(sources []string) (files []string, cleanup func(), err error) {
+	var dirs []string
+	cleanup = func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}
+	for _, source := range sources {
+		if !isFetchedSource(source) {
+			files = append(files, source)
+			continue
+		}
+		dir, err := os.MkdirTemp("", "proxybuild-source-*")
+		if err != nil {
+			return nil, cleanup, err
+		}
+		dirs = append(dirs, dir)
+		file, err := fetchConfigSource(source, dir)
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("%s: %w", source, err)
+		}
+		fmt.Printf("✓ Konfiguration geholt: %s\n", source)
+		files = append(files, file)
+	}
+	return files, cleanup, nil
+}
+
+// isFetchedSource meldet, ob source keine einfache lokale Datei ist
+func isFetchedSource(source string) bool {
+	if strings.HasPrefix(source, "git+") || strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return true
+	}
+	archive, _ := splitSourcePath(source)
+	return isArchiveSource(archive)
+}
+
+// fetchConfigSource holt source nach dir und liefert den Pfad der Konfiguration
+func fetchConfigSource(source, dir string) (string, error) {
+	if rest, ok := strings.CutPrefix(source, "git+"); ok {
+		return fetchGitSource(rest, dir)
+	}
+
+	location, expected, _ := strings.Cut(source, "#")
+	location, inner := splitSourcePath(location)
+	file := location
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		sum, ok := strings.CutPrefix(expected, "sha256=")
+		if !ok || sum == "" {
+			return "", fmt.Errorf("Downloads brauchen eine erwartete Prüfsumme, z.B. %s#sha256=<hex>", location)
+		}
+		file = filepath.Join(dir, "download", path.Base(location))
+		if err := downloadSource(location, strings.ToLower(sum), file); err != nil {
+			return "", err
+		}
+	}
+
+	if !isArchiveSource(location) {
+		if inner != "" {
+			return "", fmt.Errorf("Pfad %q nach // ist nur bei Archiven und Git-Repositories möglich", inner)
+		}
+		return file, nil
+	}
+	root := filepath.Join(dir, "archive")
+	if err := extractSourceArchive(file, root); err != nil {
+		return "", err
+	}
+	return sourceConfigFile(root, inner)
+}
+
+// splitSourcePath trennt den Pfad innerhalb eines Archivs oder Repositories ab:
+// "a.tar.gz//configs/x.json" ergibt "a.tar.gz" und "configs/x.json". Das "//"
+// hinter dem Schema einer URL zählt nicht.
+func splitSourcePath(source string) (location, inner string) {
+	start := 0
+	if i := strings.Index(source, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	i := strings.Index(source[start:], "//")
+	if i < 0 {
+		return source, ""
+	}
+	return source[:start+i], source[start+i+2:]
+}
+
+// isArchiveSource meldet, ob location ein tar.gz-Archiv ist
+func isArchiveSource(location string) bool {
+	return strings.HasSuffix(location, ".tar.gz") || strings.HasSuffix(location, ".tgz")
+}
+
+// sourceConfigFile liefert den Pfad von inner (Standard config.json) unterhalb von root
+func sourceConfigFile(root, inner string) (string, error) {
+	if inner == "" {
+		inner = defaultSourceConfig
+	}
+	clean := path.Clean(inner)
+	if !isLocalSourcePath(clean) {
+		return "", fmt.Errorf("ungültiger Pfad %q", inner)
+	}
+	file := filepath.Join(root, filepath.FromSlash(clean))
+	if _, err := os.Stat(file); err != nil {
+		return "", fmt.Errorf("%s nicht gefunden", inner)
+	}
+	return file, nil
+}
+
+// isLocalSourcePath prüft einen Pfad aus einer Quelle: relativ, ohne ".." und
+// ohne Backslashes oder Laufwerke, die unter Windows aus root hinausführen
+func isLocalSourcePath(name string) bool {
+	return !strings.Contains(name, `\`) && filepath.IsLocal(filepath.FromSlash(name))
+}
+
+// fetchGitSource holt "<repo>//<pfad>[@ref]" per git clone. Für file://-URLs
+// und lokale Pfade ist kein Netzwerk nötig.
+func fetchGitSource(source, dir string) (string, error) {
+	repo, inner := splitSourcePath(source)
+	var ref string
+	if i := strings.LastIndex(inner, "@"); i >= 0 {
+		inner, ref = inner[:i], inner[i+1:]
+	} else if i := strings.LastIndex(repo, "@"); i > strings.LastIndex(repo, "/") {
+		// git+file:///repo@v1 ohne Pfad
+		repo, ref = repo[:i], repo[i+1:]
+	}
+
+	checkout := filepath.Join(dir, "repo")
+	args := []string{"clone", "--quiet"}
+	if ref != "" {
+		args = append(args, "--no-checkout")
+	}
+	if err := runGit("", append(args, "--", repo, checkout)...); err != nil {
+		return "", err
+	}
+	if ref != "" {
+		if err := runGit(checkout, "checkout", "--quiet", ref, "--"); err != nil {
+			return "", fmt.Errorf("ref %s: %w", ref, err)
+		}
+	}
+
+	file, err := sourceConfigFile(checkout, inner)
+	if err != nil {
+		return "", err
+	}
+	// Ein Archiv im Repository wird wie eine lokale Archiv-Quelle behandelt
+	if isArchiveSource(file) {
+		root := filepath.Join(dir, "archive")
+		if err := extractSourceArchive(file, root); err != nil {
+			return "", err
+		}
+		return sourceConfigFile(root, "")
+	}
+	return file, nil
+}
+
+// runGit führt git ohne Rückfragen aus und hängt die Ausgabe an den Fehler
+func runGit(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %w\n%s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// downloadSource lädt url nach file und prüft die sha256-Prüfsumme
+func downloadSource(url, expected, file string) error {
+	if _, err := hex.DecodeString(expected); err != nil || len(expected) != sha256.Size*2 {
+		return fmt.Errorf("ungültige sha256-Prüfsumme %q", expected)
+	}
+	client := &http.Client{Timeout: sourceDownloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Download fehlgeschlagen: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, sourceMaxDownloadSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > sourceMaxDownloadSize {
+		return fmt.Errorf("Download ist größer als %d MiB", sourceMaxDownloadSize>>20)
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return fmt.Errorf("Prüfsumme stimmt nicht: erwartet sha256:%s, erhalten sha256:%s", expected, actual)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// extractSourceArchive entpackt ein tar.gz-Archiv nach root. Nur Verzeichnisse
+// und reguläre Dateien sind erlaubt, Ausführungsrechte bleiben erhalten.
+func extractSourceArchive(archive, root string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(archive), err)
+	}
+	tr := tar.NewReader(gz)
+	// Entpackt höchstens so viel, wie ein Download groß sein darf, damit ein
+	// kleines Archiv nicht die Platte füllt
+	var extracted int64
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(archive), err)
+		}
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if name == "." {
+			continue
+		}
+		if !isLocalSourcePath(name) {
+			return fmt.Errorf("%s: ungültiger Pfad %q im Archiv", filepath.Base(archive), header.Name)
+		}
+		target := filepath.Join(root, filepath.FromSlash(name))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if extracted += header.Size; header.Size < 0 || extracted > sourceMaxDownloadSize {
+				return fmt.Errorf("%s: entpackt größer als %d MiB", filepath.Base(archive), sourceMaxDownloadSize>>20)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode)&0755|0600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			if closeErr := out.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: %s ist weder Datei noch Verzeichnis", filepath.Base(archive), header.Name)
+		}
+	}
+}
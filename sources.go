@@ -0,0 +1,13 @@
+package main
+
+import "embed"
+
+// proxySources enthält die Quelltexte des proxy-Pakets. ProxyBuild schreibt sie
+// beim Build in ein temporäres Verzeichnis, damit kein Checkout des Repositories
+// nötig ist. Sie liegen nur im ProxyBuild-Executable, nicht in den Runnern.
+//
+//go:embed proxy/*.go
+var proxySources embed.FS
+
+// proxyGoMod wird im Build-Verzeichnis neben die Quelltexte geschrieben
+const proxyGoMod = "module ProxyBuild/proxy\n\ngo 1.24\n"
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"ProxyBuild/proxy"
+)
+
+// Build-Strategien
+const (
+	StrategyStub    = "stub"    // Vorgebauten Runner kopieren und Bundle anhängen
+	StrategyCompile = "compile" // Template mit eingebettetem Bundle kompilieren
+)
+
+func targetOS(opts BuildOptions) string {
+	if opts.GOOS != "" {
+		return opts.GOOS
+	}
+	return runtime.GOOS
+}
+
+func targetArch(opts BuildOptions) string {
+	if opts.GOARCH != "" {
+		return opts.GOARCH
+	}
+	return runtime.GOARCH
+}
+
+// stubName liefert den Dateinamen des Runner-Stubs für ein Ziel. Stubs mit
+// eingebautem Schlüssel tragen dessen ID und die Richtlinie im Namen, mit
+// no-hooks auch einen Hash der eingebauten Basis-Commands.
+func stubName(opts BuildOptions) string {
+	goos := targetOS(opts)
+	name := fmt.Sprintf("runner-%s-%s", goos, targetArch(opts))
+	if opts.configPublicKey != "" {
+		key, err := proxy.ParseMinisignPublicKey([]byte(opts.configPublicKey))
+		if err == nil {
+			name += "-" + strings.ToLower(key.ID()) + "-" + opts.TamperPolicy
+		}
+	}
+	if opts.trustedBaseCommands != "" {
+		// Der Stub gehört dann zu genau diesen Basis-Commands
+		sum := sha256.Sum256([]byte(opts.trustedBaseCommands))
+		name += "-" + hex.EncodeToString(sum[:8])
+	}
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// runnerSourceHash identifiziert Template, proxy-Paket und Build-Flags dieser ProxyBuild-Version
+func runnerSourceHash() (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", strings.Join(runnerBuildFlags, " "))
+	h.Write(templateSource)
+	h.Write(runnerSource)
+	h.Write([]byte(proxyGoMod))
+	err := fs.WalkDir(proxySources, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := proxySources.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", path, len(data))
+		h.Write(data)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// stubCacheDir liefert das Cache-Verzeichnis für Stubs dieser ProxyBuild-Version
+func stubCacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	hash, err := runnerSourceHash()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "proxybuild", "stubs", hash[:16]), nil
+}
+
+// findStub sucht einen Runner-Stub in -stub-dir, neben dem ProxyBuild-Executable
+// und im Cache. Fehlt er, wird er mit der Go-Toolchain gebaut und zwischengespeichert.
+func findStub(opts BuildOptions) (string, error) {
+	name := stubName(opts)
+
+	var searched []string
+	if opts.StubDir != "" {
+		searched = append(searched, opts.StubDir)
+	}
+	if exe, err := os.Executable(); err == nil {
+		searched = append(searched, filepath.Join(filepath.Dir(exe), "stubs"))
+	}
+	cacheDir, err := stubCacheDir()
+	if err != nil {
+		return "", err
+	}
+	searched = append(searched, cacheDir)
+
+	for _, dir := range searched {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		return "", fmt.Errorf("kein Runner-Stub %s gefunden (gesucht in %s) und keine Go-Toolchain verfügbar. Nutze -stub-dir oder -strategy compile",
+			name, strings.Join(searched, ", "))
+	}
+
+	fmt.Printf("Baue Runner-Stub für %s/%s...\n", targetOS(opts), targetArch(opts))
+	path := filepath.Join(cacheDir, name)
+	if err := buildStub(opts, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// buildStub kompiliert einen Runner ohne Bundle nach path
+func buildStub(opts BuildOptions, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Über eine temporäre Datei, damit parallele Builds nie einen halben Stub sehen
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	defer os.Remove(tmp)
+	if err := compileRunner(opts, nil, tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// buildStubs baut Runner-Stubs zum Ausliefern mit ProxyBuild nach dir
+func buildStubs(dir string, opts BuildOptions) error {
+	if _, err := loadSigner(&opts); err != nil {
+		return err
+	}
+	if opts.configPublicKey != "" && opts.TamperPolicy == proxy.PolicyNoHooks {
+		return fmt.Errorf("mit -tamper-policy %s wird das Basis-Command in den Runner kompiliert, dafür gibt es keine vorgebauten Stubs", proxy.PolicyNoHooks)
+	}
+	path := filepath.Join(dir, stubName(opts))
+	if err := buildStub(opts, path); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Runner-Stub erstellt: %s\n", path)
+	return nil
+}
+
+// buildFromStub kopiert den Runner-Stub und hängt das Bundle als Trailer an
+func buildFromStub(opts BuildOptions, bundle []byte, outputPath string) error {
+	stubPath, err := findStub(opts)
+	if err != nil {
+		return err
+	}
+	stub, err := os.ReadFile(stubPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Erstelle %s aus Runner-Stub (%s/%s)...\n", filepath.Base(outputPath), targetOS(opts), targetArch(opts))
+	data, err := appendBundle(stub, bundle)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputPath, data, 0755)
+}
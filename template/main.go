@@ -1,26 +1,20 @@
 package main
 
-import (
-	_ "embed"
-	"encoding/json"
-	"fmt"
-	"os"
+import "ProxyBuild/proxy"
 
-	"ProxyBuild/proxy"
-)
-
-//go:embed config.json
-var embeddedConfig []byte
+// Standard-Template. Eigene Templates (-template <dir>) müssen dieselben
+// Funktionen definieren, ProxyBuild prüft das vor dem Kompilieren.
 
 func main() {
-	var config proxy.Config
-	if err := json.Unmarshal(embeddedConfig, &config); err != nil {
-		fmt.Fprintf(os.Stderr, "Fehler beim Laden der Konfiguration: %v\n", err)
-		os.Exit(1)
-	}
+	runProxy()
+}
 
-	if err := proxy.Run(&config, os.Args[1:]); err != nil {
-		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
-		os.Exit(1)
-	}
+// setupProxy wird nach dem Laden und Prüfen der Konfiguration aufgerufen, vor
+// Hooks und Basis-Command. Ein Fehler bricht den Aufruf ab.
+func setupProxy(config *proxy.Config) error {
+	return nil
 }
+
+// finishProxy wird nach dem Basis-Command und allen Hooks aufgerufen, err ist
+// das Ergebnis von proxy.Run
+func finishProxy(config *proxy.Config, err error) {}
@@ -0,0 +1,171 @@
+package main
+
+// runner.go wird bei jedem Build unverändert geschrieben. Eigene Templates
+// (-template) ersetzen nur main.go, siehe README "Eigene Templates".
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+
+	"ProxyBuild/proxy"
+)
+
+// Beim Compile-Build enthält bundle.bin die Konfiguration. Bei einem
+// Runner-Stub ist die Datei leer und das Bundle hängt am Executable.
+//
+//go:embed bundle.bin
+var embeddedBundle []byte
+
+// Werden beim Build mit -sign-key per -ldflags "-X" gesetzt. Der öffentliche
+// Schlüssel steckt damit im Code des Runners und nicht im änderbaren Bundle,
+// ebenso mit -tamper-policy no-hooks die Basis-Commands (proxy.TrustedBaseCommands).
+var (
+	configPublicKey     string
+	configPolicy        string
+	trustedBaseCommands string
+)
+
+// Beim Compile-Build per -ldflags "-X" gesetzte Build-Metadaten (proxy.EncodeMetadata)
+var buildMetadata string
+
+// Mit -codegen setzt die generierte Datei config_gen.go die Konfiguration als
+// Go-Code. Das JSON im Bundle bleibt für -inspect und die Signaturprüfung.
+// Bei einem Multi-Call-Executable stehen die Konfigurationen je Befehl in
+// generatedConfigs.
+var (
+	generatedConfig  *proxy.Config
+	generatedConfigs map[string]*proxy.Config
+)
+
+// runProxy lädt das Bundle, beantwortet die Meta-Commands und führt den Proxy
+// aus. main.go des Templates muss es aufrufen.
+func runProxy() {
+	var sections map[string][]byte
+	var err error
+	if len(embeddedBundle) > 0 {
+		sections, err = proxy.DecodeBundle(embeddedBundle)
+	} else {
+		sections, err = proxy.ReadExecutableBundle()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler beim Laden der Konfiguration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Ein Multi-Call-Executable wählt die Konfiguration über den Aufrufnamen
+	// oder das erste Argument
+	names := proxy.MultiCallNames(sections)
+	name, args := proxy.SelectCommand(names, os.Args)
+
+	// Meta-Commands zum Prüfen installierter Kopien
+	if len(args) > 0 {
+		switch args[0] {
+		case "--proxy-verify":
+			if err := proxy.WriteVerifyReport(os.Stdout, sections, configPublicKey, configPolicy); err != nil {
+				os.Exit(1)
+			}
+			return
+		case "--proxy-version":
+			meta, err := proxy.BundleMetadata(sections, buildMetadata)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+				os.Exit(1)
+			}
+			proxy.WriteMetadata(os.Stdout, meta)
+			return
+		case "--proxy-install-links":
+			if len(args) != 2 {
+				fmt.Fprintln(os.Stderr, "Verwendung: --proxy-install-links <verzeichnis>")
+				os.Exit(1)
+			}
+			if err := proxy.InstallLinks(os.Stdout, args[1], names); err != nil {
+				fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "--proxy-update":
+			// Die update-Section ist in allen Konfigurationen eines Multi-Call-Executables gleich
+			if name == "" && len(names) > 0 {
+				name = names[0]
+			}
+			if err := updateProxy(sections, name); err != nil {
+				fmt.Fprintf(os.Stderr, "Fehler beim Update: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "--proxy-rollback":
+			exe, err := proxy.Rollback()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Fehler beim Rollback: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("✓ Vorherige Version von %s wiederhergestellt\n", exe)
+			return
+		}
+	}
+	if len(names) > 0 && name == "" {
+		fmt.Fprintf(os.Stderr, "Fehler: unbekannter Befehl, verfügbar: %s\n", strings.Join(names, ", "))
+		fmt.Fprintln(os.Stderr, "Aufruf über einen Link (--proxy-install-links <verzeichnis>) oder mit dem Befehl als erstem Argument")
+		os.Exit(1)
+	}
+
+	config, err := loadProxyConfig(sections, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler beim Laden der Konfiguration: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := setupProxy(config); err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+	err = proxy.Run(config, args)
+	finishProxy(config, err)
+	if config.Update != nil {
+		meta, _ := proxy.BundleMetadata(sections, buildMetadata)
+		proxy.AutoUpdate(os.Stderr, config.Update, meta)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadProxyConfig liefert die geprüfte Konfiguration für den Befehl name ("" ohne Multi-Call)
+func loadProxyConfig(sections map[string][]byte, name string) (*proxy.Config, error) {
+	generated := generatedConfig
+	if name != "" {
+		generated = generatedConfigs[name]
+	}
+	if generated != nil {
+		return proxy.TrustGeneratedConfig(generated, sections, name, configPublicKey, configPolicy, trustedBaseCommands)
+	}
+	return proxy.LoadTrustedConfigFor(sections, name, configPublicKey, configPolicy, trustedBaseCommands)
+}
+
+// updateProxy beantwortet --proxy-update
+func updateProxy(sections map[string][]byte, name string) error {
+	config, err := loadProxyConfig(sections, name)
+	if err != nil {
+		return err
+	}
+	if config.Update == nil {
+		return fmt.Errorf("die Konfiguration enthält keine update-Section")
+	}
+	meta, err := proxy.BundleMetadata(sections, buildMetadata)
+	if err != nil {
+		return err
+	}
+	result, err := proxy.Update(config.Update, meta)
+	if err != nil {
+		return err
+	}
+	if !result.Updated {
+		fmt.Printf("Kein Update: %s\n", result.Reason)
+		return nil
+	}
+	fmt.Printf("✓ Aktualisiert auf Build %s (sha256:%s), vorherige Version mit --proxy-rollback\n", result.Artifact.BuildTime, result.Artifact.SHA256)
+	return nil
+}
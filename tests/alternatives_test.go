@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestLoadConfig_BaseCommandAlternatives(t *testing.T) {
+	config, err := proxy.LoadConfig([]byte(`{"base_command": ["docker-compose", "docker compose"], "base_command_probe": ["version"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.BaseCommand != "docker-compose" || !reflect.DeepEqual(config.BaseCommandAlternatives, []string{"docker-compose", "docker compose"}) {
+		t.Errorf("Unexpected base command %q, alternatives %v", config.BaseCommand, config.BaseCommandAlternatives)
+	}
+
+	// Die Liste bleibt beim Serialisieren erhalten
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"base_command":["docker-compose","docker compose"]`) {
+		t.Errorf("Expected list in JSON, got %s", data)
+	}
+	again, err := proxy.LoadConfig(data)
+	if err != nil || !reflect.DeepEqual(again, config) {
+		t.Errorf("Round trip changed the config: %+v, %v", again, err)
+	}
+
+	// Einzelne Programme gehen auch mit direct
+	if _, err := proxy.LoadConfig([]byte(`{"base_command": ["docker-compose", "podman-compose"], "executor": "direct"}`)); err != nil {
+		t.Errorf("Unexpected error for direct alternatives: %v", err)
+	}
+
+	single, err := proxy.LoadConfig([]byte(`{"base_command": ["git"]}`))
+	if err != nil || single.BaseCommand != "git" || single.BaseCommandAlternatives != nil {
+		t.Errorf("Expected single base command, got %+v, %v", single, err)
+	}
+
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"empty alternative", `{"base_command": ["docker-compose", " "]}`, "base_command[1] ist leer"},
+		{"invalid template", `{"base_command": ["docker-compose", "{{.Nope}}"]}`, "base_command[1]"},
+		{"pinned", `{"base_command": ["docker-compose", "docker compose"], "base_command_path": "/usr/bin/docker-compose"}`, "nur mit einem einzelnen base_command"},
+		{"no strings", `{"base_command": [1, 2]}`, "base_command"},
+		{"direct with sub-command", `{"base_command": ["docker-compose", "docker compose"], "executor": "direct"}`, `base_command[1] "docker compose": mit executor direct`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := proxy.LoadConfig([]byte(tt.json))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestBuildBaseCommandAlternatives(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the sh executor")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	for name, args := range map[string][]string{"stub": {"-strategy", "stub"}, "codegen": {"-codegen"}} {
+		t.Run(name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			mkdirs(t, tmpDir, "config")
+			configFile := filepath.Join(tmpDir, "config", "config.json")
+			writeFiles(t, filepath.Dir(configFile), map[string]string{"config.json": `{
+				"base_command": ["missing-tool", "multi sub", "single"],
+				"base_command_probe": ["--probe"],
+				"executor": "shell"
+			}`})
+			tool := filepath.Join(tmpDir, "tool")
+			if out, err := exec.Command(proxyBuild, append([]string{"-build", configFile, "-output", tool}, args...)...).CombinedOutput(); err != nil {
+				t.Fatalf("build failed: %v\n%s", err, out)
+			}
+
+			bin := filepath.Join(tmpDir, "bin")
+			// multi kennt das Sub-Command noch nicht, die Probe schlägt fehl
+			writeScript(t, filepath.Join(bin, "multi"), `[ "$2" = --probe ] && exit 3; echo "multi $*"`)
+			writeScript(t, filepath.Join(bin, "single"), `echo "single $*"`)
+
+			cacheDir := t.TempDir()
+			run := func() (string, error) {
+				t.Helper()
+				cmd := exec.Command(tool, "x")
+				cmd.Env = append(cacheEnv(t, cacheDir), "PATH="+bin+string(os.PathListSeparator)+"/bin:/usr/bin")
+				out, err := cmd.CombinedOutput()
+				return string(out), err
+			}
+
+			if out, err := run(); err != nil || out != "single x\n" {
+				t.Fatalf("Expected single: %v\n%s", err, out)
+			}
+
+			// Die Wahl ist gespeichert, auch wenn multi jetzt funktioniert
+			writeScript(t, filepath.Join(bin, "multi"), `echo "multi $*"`)
+			if out, err := run(); err != nil || out != "single x\n" {
+				t.Errorf("Expected cached choice: %v\n%s", err, out)
+			}
+
+			// Fehlt der gewählte Befehl, wird neu gewählt
+			os.Remove(filepath.Join(bin, "single"))
+			if out, err := run(); err != nil || out != "multi sub x\n" {
+				t.Errorf("Expected multi after single was removed: %v\n%s", err, out)
+			}
+
+			// Auf einem anderen Rechner (leerer Cache) wird die Probe wieder ausgeführt
+			writeScript(t, filepath.Join(bin, "multi"), `[ "$2" = --probe ] && exit 3; echo "multi $*"`)
+			cacheDir = t.TempDir()
+			out, err := run()
+			want := "versucht: missing-tool (missing-tool nicht gefunden), multi sub (multi sub --probe: exit status 3), single (single nicht gefunden)"
+			if err == nil || !strings.Contains(out, want) {
+				t.Errorf("Expected error listing all alternatives: %v\n%s", err, out)
+			}
+		})
+	}
+}
+
+func TestBuildBaseCommandAlternatives_DefaultProbe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the sh executor")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.json")
+	writeFiles(t, tmpDir, map[string]string{"config.json": `{"base_command": ["multi sub", "single"]}`})
+	tool := filepath.Join(tmpDir, "tool")
+	if out, err := exec.Command(proxyBuild, "-build", configFile, "-output", tool, "-strategy", "stub").CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+
+	bin := filepath.Join(tmpDir, "bin")
+	// multi gibt es, das Sub-Command nicht: ohne base_command_probe prüft "multi sub --help"
+	writeScript(t, filepath.Join(bin, "multi"), `[ "$1" = sub ] && [ "$2" = --help ] && exit 1; echo "multi $*"`)
+	writeScript(t, filepath.Join(bin, "single"), `echo "single $*"`)
+
+	cmd := exec.Command(tool, "x")
+	cmd.Env = append(cacheEnv(t, t.TempDir()), "PATH="+bin+string(os.PathListSeparator)+"/bin:/usr/bin")
+	if out, err := cmd.CombinedOutput(); err != nil || string(out) != "single x\n" {
+		t.Errorf("Expected single after the default probe of multi sub failed: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestEncodeAssets_RoundTrip(t *testing.T) {
+	files := []proxy.AssetFile{
+		{Name: "scripts/pre-up.sh", Data: []byte("#!/bin/sh\necho up\n"), Executable: true},
+		{Name: "scripts/lib/data.txt", Data: []byte("data\n")},
+	}
+	archive, err := proxy.EncodeAssets(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Reihenfolge der Eingabe spielt keine Rolle
+	again, err := proxy.EncodeAssets([]proxy.AssetFile{files[1], files[0]})
+	if err != nil || !bytes.Equal(archive, again) {
+		t.Errorf("Encoding should be deterministic: %v", err)
+	}
+
+	decoded, err := proxy.DecodeAssets(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []proxy.AssetFile{files[1], files[0]}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("Unexpected files %+v", decoded)
+	}
+
+	if _, err := proxy.EncodeAssets([]proxy.AssetFile{{Name: "../escape"}}); err == nil {
+		t.Error("Expected error for path outside the asset dir")
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "/etc/passwd", Mode: 0644})
+	tw.Close()
+	if _, err := proxy.DecodeAssets(buf.Bytes()); err == nil {
+		t.Error("Expected error for absolute path in archive")
+	}
+}
+
+func TestExtractAssets(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
+	t.Setenv("HOME", cacheDir)
+	t.Setenv("LocalAppData", cacheDir)
+
+	archive, err := proxy.EncodeAssets([]proxy.AssetFile{{Name: "scripts/run.sh", Data: []byte("original")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := proxy.ExtractAssets(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "scripts", "run.sh")
+	if data, err := os.ReadFile(script); err != nil || string(data) != "original" {
+		t.Fatalf("Unexpected content %q: %v", data, err)
+	}
+
+	// Veränderte Dateien werden beim nächsten Aufruf ersetzt
+	os.WriteFile(script, []byte("tampered"), 0600)
+	again, err := proxy.ExtractAssets(archive)
+	if err != nil || again != dir {
+		t.Fatalf("Expected same dir %s, got %s: %v", dir, again, err)
+	}
+	if data, _ := os.ReadFile(script); string(data) != "original" {
+		t.Errorf("Tampered file should be restored, got %q", data)
+	}
+
+	// Anderer Inhalt, anderes Verzeichnis
+	other, _ := proxy.EncodeAssets([]proxy.AssetFile{{Name: "scripts/run.sh", Data: []byte("v2")}})
+	if otherDir, err := proxy.ExtractAssets(other); err != nil || otherDir == dir {
+		t.Errorf("Expected separate dir for new content, got %s: %v", otherDir, err)
+	}
+}
+
+func TestLoadConfig_EmbedDirs(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"valid", `{"base_command": "echo", "embed_dirs": ["scripts"], "hooks": {"up": [{"command": "asset:scripts/pre-up.sh", "when": "before"}]}}`, ""},
+		{"asset dir template", `{"base_command": "echo", "embed_dirs": ["scripts"], "hooks": {"up": [{"command": "sh", "args": ["{{.AssetDir}}/scripts/x.sh"], "when": "before"}]}}`, ""},
+		{"absolute dir", `{"base_command": "echo", "embed_dirs": ["/etc"]}`, "embed_dirs[0]"},
+		{"outside", `{"base_command": "echo", "embed_dirs": ["../shared"]}`, "embed_dirs[0]"},
+		{"not embedded", `{"base_command": "echo", "embed_dirs": ["scripts"], "hooks": {"up": [{"command": "asset:tools/x.sh", "when": "before"}]}}`, "liegt in keinem Verzeichnis aus embed_dirs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := proxy.LoadConfig([]byte(tt.json))
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestBuildEmbedDirs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the sh executor")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"scripts/pre-up.sh":    "#!/bin/sh\necho \"pre-up $1\"\n",
+		"scripts/lib/data.txt": "data\n",
+	})
+	configFile := writeConfig(t, tmpDir, proxy.Config{
+		BaseCommand: "echo base",
+		Executor:    proxy.ExecutorShell,
+		EmbedDirs:   []string{"scripts"},
+		Hooks: map[string][]proxy.Hook{
+			"up": {
+				{Command: "asset:scripts/pre-up.sh", Args: []string{"{{.SubCommand}}"}, When: "before"},
+				{Command: "cat", Args: []string{"{{.AssetDir}}/scripts/lib/data.txt"}, When: "after"},
+			},
+		},
+	})
+
+	for mode, flags := range map[string][]string{
+		"stub":    {"-strategy", "stub"},
+		"codegen": {"-codegen"},
+	} {
+		t.Run(mode, func(t *testing.T) {
+			output := filepath.Join(tmpDir, mode+"-proxy")
+			args := append([]string{"-build", configFile, "-output", output}, flags...)
+			if out, err := exec.Command(proxyBuild, args...).CombinedOutput(); err != nil {
+				t.Fatalf("build failed: %v\n%s", err, out)
+			}
+			// Die Quellen werden zur Laufzeit nicht mehr gebraucht
+			cacheDir := t.TempDir()
+			runDir := t.TempDir()
+			run := func() string {
+				t.Helper()
+				cmd := exec.Command(output, "up", "-d")
+				cmd.Dir = runDir
+				cmd.Env = cacheEnv(t, cacheDir)
+				out, err := cmd.CombinedOutput()
+				if err != nil {
+					t.Fatalf("proxy failed: %v\n%s", err, out)
+				}
+				return string(out)
+			}
+
+			want := "pre-up up\nbase up -d\ndata\n"
+			if out := run(); out != want {
+				t.Errorf("Unexpected output %q, want %q", out, want)
+			}
+
+			// Eine veränderte Datei im Cache wird vor dem nächsten Aufruf ersetzt
+			scripts, _ := filepath.Glob(filepath.Join(cacheDir, "*", "proxybuild", "assets", "*", "scripts", "pre-up.sh"))
+			if len(scripts) == 0 {
+				scripts, _ = filepath.Glob(filepath.Join(cacheDir, "proxybuild", "assets", "*", "scripts", "pre-up.sh"))
+			}
+			if len(scripts) != 1 {
+				t.Fatalf("Expected one extracted script, got %v", scripts)
+			}
+			os.WriteFile(scripts[0], []byte("#!/bin/sh\necho tampered\n"), 0700)
+			if out := run(); out != want {
+				t.Errorf("Tampered asset should be restored, got %q", out)
+			}
+		})
+	}
+
+	out, err := exec.Command(proxyBuild, "-inspect", filepath.Join(tmpDir, "stub-proxy")).CombinedOutput()
+	if err != nil || !strings.Contains(string(out), "Assets:        2 Dateien") || !strings.Contains(string(out), "scripts/pre-up.sh") {
+		t.Errorf("-inspect should list the assets: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestLoadConfig_BuildHooksAndSmokeTests(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"valid", `{"base_command": "git", "build_hooks": [{"command": "make", "when": "before"}], "smoke_tests": [{"args": ["status"], "output_match": "^git"}]}`, ""},
+		{"missing when", `{"base_command": "git", "build_hooks": [{"command": "make"}]}`, "build_hooks[0]: when muss before oder after sein"},
+		{"invalid regexp", `{"base_command": "git", "smoke_tests": [{"output_match": "("}]}`, "smoke_tests[0].output_match"},
+		{"absolute base command", `{"base_command": "/usr/bin/git", "smoke_tests": [{"args": ["status"]}]}`, "setze real_command"},
+		{"real command", `{"base_command": "/usr/bin/git", "smoke_tests": [{"args": ["--version"], "real_command": true}]}`, ""},
+		{"stub and real command", `{"base_command": "git", "smoke_tests": [{"real_command": true, "stub": {"output": "x"}}]}`, "schließen sich aus"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := proxy.LoadConfig([]byte(tt.json))
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestBuildHooksAndSmokeTests(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the sh executor")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	onError := true
+	config := proxy.Config{
+		BaseCommand: "deploytool --verbose",
+		Executor:    proxy.ExecutorShell,
+		Hooks: map[string][]proxy.Hook{
+			"release": {
+				{Command: "echo", Args: []string{"audit release"}, When: "before"},
+				{Command: "echo", Args: []string{"rollback"}, When: "after", Conditions: proxy.Conditions{OnError: &onError}},
+			},
+		},
+		BuildHooks: []proxy.BuildHook{
+			{Command: "echo", Args: []string{"$PROXYBUILD_TARGET > generated.txt"}, When: "before"},
+			{Command: "echo", Args: []string{"$PROXYBUILD_OUTPUT > uploaded.txt"}, When: "after"},
+		},
+		SmokeTests: []proxy.SmokeTest{
+			{Name: "release", Args: []string{"release", "v1"}, OutputContains: []string{"audit release", "deploytool --verbose release v1"}},
+			{Name: "failing base command", Args: []string{"release"}, Stub: &proxy.SmokeStub{Output: "boom\n", ExitCode: 2}, OutputMatch: `(?s)boom.*rollback`},
+		},
+	}
+	configFile := writeConfig(t, tmpDir, config)
+	output := filepath.Join(tmpDir, "deploy-proxy")
+
+	out, err := exec.Command(proxyBuild, "-build", configFile, "-output", output).CombinedOutput()
+	if err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+	for _, want := range []string{"✓ Smoke-Test release", "✓ Smoke-Test failing base command"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("Expected %q in output:\n%s", want, out)
+		}
+	}
+
+	// Build-Hooks laufen im Verzeichnis der Konfiguration
+	data, err := os.ReadFile(filepath.Join(tmpDir, "generated.txt"))
+	if err != nil || string(data) != runtime.GOOS+"/"+runtime.GOARCH+"\n" {
+		t.Errorf("before hook: %q, %v", data, err)
+	}
+	data, err = os.ReadFile(filepath.Join(tmpDir, "uploaded.txt"))
+	if err != nil || string(data) != output+"\n" {
+		t.Errorf("after hook: %q, %v", data, err)
+	}
+
+	// Ein fehlgeschlagener Smoke-Test bricht den Build vor den after-Hooks ab
+	os.Remove(filepath.Join(tmpDir, "uploaded.txt"))
+	config.SmokeTests = append(config.SmokeTests, proxy.SmokeTest{Name: "broken", Args: []string{"status"}, OutputContains: []string{"on branch main"}})
+	writeConfig(t, tmpDir, config)
+
+	previous, _ := os.ReadFile(output)
+	out, err = exec.Command(proxyBuild, "-build", configFile, "-output", output).CombinedOutput()
+	if err == nil {
+		t.Fatalf("Expected failing smoke test to fail the build:\n%s", out)
+	}
+	// Der fehlerhafte Proxy landet nicht am Ausgabepfad, auch kein Zwischenstand
+	if data, _ := os.ReadFile(output); !bytes.Equal(data, previous) {
+		t.Error("A proxy failing its smoke tests must not replace the output")
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(tmpDir, ".proxybuild-*")); len(leftovers) > 0 {
+		t.Errorf("Staging directory left behind: %v", leftovers)
+	}
+	for _, want := range []string{"1 von 3 Smoke-Tests fehlgeschlagen", `broken: Ausgabe enthält "on branch main" nicht`, "deploytool --verbose status"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("Expected %q in output:\n%s", want, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "uploaded.txt")); err == nil {
+		t.Error("after hook should not run when a smoke test fails")
+	}
+}
@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+// writeFiles legt Dateien relativ zu dir an
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuildCustomTemplate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the sh executor")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo base $TEAM"})
+	templateDir := filepath.Join(tmpDir, "template")
+	writeFiles(t, templateDir, map[string]string{
+		"main.go": `package main
+
+import (
+	"fmt"
+	"os"
+
+	p "ProxyBuild/proxy"
+	"generated-proxy/telemetry"
+)
+
+func main() {
+	runProxy()
+}
+
+func setupProxy(config *p.Config) error {
+	if os.Getenv("DENY") != "" {
+		return fmt.Errorf("denied by %s", binaryName)
+	}
+	config.EnvVars = map[string]string{"TEAM": "platform"}
+	return nil
+}
+
+func finishProxy(config *p.Config, err error) {
+	telemetry.Report(binaryName, target, err)
+}
+`,
+		"vars.go.tmpl": `package main
+
+const binaryName = {{printf "%q" .BinaryName}}
+const target = {{printf "%q" .Target}}
+const configName = {{printf "%q" .ConfigPath}}
+`,
+		"telemetry/telemetry.go": `package telemetry
+
+import "fmt"
+
+func Report(name, target string, err error) {
+	fmt.Printf("telemetry %s %s %v\n", name, target, err)
+}
+`,
+		"main_test.go": `package main`,
+	})
+
+	output := filepath.Join(tmpDir, "custom")
+	if out, err := exec.Command(proxyBuild, "-build", configFile, "-template", templateDir, "-output", output).CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+
+	out, err := exec.Command(output, "ok").CombinedOutput()
+	if err != nil {
+		t.Fatalf("proxy failed: %v\n%s", err, out)
+	}
+	// TEAM setzt setupProxy in env_vars
+	want := "base platform ok\ntelemetry custom " + runtime.GOOS + "/" + runtime.GOARCH + " <nil>\n"
+	if string(out) != want {
+		t.Errorf("Unexpected output %q, want %q", out, want)
+	}
+
+	cmd := exec.Command(output, "ok")
+	cmd.Env = append(os.Environ(), "DENY=1")
+	out, err = cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "denied by custom") {
+		t.Errorf("setupProxy error should abort the call: %v\n%s", err, out)
+	}
+}
+
+func TestBuildCustomTemplate_Contract(t *testing.T) {
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo"})
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name:  "missing functions",
+			files: map[string]string{"main.go": "package main\n\nfunc main() { runProxy() }\n"},
+			want:  []string{"Vertragsfunktion setupProxy fehlt", "Vertragsfunktion finishProxy fehlt"},
+		},
+		{
+			name: "wrong signature",
+			files: map[string]string{"main.go": `package main
+
+import "ProxyBuild/proxy"
+
+func main() { runProxy() }
+func setupProxy() error { return nil }
+func finishProxy(config *proxy.Config, err error) {}
+`},
+			want: []string{"setupProxy hat die Signatur func() error"},
+		},
+		{
+			name: "main without runProxy",
+			files: map[string]string{"main.go": `package main
+
+import "ProxyBuild/proxy"
+
+func main() {}
+func setupProxy(config *proxy.Config) error { return nil }
+func finishProxy(config *proxy.Config, err error) {}
+`},
+			want: []string{"main muss runProxy() aufrufen"},
+		},
+		{
+			name:  "reserved names",
+			files: map[string]string{"main.go": "package main\n\nvar embeddedBundle []byte\n", "runner.go": "package main\n"},
+			want:  []string{"embeddedBundle ist in runner.go definiert", "runner.go ist reserviert"},
+		},
+		{
+			name:  "module without go.mod",
+			files: map[string]string{"main.go": "package main\n\nimport _ \"github.com/example/lib\"\n"},
+			want:  []string{"main.go importiert github.com/example/lib", "go.mod (module generated-proxy) und go.sum"},
+		},
+		{
+			name:  "wrong go.mod",
+			files: map[string]string{"main.go": "package main\n", "go.mod": "module x\n\ngo 1.24\n"},
+			want:  []string{`go.mod: module "x", erwartet module generated-proxy`},
+		},
+		{
+			name:  "unknown template variable",
+			files: map[string]string{"main.go.tmpl": "package main\n\nconst x = {{.Unknown}}\n"},
+			want:  []string{"main.go.tmpl", "Unknown"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templateDir := filepath.Join(tmpDir, strings.ReplaceAll(tt.name, " ", "-"))
+			writeFiles(t, templateDir, tt.files)
+
+			output := filepath.Join(tmpDir, "proxy")
+			out, err := exec.Command(proxyBuild, "-build", configFile, "-template", templateDir, "-output", output).CombinedOutput()
+			if err == nil {
+				t.Fatalf("Expected build to fail:\n%s", out)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(out), want) {
+					t.Errorf("Expected %q in error:\n%s", want, out)
+				}
+			}
+			if strings.Contains(string(out), "Kompiliere") {
+				t.Error("Template should be rejected before compiling")
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestBundle_RoundTrip(t *testing.T) {
+	sections := map[string][]byte{
+		"config": []byte(`{"base_command": "echo"}`),
+		"empty":  {},
+	}
+
+	decoded, err := proxy.DecodeBundle(proxy.EncodeBundle(sections))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded["config"], sections["config"]) {
+		t.Errorf("Unexpected config section: %q", decoded["config"])
+	}
+	if data, ok := decoded["empty"]; !ok || len(data) != 0 {
+		t.Error("Empty section should be preserved")
+	}
+}
+
+func TestBundle_StableEncoding(t *testing.T) {
+	a := proxy.EncodeBundle(map[string][]byte{"a": []byte("1"), "b": []byte("2")})
+	b := proxy.EncodeBundle(map[string][]byte{"b": []byte("2"), "a": []byte("1")})
+	if !bytes.Equal(a, b) {
+		t.Error("Encoding should not depend on map order")
+	}
+}
+
+func TestBundle_TamperDetected(t *testing.T) {
+	bundle := proxy.EncodeBundle(map[string][]byte{"config": []byte(`{"base_command": "echo"}`)})
+	bundle[5] ^= 0xFF
+
+	if _, err := proxy.DecodeBundle(bundle); err == nil {
+		t.Error("Expected checksum error for modified bundle")
+	}
+}
+
+func TestBundle_FindWithTrailingData(t *testing.T) {
+	bundle := proxy.EncodeBundle(map[string][]byte{"config": []byte("cfg")})
+
+	// Bundle mitten in einer Datei, z.B. vor einer angehängten Signatur
+	var data []byte
+	data = append(data, []byte("binary prefix PXBNDL01 with fake magic")...)
+	data = append(data, bundle...)
+	data = append(data, []byte("signature block")...)
+
+	path := filepath.Join(t.TempDir(), "runner")
+	if err := os.WriteFile(path, data, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sections, err := proxy.ReadBundleFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sections["config"]) != "cfg" {
+		t.Errorf("Unexpected config section: %q", sections["config"])
+	}
+}
+
+func TestBundle_Missing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runner")
+	if err := os.WriteFile(path, []byte("no bundle here"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := proxy.ReadBundleFile(path); !errors.Is(err, proxy.ErrNoBundle) {
+		t.Errorf("Expected ErrNoBundle, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+// cacheEnv leitet den Cache-Ordner von ProxyBuild in ein temporäres Verzeichnis
+// um. Der Go-Build-Cache bleibt, damit nicht die Standardbibliothek neu gebaut wird.
+func cacheEnv(t *testing.T, dir string) []string {
+	t.Helper()
+	goCache, err := exec.Command("go", "env", "GOCACHE").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(os.Environ(),
+		"GOCACHE="+strings.TrimSpace(string(goCache)),
+		"XDG_CACHE_HOME="+dir,
+		"HOME="+dir,
+		"LocalAppData="+dir,
+	)
+}
+
+func TestBuildCache(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	cacheDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo cached"})
+	env := cacheEnv(t, cacheDir)
+
+	// Gleicher Dateiname in eigenen Verzeichnissen, der Name steht in den Metadaten
+	output := func(name string) string {
+		os.MkdirAll(filepath.Join(tmpDir, name), 0755)
+		return filepath.Join(tmpDir, name, "proxy")
+	}
+	build := func(name string, args ...string) string {
+		t.Helper()
+		args = append([]string{"-build", configFile, "-strategy", "compile", "-output", output(name)}, args...)
+		cmd := exec.Command(proxyBuild, args...)
+		cmd.Env = env
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("build failed: %v\n%s", err, out)
+		}
+		return string(out)
+	}
+	stats := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command(proxyBuild, append([]string{"-cache-stats"}, args...)...)
+		cmd.Env = env
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("-cache-stats failed: %v\n%s", err, out)
+		}
+		return string(out)
+	}
+
+	if out := build("first"); !strings.Contains(out, "Kompiliere") {
+		t.Errorf("First build should compile:\n%s", out)
+	}
+	if out := build("second"); !strings.Contains(out, "aus dem Build-Cache") {
+		t.Errorf("Second build should be a cache hit:\n%s", out)
+	}
+	first, _ := os.ReadFile(output("first"))
+	second, _ := os.ReadFile(output("second"))
+	if !bytes.Equal(first, second) {
+		t.Error("Cache hit should yield the same executable")
+	}
+	out, err := exec.Command(output("second"), "ok").CombinedOutput()
+	if err != nil || strings.TrimSpace(string(out)) != "cached ok" {
+		t.Errorf("Cached proxy does not run: %v\n%s", err, out)
+	}
+
+	if out := build("third", "-no-cache"); !strings.Contains(out, "Kompiliere") {
+		t.Errorf("-no-cache should compile:\n%s", out)
+	}
+
+	report := stats()
+	for _, want := range []string{"Einträge:     1", "Treffer:      1", "Fehlschläge:  1"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Expected %q in cache stats:\n%s", want, report)
+		}
+	}
+
+	// Go-Einstellungen aus der Umgebung verändern das Executable
+	cmd := exec.Command(proxyBuild, "-build", configFile, "-strategy", "compile", "-output", output("tagged"))
+	cmd.Env = append(env, "GOFLAGS=-tags=proxybuildtest")
+	if out, err := cmd.CombinedOutput(); err != nil || !strings.Contains(string(out), "Kompiliere") {
+		t.Errorf("Changed GOFLAGS should not hit the cache: %v\n%s", err, out)
+	}
+
+	// Parallele Builds zählen jeden Treffer
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cmd := exec.Command(proxyBuild, "-build", configFile, "-strategy", "compile", "-output", output(fmt.Sprintf("parallel-%d", i)))
+			cmd.Env = env
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("parallel build failed: %v\n%s", err, out)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if report := stats(); !strings.Contains(report, "Treffer:      5") || !strings.Contains(report, "Fehlschläge:  2") {
+		t.Errorf("Expected 5 hits and 2 misses:\n%s", report)
+	}
+
+	// Eine geänderte Konfiguration ergibt einen neuen Eintrag
+	writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo changed"})
+	if out := build("changed"); !strings.Contains(out, "Kompiliere") {
+		t.Errorf("Changed config should not hit the cache:\n%s", out)
+	}
+	if report := stats(); !strings.Contains(report, "Einträge:     3") {
+		t.Errorf("Expected two cache entries:\n%s", report)
+	}
+
+	// Zu kleiner Cache: alle Einträge werden entfernt
+	writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo pruned"})
+	build("pruned", "-cache-max-size", "1")
+	if report := stats(); !strings.Contains(report, "Einträge:     0") {
+		t.Errorf("Expected cache to be pruned:\n%s", report)
+	}
+}
@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+// runCaptureConfig führt einen before-Hook mit capture_as aus und schreibt
+// die Variable über einen zweiten Hook in eine Datei
+func runCaptureConfig(t *testing.T, output string, opts *proxy.CaptureOptions) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+
+	outFile := filepath.Join(t.TempDir(), "out.txt")
+	config := proxy.Config{
+		BaseCommand: "true",
+		Hooks: map[string][]proxy.Hook{
+			"up": {
+				{Command: "printf", Args: []string{"'" + output + "'"}, When: "before", CaptureAs: "VALUE", Capture: opts},
+				{Command: "printf", Args: []string{"'%s|%s'", "{{.Vars.VALUE}}", "\"$VALUE\"", ">", outFile}, When: "before"},
+			},
+		},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := proxy.Run(&config, []string{"up"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCapture_Default(t *testing.T) {
+	if got := runCaptureConfig(t, "  abc123  \n", nil); got != "  abc123  |  abc123  " {
+		t.Errorf("Expected trailing newline to be stripped only, got %q", got)
+	}
+}
+
+func TestCapture_Trim(t *testing.T) {
+	if got := runCaptureConfig(t, "  abc123  \n", &proxy.CaptureOptions{Trim: true}); got != "abc123|abc123" {
+		t.Errorf("Expected trimmed value, got %q", got)
+	}
+}
+
+func TestCapture_LastLine(t *testing.T) {
+	if got := runCaptureConfig(t, "Fetching...\nDone\n8080\n\n", &proxy.CaptureOptions{LastLine: true}); got != "8080|8080" {
+		t.Errorf("Expected last line, got %q", got)
+	}
+}
+
+func TestCapture_JSON(t *testing.T) {
+	got := runCaptureConfig(t, `{"data": {"token": "tok-1", "ttl": 300}}`, &proxy.CaptureOptions{JSON: "data.token"})
+	if got != "tok-1|tok-1" {
+		t.Errorf("Expected JSON field, got %q", got)
+	}
+}
+
+func TestCapture_BaseCommandEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+
+	outFile := filepath.Join(t.TempDir(), "out.txt")
+	config := proxy.Config{
+		// Die Args des Aufrufs werden per Kommentar ausgeblendet
+		BaseCommand: "echo {{.Vars.SHA}} $SHA $FROM_ENV_VARS > " + outFile + " #",
+		EnvVars:     map[string]string{"FROM_ENV_VARS": "v-{{.Vars.SHA}}"},
+		Hooks: map[string][]proxy.Hook{
+			"build": {
+				{Command: "echo", Args: []string{"deadbeef"}, When: "before", CaptureAs: "SHA"},
+			},
+		},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := proxy.Run(&config, []string{"build"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "deadbeef deadbeef v-deadbeef" {
+		t.Errorf("Expected captured value in base command and env, got %q", got)
+	}
+}
+
+func TestValidate_UnknownCapturedVariable(t *testing.T) {
+	config := proxy.Config{
+		BaseCommand: "echo",
+		Hooks: map[string][]proxy.Hook{
+			"up": {
+				{Command: "echo", Args: []string{"{{.Vars.TOKEN}}"}, When: "before"},
+				{Command: "get-token", When: "before", CaptureAs: "TOKEN"},
+			},
+		},
+	}
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Using a variable before it is captured should fail")
+	}
+	if !strings.Contains(err.Error(), "hooks.up[0].args[0]") {
+		t.Errorf("Error should name the hook location, got: %v", err)
+	}
+}
+
+func TestValidate_InvalidCaptureName(t *testing.T) {
+	config := proxy.Config{
+		BaseCommand: "echo",
+		Hooks: map[string][]proxy.Hook{
+			"up": {{Command: "echo", When: "before", CaptureAs: "MY-TOKEN"}},
+		},
+	}
+
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for capture name that is not a valid env var name")
+	}
+}
+
+func TestCapture_SecretRedacted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+
+	config := proxy.Config{
+		BaseCommand: "true",
+		Hooks: map[string][]proxy.Hook{
+			"up": {
+				{Command: "echo", Args: []string{"s3cr3t-value"}, When: "before", CaptureAs: "TOKEN", Capture: &proxy.CaptureOptions{Secret: true}},
+				{Command: "{{.Vars.TOKEN}}", Executor: proxy.ExecutorDirect, When: "before"},
+			},
+		},
+	}
+
+	err := proxy.Run(&config, []string{"up"})
+	if err == nil {
+		t.Fatal("Expected error from unknown command")
+	}
+	if strings.Contains(err.Error(), "s3cr3t-value") {
+		t.Errorf("Secret value leaked into error: %v", err)
+	}
+	if !strings.Contains(err.Error(), "***") {
+		t.Errorf("Expected redacted marker in error, got: %v", err)
+	}
+}
+
+func TestCapture_SecretRedactedInHookStderr(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+
+	config := proxy.Config{
+		BaseCommand: "true",
+		Hooks: map[string][]proxy.Hook{
+			"up": {
+				{Command: "echo s3cr3t-value; echo own s3cr3t-value >&2", When: "before", CaptureAs: "TOKEN", Capture: &proxy.CaptureOptions{Secret: true}},
+				{Command: `echo "later $TOKEN" >&2; printf '%s' "{{.Vars.TOKEN}}" >&2`, When: "before"},
+			},
+		},
+	}
+
+	// Die Fehlerausgabe der Hooks landet in einer Datei statt im Terminal
+	stderrFile, err := os.Create(filepath.Join(t.TempDir(), "stderr.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderrFile.Close()
+	stderr := os.Stderr
+	os.Stderr = stderrFile
+	err = proxy.Run(&config, []string{"up"})
+	os.Stderr = stderr
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	data, err := os.ReadFile(stderrFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cr3t") {
+		t.Errorf("Secret value leaked into hook stderr:\n%s", data)
+	}
+	if string(data) != "own ***\nlater ***\n***" {
+		t.Errorf("Expected redacted hook stderr, got %q", data)
+	}
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestShouldExecuteHook_Match(t *testing.T) {
+	hook := proxy.Hook{
+		// Vorkompilierte Bedingungen haben Vorrang vor Conditions
+		Conditions: proxy.Conditions{ArgsMatch: []string{"never"}},
+		Match:      func(args []string, hadError bool) bool { return len(args) == 2 && !hadError },
+	}
+	if !proxy.ShouldExecuteHook(hook, []string{"a", "b"}, false, runtime.GOOS) {
+		t.Error("Match should decide whether the hook runs")
+	}
+	if proxy.ShouldExecuteHook(hook, []string{"a", "b"}, true, runtime.GOOS) {
+		t.Error("Match should see hadError")
+	}
+}
+
+// codegenConfig enthält Hooks mit allen Arten von Bedingungen
+func codegenConfig() proxy.Config {
+	onError := true
+	otherOS := "windows"
+	if runtime.GOOS == "windows" {
+		otherOS = "linux"
+	}
+	return proxy.Config{
+		BaseCommand: "echo base",
+		Executor:    proxy.ExecutorShell,
+		EnvVars:     map[string]string{"GREETING": "hello {{.SubCommand}}"},
+		Hooks: map[string][]proxy.Hook{
+			"push": {
+				{Command: "echo", Args: []string{"forced {{.SubCommand}}"}, When: "before", Conditions: proxy.Conditions{ArgsMatch: []string{"--force"}}},
+				{Command: "echo", Args: []string{"other os"}, When: "before", Conditions: proxy.Conditions{OsMatch: []string{otherOS}}},
+				{Command: "echo", Args: []string{"this os"}, When: "before", Conditions: proxy.Conditions{OsMatch: []string{runtime.GOOS}}},
+				{Command: "echo", Args: []string{"origin"}, When: "after", Conditions: proxy.Conditions{ArgsContain: []string{"orig"}}},
+				{Command: "echo", Args: []string{"failed"}, When: "after", Conditions: proxy.Conditions{OnError: &onError}},
+			},
+		},
+	}
+}
+
+func TestBuildCodegen(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the sh executor")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, codegenConfig())
+
+	outputs := map[string]string{}
+	for _, mode := range []string{"json", "codegen"} {
+		output := filepath.Join(tmpDir, mode+"-proxy")
+		args := []string{"-build", configFile, "-output", output, "-strategy", "compile"}
+		if mode == "codegen" {
+			args = append(args, "-codegen")
+		}
+		if out, err := exec.Command(proxyBuild, args...).CombinedOutput(); err != nil {
+			t.Fatalf("%s build failed: %v\n%s", mode, err, out)
+		}
+
+		var results []string
+		for _, runArgs := range [][]string{{"push", "--force", "origin"}, {"push", "main"}} {
+			out, err := exec.Command(output, runArgs...).CombinedOutput()
+			if err != nil {
+				t.Fatalf("%s proxy failed: %v\n%s", mode, err, out)
+			}
+			results = append(results, string(out))
+		}
+		outputs[mode] = strings.Join(results, "---\n")
+	}
+
+	want := "forced push\nthis os\nbase push --force origin\norigin\n---\nthis os\nbase push main\n"
+	for mode, got := range outputs {
+		if got != want {
+			t.Errorf("%s: unexpected output:\n%s\nwant:\n%s", mode, got, want)
+		}
+	}
+
+	// Das JSON bleibt für -inspect eingebettet
+	out, err := exec.Command(proxyBuild, "-inspect", filepath.Join(tmpDir, "codegen-proxy")).CombinedOutput()
+	if err != nil || !strings.Contains(string(out), `"GREETING": "hello {{.SubCommand}}"`) {
+		t.Errorf("-inspect should show the embedded config: %v\n%s", err, out)
+	}
+}
+
+// BenchmarkStartup vergleicht den Start eines Proxys mit vielen Hooks: JSON
+// parsen und validieren gegenüber der beim Build generierten Konfiguration
+func BenchmarkStartup(b *testing.B) {
+	if runtime.GOOS == "windows" {
+		b.Skip("uses the sh executor")
+	}
+	proxyBuild := buildProxyBuild(b)
+
+	config := proxy.Config{BaseCommand: "true", Hooks: map[string][]proxy.Hook{}}
+	for i := 0; i < 2000; i++ {
+		subCommand := fmt.Sprintf("sub%d", i)
+		config.Hooks[subCommand] = []proxy.Hook{{
+			Command:    "echo",
+			Args:       []string{"{{.SubCommand}} {{.Cwd}} {{range .Args}}{{.}} {{end}}"},
+			When:       "before",
+			Conditions: proxy.Conditions{ArgsContain: []string{"--verbose"}, ArgsMatch: []string{subCommand}},
+		}}
+	}
+	tmpDir := b.TempDir()
+	configFile := writeConfig(b, tmpDir, config)
+
+	for _, mode := range []string{"json", "codegen"} {
+		output := filepath.Join(tmpDir, mode+"-proxy")
+		args := []string{"-build", configFile, "-output", output, "-strategy", "compile"}
+		if mode == "codegen" {
+			args = append(args, "-codegen")
+		}
+		if out, err := exec.Command(proxyBuild, args...).CombinedOutput(); err != nil {
+			b.Fatalf("%s build failed: %v\n%s", mode, err, out)
+		}
+
+		b.Run(mode, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if out, err := exec.Command(output, "other").CombinedOutput(); err != nil {
+					b.Fatalf("proxy failed: %v\n%s", err, out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ProxyBuild/proxy"
+)
+
+// credentialTestConfig erstellt eine Konfiguration, deren Provider jeden Aufruf
+// in einer Zähldatei protokolliert
+func credentialTestConfig(t *testing.T, expiry time.Time) (proxy.Config, string, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+
+	// Eigenes Cache-Verzeichnis pro Test
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	tmpDir := t.TempDir()
+	counter := filepath.Join(tmpDir, "calls")
+	outFile := filepath.Join(tmpDir, "out.txt")
+	output := fmt.Sprintf(`{"token": "tok-123", "expiry": "%s"}`, expiry.Format(time.RFC3339))
+
+	config := proxy.Config{
+		BaseCommand: "echo $API_TOKEN > " + outFile + " #",
+		Credentials: []proxy.CredentialProvider{
+			{
+				Name:    "test-" + filepath.Base(tmpDir),
+				Command: fmt.Sprintf("sleep 0.2; echo x >> %s; echo '%s'", counter, output),
+				Env:     map[string]string{"API_TOKEN": "token"},
+			},
+		},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return config, counter, outFile
+}
+
+func countCalls(t *testing.T, counter string) int {
+	t.Helper()
+	data, err := os.ReadFile(counter)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(string(data), "x")
+}
+
+func TestCredentials_CachedUntilExpiry(t *testing.T) {
+	config, counter, outFile := credentialTestConfig(t, time.Now().Add(time.Hour))
+
+	for i := 0; i < 3; i++ {
+		if err := proxy.Run(&config, []string{"get"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls := countCalls(t, counter); calls != 1 {
+		t.Errorf("Expected provider to run once, ran %d times", calls)
+	}
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "tok-123" {
+		t.Errorf("Expected token in base command env, got %q", got)
+	}
+}
+
+func TestCredentials_CacheFilePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on Windows")
+	}
+	config, _, _ := credentialTestConfig(t, time.Now().Add(time.Hour))
+
+	if err := proxy.Run(&config, []string{"get"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(cacheDir, "proxybuild", "credentials", "*.json"))
+	if len(matches) != 1 {
+		t.Fatalf("Expected one cache file, got %v", matches)
+	}
+	info, err := os.Stat(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Expected cache file mode 0600, got %o", perm)
+	}
+}
+
+func TestCredentials_RefreshBeforeExpiry(t *testing.T) {
+	// Läuft in 2 Minuten ab, also innerhalb des Standard-Vorlaufs von 5 Minuten
+	config, counter, _ := credentialTestConfig(t, time.Now().Add(2*time.Minute))
+
+	for i := 0; i < 2; i++ {
+		if err := proxy.Run(&config, []string{"get"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls := countCalls(t, counter); calls != 2 {
+		t.Errorf("Expected provider to run on every call close to expiry, ran %d times", calls)
+	}
+}
+
+func TestCredentials_ConcurrentRefresh(t *testing.T) {
+	config, counter, _ := credentialTestConfig(t, time.Now().Add(time.Hour))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg := config
+			errs <- proxy.Run(&cfg, []string{"get"})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := countCalls(t, counter); calls != 1 {
+		t.Errorf("Expected concurrent calls to share one refresh, provider ran %d times", calls)
+	}
+}
+
+func TestValidate_Credentials(t *testing.T) {
+	config := proxy.Config{
+		BaseCommand: "aws",
+		Credentials: []proxy.CredentialProvider{
+			{Name: "aws", Command: "aws-sso-creds", Env: map[string]string{"AWS_SESSION_TOKEN": "SessionToken"}},
+		},
+		Hooks: map[string][]proxy.Hook{
+			"s3": {{Command: "echo", Args: []string{"{{.Vars.AWS_SESSION_TOKEN}}"}, When: "before"}},
+		},
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Credentials should be available as template variables, got: %v", err)
+	}
+
+	config.Credentials[0].Env = nil
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for provider without env mapping")
+	}
+}
+
+func TestCredentials_StaleLock(t *testing.T) {
+	config, counter, _ := credentialTestConfig(t, time.Now().Add(time.Hour))
+
+	// Sperre eines abgestürzten Prozesses
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(cacheDir, "proxybuild", "credentials")
+	mkdirs(t, dir, ".")
+	lockPath := filepath.Join(dir, config.Credentials[0].Name+".json.lock")
+	if err := os.WriteFile(lockPath, []byte("1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(lockPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg := config
+			errs <- proxy.Run(&cfg, []string{"get"})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := countCalls(t, counter); calls != 1 {
+		t.Errorf("Expected one process to take over the stale lock, provider ran %d times", calls)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("Expected lock to be released, got %v", err)
+	}
+}
@@ -2,7 +2,6 @@ module ProxyBuild/tests
 
 go 1.24
 
-require ProxyBuild/proxy v0.0.0
-
-replace ProxyBuild/proxy => ../proxy
+require ProxyBuild v0.0.0
 
+replace ProxyBuild => ../
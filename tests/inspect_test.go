@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"ProxyBuild/proxy"
+)
+
+func TestMetadata_RoundTrip(t *testing.T) {
+	meta := &proxy.BuildMetadata{
+		ProxyBuildVersion: "v1.2.3",
+		ConfigSHA256:      "abc",
+		BuildTime:         "2023-11-14T22:13:20Z",
+		GitCommit:         "0123456789abcdef",
+		Target:            "linux/arm64",
+	}
+	encoded := proxy.EncodeMetadata(meta)
+	if strings.ContainsAny(encoded, " '\"") {
+		t.Errorf("Encoded metadata must be usable as -X value without quoting: %s", encoded)
+	}
+
+	// Per -ldflags gesetzte Metadaten haben Vorrang vor dem Bundle
+	sections := map[string][]byte{proxy.MetadataSection: []byte(proxy.EncodeMetadata(&proxy.BuildMetadata{Target: "other"}))}
+	decoded, err := proxy.BundleMetadata(sections, encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *decoded != *meta {
+		t.Errorf("Metadata does not round-trip: %+v", decoded)
+	}
+
+	if decoded, err := proxy.BundleMetadata(map[string][]byte{}, ""); err != nil || decoded != nil {
+		t.Errorf("Bundles without metadata should yield nil, got %+v, %v", decoded, err)
+	}
+}
+
+func TestBuildInspect(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{
+		BaseCommand: "echo",
+		EnvVars:     map[string]string{"INSPECT_ME": "1"},
+	})
+	if _, err := exec.LookPath("git"); err == nil {
+		for _, args := range [][]string{
+			{"init", "-q"},
+			{"add", "."},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "config"},
+		} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = tmpDir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v failed: %v\n%s", args, err, out)
+			}
+		}
+	}
+
+	// Cross-kompilierte Proxies können nicht ausgeführt, aber untersucht werden
+	goos := "windows"
+	if runtime.GOOS == "windows" {
+		goos = "linux"
+	}
+	for _, strategy := range []string{"stub", "compile"} {
+		t.Run(strategy, func(t *testing.T) {
+			output := filepath.Join(tmpDir, strategy+"-proxy")
+			cmd := exec.Command(proxyBuild, "-build", configFile, "-strategy", strategy, "-os", goos, "-arch", "arm64", "-output", output)
+			cmd.Env = append(os.Environ(), "SOURCE_DATE_EPOCH=1700000000")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("build failed: %v\n%s", err, out)
+			}
+
+			out, err := exec.Command(proxyBuild, "-inspect", output).CombinedOutput()
+			if err != nil {
+				t.Fatalf("inspect failed: %v\n%s", err, out)
+			}
+			for _, want := range []string{"Ziel:          " + goos + "/arm64", "Build-Zeit:    2023-11-14T22:13:20Z", "Konfiguration: sha256:", `"INSPECT_ME": "1"`} {
+				if !strings.Contains(string(out), want) {
+					t.Errorf("Expected %q in inspect output:\n%s", want, out)
+				}
+			}
+			if _, err := exec.LookPath("git"); err == nil && strings.Contains(string(out), "Git-Commit:    unbekannt") {
+				t.Errorf("Expected git commit of the config repository:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestBuildProxyVersion(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo"})
+
+	for _, strategy := range []string{"stub", "compile"} {
+		t.Run(strategy, func(t *testing.T) {
+			output := filepath.Join(tmpDir, strategy+"-proxy")
+			cmd := exec.Command(proxyBuild, "-build", configFile, "-strategy", strategy, "-output", output)
+			cmd.Env = withoutEnv(os.Environ(), "SOURCE_DATE_EPOCH")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("build failed: %v\n%s", err, out)
+			}
+			out, err := exec.Command(output, "--proxy-version").CombinedOutput()
+			if err != nil {
+				t.Fatalf("--proxy-version failed: %v\n%s", err, out)
+			}
+			if want := "Ziel:          " + runtime.GOOS + "/" + runtime.GOARCH; !strings.Contains(string(out), want) {
+				t.Errorf("Expected %q in output:\n%s", want, out)
+			}
+			// Außerhalb eines Git-Repositories und ohne SOURCE_DATE_EPOCH fest 1970
+			if want := "Build-Zeit:    1970-01-01T00:00:00Z"; !strings.Contains(string(out), want) {
+				t.Errorf("Expected %q in output:\n%s", want, out)
+			}
+		})
+	}
+
+	// Die aktuelle Zeit nur mit -build-time-now
+	output := filepath.Join(tmpDir, "now-proxy")
+	if out, err := exec.Command(proxyBuild, "-build", configFile, "-build-time-now", "-output", output).CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+	out, err := exec.Command(output, "--proxy-version").CombinedOutput()
+	if want := "Build-Zeit:    " + time.Now().UTC().Format("2006-01-02"); err != nil || !strings.Contains(string(out), want) {
+		t.Errorf("Expected %q in output: %v\n%s", want, err, out)
+	}
+}
+
+func TestInspect_NoProxy(t *testing.T) {
+	proxyBuild := buildProxyBuild(t)
+
+	out, err := exec.Command(proxyBuild, "-inspect", proxyBuild).CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "kein eingebettetes Bundle") {
+		t.Errorf("Expected error for executable without bundle, got %v:\n%s", err, out)
+	}
+}
@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+// shimEnv isoliert Registry und Standard-Shim-Verzeichnis in home
+func shimEnv(home string) []string {
+	return append(os.Environ(),
+		"HOME="+home,
+		"XDG_CONFIG_HOME="+filepath.Join(home, ".config"),
+		"AppData="+home,
+		"LocalAppData="+home,
+		proxy.ShimDirEnv+"=",
+		"SHELL=/bin/bash",
+	)
+}
+
+func TestShimDirs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("AppData", home)
+	t.Setenv(proxy.ShimDirEnv, filepath.Join(home, "shims"))
+
+	extra := filepath.Join(home, "extra")
+	registry := &proxy.ShimRegistry{Shims: []proxy.InstalledShim{
+		{Name: "git", Path: filepath.Join(extra, "git"), Target: "/opt/git-proxy", ShimDir: extra},
+		{Name: "tool", Path: filepath.Join(home, "shims", "tool"), Target: "/opt/tool", ShimDir: filepath.Join(home, "shims")},
+	}}
+	if err := registry.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{filepath.Join(home, "shims"), extra}
+	if dirs := proxy.ShimDirs(); !reflect.DeepEqual(dirs, want) {
+		t.Errorf("ShimDirs() = %v, want %v", dirs, want)
+	}
+
+	loaded, err := proxy.LoadShimRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shim, ok := loaded.Find(filepath.Join(extra, "git")); !ok || shim.Target != "/opt/git-proxy" {
+		t.Errorf("Expected git shim in registry, got %+v", loaded.Shims)
+	}
+}
+
+func TestInstallShims(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("runs shims by name")
+	}
+	proxyBuild := buildProxyBuild(t)
+	tmpDir := t.TempDir()
+	home := filepath.Join(tmpDir, "home")
+	shimDir := filepath.Join(tmpDir, "shims")
+	mkdirs(t, tmpDir, "home", "config")
+
+	tool := filepath.Join(tmpDir, "tool")
+	configFile := writeConfig(t, filepath.Join(tmpDir, "config"), proxy.Config{BaseCommand: "echo"})
+	if out, err := exec.Command(proxyBuild, "-build", configFile, "-output", tool).CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		cmd := exec.Command(proxyBuild, args...)
+		cmd.Env = shimEnv(home)
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	out, err := run("-install", tool, "--as", "greet", "-shim-dir", shimDir)
+	if err != nil || !strings.Contains(out, `export PATH="`+shimDir+`:$PATH"`) {
+		t.Fatalf("install failed: %v\n%s", err, out)
+	}
+	if out, err := exec.Command(filepath.Join(shimDir, "greet"), "hello").CombinedOutput(); err != nil || string(out) != "hello\n" {
+		t.Errorf("shim failed: %v\n%s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "proxybuild", "shims.json")); err != nil {
+		t.Errorf("Expected registry: %v", err)
+	}
+	if out, err := run("-install", tool, "--as", "greet", "-shim-dir", shimDir); err != nil || !strings.Contains(out, "(vorhanden)") {
+		t.Errorf("Expected existing shim to be kept: %v\n%s", err, out)
+	}
+
+	// Fremde Dateien werden nicht überschrieben
+	writeFiles(t, shimDir, map[string]string{"other": "#!/bin/sh\n"})
+	if out, err := run("-install", tool, "--as", "other", "-shim-dir", shimDir); err == nil || !strings.Contains(out, "nicht mit -install angelegt") {
+		t.Errorf("Expected conflict error: %v\n%s", err, out)
+	}
+
+	// Ohne --as und -shim-dir: Name des Basis-Befehls im Standardverzeichnis
+	if out, err := run("-install", tool); err != nil {
+		t.Fatalf("install with defaults failed: %v\n%s", err, out)
+	}
+	defaultShim := filepath.Join(home, ".local", "proxybuild", "bin", "echo")
+	if _, err := os.Lstat(defaultShim); err != nil {
+		t.Errorf("Expected default shim: %v", err)
+	}
+
+	out, err = run("-list-installed")
+	if err != nil || !strings.Contains(out, "greet") || !strings.Contains(out, defaultShim) || strings.Contains(out, "other") {
+		t.Errorf("Unexpected list: %v\n%s", err, out)
+	}
+
+	if out, err := run("-uninstall", "greet"); err != nil || !strings.Contains(out, "Entfernt") {
+		t.Errorf("uninstall failed: %v\n%s", err, out)
+	}
+	if _, err := os.Lstat(filepath.Join(shimDir, "greet")); !os.IsNotExist(err) {
+		t.Errorf("Expected shim to be removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(shimDir, "other")); err != nil {
+		t.Errorf("Foreign file must stay: %v", err)
+	}
+	if out, err := run("-uninstall", "greet"); err == nil || !strings.Contains(out, "kein Shim") {
+		t.Errorf("Expected error for unknown shim: %v\n%s", err, out)
+	}
+	if out, _ := run("-list-installed"); strings.Contains(out, "greet") || !strings.Contains(out, defaultShim) {
+		t.Errorf("Unexpected list after uninstall:\n%s", out)
+	}
+}
+
+func TestInstallShims_MultiCall(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("runs shims by name")
+	}
+	proxyBuild := buildProxyBuild(t)
+	tmpDir := t.TempDir()
+	mkdirs(t, tmpDir, "home", "echo", "printf")
+	tools := filepath.Join(tmpDir, "tools")
+	echoConfig := writeConfig(t, filepath.Join(tmpDir, "echo"), proxy.Config{BaseCommand: "echo"})
+	printfConfig := writeConfig(t, filepath.Join(tmpDir, "printf"), proxy.Config{BaseCommand: "printf"})
+	if out, err := exec.Command(proxyBuild, "-build", echoConfig, printfConfig, "-output", tools).CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+
+	shimDir := filepath.Join(tmpDir, "shims")
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		cmd := exec.Command(proxyBuild, append(args, "-shim-dir", shimDir)...)
+		cmd.Env = shimEnv(filepath.Join(tmpDir, "home"))
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	if out, err := run("-install", tools, "--as", "git"); err == nil || !strings.Contains(out, "verfügbar: echo, printf") {
+		t.Errorf("Expected unknown command error: %v\n%s", err, out)
+	}
+	if out, err := run("-install", tools); err != nil {
+		t.Fatalf("install failed: %v\n%s", err, out)
+	}
+	if out, err := exec.Command(filepath.Join(shimDir, "printf"), "%s-%s", "a", "b").CombinedOutput(); err != nil || string(out) != "a-b" {
+		t.Errorf("printf shim failed: %v\n%s", err, out)
+	}
+	if out, err := exec.Command(filepath.Join(shimDir, "echo"), "hi").CombinedOutput(); err != nil || string(out) != "hi\n" {
+		t.Errorf("echo shim failed: %v\n%s", err, out)
+	}
+}
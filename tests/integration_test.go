@@ -2,14 +2,78 @@ package tests
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 
 	"ProxyBuild/proxy"
 )
 
+var (
+	proxyBuildOnce sync.Once
+	proxyBuildDir  string
+	proxyBuildPath string
+	proxyBuildErr  error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if proxyBuildDir != "" {
+		os.RemoveAll(proxyBuildDir)
+	}
+	os.Exit(code)
+}
+
+// buildProxyBuild kompiliert das ProxyBuild-Tool einmalig für alle Tests
+func buildProxyBuild(t testing.TB) string {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("Go not found in PATH")
+	}
+
+	proxyBuildOnce.Do(func() {
+		proxyBuildDir, proxyBuildErr = os.MkdirTemp("", "proxybuild-test-")
+		if proxyBuildErr != nil {
+			return
+		}
+		name := "ProxyBuild"
+		if runtime.GOOS == "windows" {
+			name += ".exe"
+		}
+		proxyBuildPath = filepath.Join(proxyBuildDir, name)
+		cmd := exec.Command("go", "build", "-o", proxyBuildPath, ".")
+		cmd.Dir = ".."
+		if out, err := cmd.CombinedOutput(); err != nil {
+			proxyBuildErr = err
+			proxyBuildPath = string(out)
+		}
+	})
+
+	if proxyBuildErr != nil {
+		t.Fatalf("building ProxyBuild failed: %v\n%s", proxyBuildErr, proxyBuildPath)
+	}
+	return proxyBuildPath
+}
+
+// writeConfig schreibt eine Konfiguration als JSON in eine temporäre Datei
+func writeConfig(t testing.TB, dir string, config any) string {
+	t.Helper()
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return configFile
+}
+
 func TestLoadConfig(t *testing.T) {
 	// Erstelle temporäre Config-Datei
 	tmpDir := t.TempDir()
@@ -244,3 +308,255 @@ func TestEmptyConfig(t *testing.T) {
 		t.Error("Empty hooks should remain empty")
 	}
 }
+
+func TestBuildSecretGuard(t *testing.T) {
+	proxyBuild := buildProxyBuild(t)
+
+	tests := []struct {
+		name    string
+		env     string
+		wantMsg string
+	}{
+		{"known name", "GITHUB_TOKEN=plainvalue", "bekannter Secret-Name"},
+		{"token prefix", "PB_TEST_VALUE=ghp_0123456789abcdefghij", "Token-Präfix ghp_"},
+		{"high entropy", "PB_TEST_VALUE=q8Zr2LmX0vTn7KpW4sYb9HcJ", "hohe Entropie"},
+		{"prefix inside value", "PB_TEST_VALUE=Bearer ghp_0123456789abcdefghij", "Token-Präfix ghp_"},
+		{"prefix after assignment", "PB_TEST_VALUE=token=AKIA0123456789ABCDEF", "Token-Präfix AKIA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			key := strings.SplitN(tt.env, "=", 2)[0]
+			configFile := writeConfig(t, tmpDir, proxy.Config{
+				BaseCommand: "echo",
+				Hooks: map[string][]proxy.Hook{
+					"up": {{Command: "notify", Args: []string{"--token", "$" + key}, When: "after"}},
+				},
+			})
+
+			cmd := exec.Command(proxyBuild, "-build", configFile)
+			cmd.Dir = tmpDir
+			cmd.Env = append(os.Environ(), tt.env)
+			out, err := cmd.CombinedOutput()
+			if err == nil {
+				t.Fatalf("expected build to fail, output:\n%s", out)
+			}
+			if !strings.Contains(string(out), "hooks.up[0].args[1]") {
+				t.Errorf("expected finding to name the config path, got:\n%s", out)
+			}
+			if !strings.Contains(string(out), tt.wantMsg) {
+				t.Errorf("expected reason %q, got:\n%s", tt.wantMsg, out)
+			}
+		})
+	}
+}
+
+func TestBuildSecretGuard_Keys(t *testing.T) {
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{
+		BaseCommand: "echo",
+		Hooks: map[string][]proxy.Hook{
+			"$PB_TEST_SUB": {{Command: "notify", When: "after"}},
+		},
+	})
+
+	cmd := exec.Command(proxyBuild, "-build", configFile)
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "PB_TEST_SUB=ghp_0123456789abcdefghij")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected build to fail, output:\n%s", out)
+	}
+	if !strings.Contains(string(out), "hooks.$PB_TEST_SUB (Schlüssel)") || !strings.Contains(string(out), "Token-Präfix ghp_") {
+		t.Errorf("expected finding for the substituted key, got:\n%s", out)
+	}
+}
+
+func TestBuildEnvKeyCollision(t *testing.T) {
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{
+		BaseCommand: "echo",
+		EnvVars:     map[string]string{"$PB_TEST_NAME": "from-variable", "TEAM": "literal"},
+	})
+
+	cmd := exec.Command(proxyBuild, "-build", configFile)
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "PB_TEST_NAME=TEAM")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected build to fail, output:\n%s", out)
+	}
+	if !strings.Contains(string(out), "env_vars.$PB_TEST_NAME und env_vars.TEAM ergeben nach dem Ersetzen der Umgebungsvariablen beide env_vars.TEAM") {
+		t.Errorf("expected error naming both keys, got:\n%s", out)
+	}
+}
+
+func TestBuildSecretGuard_Allowlist(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("reads the bundle appended to a runner stub")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{
+		BaseCommand: "echo $PB_ALLOWED_NAME",
+		EnvVars:     map[string]string{"$PB_ALLOWED_VAR": "value"},
+		Hooks: map[string][]proxy.Hook{
+			"up":               {{Command: "notify", Args: []string{"$GITHUB_TOKEN"}, When: "after"}},
+			"$PB_ALLOWED_NAME": {{Command: "notify", When: "after"}},
+		},
+		BuildEnvAllowlist: []string{"PB_ALLOWED_*"},
+	})
+
+	output := filepath.Join(tmpDir, "allowlist-proxy")
+	cmd := exec.Command(proxyBuild, "-build", configFile, "-output", output, "-strategy", "stub")
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GITHUB_TOKEN=ghp_0123456789abcdefghij", "PB_ALLOWED_NAME=allowed", "PB_ALLOWED_VAR=TEAM")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+	if strings.Contains(string(out), "mögliche Secrets") {
+		t.Errorf("variables outside build_env_allowlist must not be substituted, got:\n%s", out)
+	}
+
+	sections, err := proxy.ReadBundleFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := proxy.LoadBundleConfig(sections)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.BaseCommand != "echo allowed" {
+		t.Errorf("Expected allowlisted variable to be substituted, got %q", config.BaseCommand)
+	}
+	if _, ok := config.Hooks["allowed"]; !ok || config.EnvVars["TEAM"] != "value" {
+		t.Errorf("Expected variables in keys to be substituted, got hooks %v and env_vars %v", config.Hooks, config.EnvVars)
+	}
+	if args := config.Hooks["up"][0].Args; len(args) != 1 || args[0] != "$GITHUB_TOKEN" {
+		t.Errorf("Expected $GITHUB_TOKEN to stay unsubstituted, got %v", args)
+	}
+}
+
+func TestBuildOutsideRepository(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	// Zwei parallele Builds im selben Verzeichnis dürfen sich nicht stören
+	tmpDir := t.TempDir()
+	configs := map[string]string{"first": "first-proxy", "second": "second-proxy"}
+	errs := make(chan error, len(configs))
+	var wg sync.WaitGroup
+	for word, output := range configs {
+		dir := filepath.Join(tmpDir, word)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		configFile := writeConfig(t, dir, proxy.Config{BaseCommand: "echo " + word})
+
+		wg.Add(1)
+		go func(configFile, output string) {
+			defer wg.Done()
+			cmd := exec.Command(proxyBuild, "-build", configFile, "-output", output)
+			cmd.Dir = tmpDir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				errs <- fmt.Errorf("%v\n%s", err, out)
+			}
+		}(configFile, output)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	for word, output := range configs {
+		out, err := exec.Command(filepath.Join(tmpDir, output), "ok").CombinedOutput()
+		if err != nil {
+			t.Fatalf("running %s failed: %v\n%s", output, err, out)
+		}
+		if got := strings.TrimSpace(string(out)); got != word+" ok" {
+			t.Errorf("Expected %q, got %q", word+" ok", got)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "tmp_build")); !os.IsNotExist(err) {
+		t.Error("Build should not create tmp_build in the working directory")
+	}
+}
+
+func TestBuildStrategies(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("stub strategy is tested on ELF platforms")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo built"})
+
+	for _, strategy := range []string{"stub", "compile"} {
+		t.Run(strategy, func(t *testing.T) {
+			output := filepath.Join(tmpDir, strategy+"-proxy")
+			cmd := exec.Command(proxyBuild, "-build", configFile, "-strategy", strategy, "-output", output)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("build failed: %v\n%s", err, out)
+			}
+
+			paths := []string{output}
+			// Der Trailer muss strip überstehen
+			if stripPath, err := exec.LookPath("strip"); err == nil {
+				stripped := output + "-stripped"
+				data, _ := os.ReadFile(output)
+				if err := os.WriteFile(stripped, data, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if out, err := exec.Command(stripPath, stripped).CombinedOutput(); err != nil {
+					t.Fatalf("strip failed: %v\n%s", err, out)
+				}
+				paths = append(paths, stripped)
+			}
+
+			for _, path := range paths {
+				out, err := exec.Command(path, "ok").CombinedOutput()
+				if err != nil {
+					t.Fatalf("running %s failed: %v\n%s", filepath.Base(path), err, out)
+				}
+				if got := strings.TrimSpace(string(out)); got != "built ok" {
+					t.Errorf("%s: expected %q, got %q", filepath.Base(path), "built ok", got)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildStub_WithoutToolchain(t *testing.T) {
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo"})
+
+	cmd := exec.Command(proxyBuild, "-build", configFile, "-stub-dir", filepath.Join(tmpDir, "stubs"))
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "PATH="+tmpDir, "XDG_CACHE_HOME="+filepath.Join(tmpDir, "cache"), "HOME="+tmpDir, "LocalAppData="+tmpDir)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("Expected build without stub and toolchain to fail:\n%s", out)
+	}
+	if !strings.Contains(string(out), "-strategy compile") {
+		t.Errorf("Error should point to alternatives, got:\n%s", out)
+	}
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestSelectCommand(t *testing.T) {
+	names := []string{"git", "kubectl"}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantName string
+		wantArgs []string
+	}{
+		{"symlink name", []string{"/usr/local/bin/git", "status"}, "git", []string{"status"}},
+		{"exe suffix", []string{filepath.Join("tools", "kubectl.exe"), "get", "pods"}, "kubectl", []string{"get", "pods"}},
+		{"first argument", []string{"./multi-proxy", "kubectl", "get"}, "kubectl", []string{"get"}},
+		{"link name wins", []string{"git", "kubectl"}, "git", []string{"kubectl"}},
+		{"unknown", []string{"./multi-proxy", "terraform"}, "", []string{"terraform"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args := proxy.SelectCommand(names, tt.args)
+			if name != tt.wantName || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("SelectCommand(%v) = %q, %v, want %q, %v", tt.args, name, args, tt.wantName, tt.wantArgs)
+			}
+		})
+	}
+
+	// Ohne Multi-Call-Bundle gehen alle Argumente an den Proxy
+	if name, args := proxy.SelectCommand(nil, []string{"git", "git"}); name != "" || !reflect.DeepEqual(args, []string{"git"}) {
+		t.Errorf("single config: got %q, %v", name, args)
+	}
+}
+
+func TestLoadBundleConfigFor(t *testing.T) {
+	sections := map[string][]byte{
+		proxy.MultiCallPrefix + "git":     []byte(`{"base_command": "git"}`),
+		proxy.MultiCallPrefix + "kubectl": []byte(`{"base_command": "kubectl"}`),
+	}
+	if names := proxy.MultiCallNames(sections); !reflect.DeepEqual(names, []string{"git", "kubectl"}) {
+		t.Errorf("Unexpected names %v", names)
+	}
+	config, err := proxy.LoadBundleConfigFor(sections, "kubectl")
+	if err != nil || config.BaseCommand != "kubectl" {
+		t.Errorf("Expected kubectl config, got %+v, %v", config, err)
+	}
+	if _, err := proxy.LoadBundleConfigFor(sections, "terraform"); err == nil {
+		t.Error("Expected error for unknown command")
+	}
+	if _, err := proxy.LoadBundleConfig(sections); err == nil {
+		t.Error("Multi-call bundle has no single config")
+	}
+}
+
+func TestBuildMultiCall(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the sh executor")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	mkdirs(t, tmpDir, "echo", "printf", "stub", "codegen")
+	echoConfig := writeConfig(t, filepath.Join(tmpDir, "echo"), proxy.Config{
+		BaseCommand: "echo",
+		Executor:    proxy.ExecutorShell,
+		Hooks:       map[string][]proxy.Hook{"hi": {{Command: "echo", Args: []string{"hook"}, When: "before"}}},
+	})
+	printfConfig := writeConfig(t, filepath.Join(tmpDir, "printf"), proxy.Config{BaseCommand: "/usr/bin/printf", Executor: proxy.ExecutorShell})
+
+	for _, mode := range []string{"stub", "codegen"} {
+		t.Run(mode, func(t *testing.T) {
+			dir := filepath.Join(tmpDir, mode)
+			output := filepath.Join(dir, "tools")
+			// Flags dürfen nach den Konfigurationsdateien folgen
+			args := []string{"-build", echoConfig, printfConfig, "-output", output}
+			if mode == "codegen" {
+				args = append(args, "-codegen")
+			}
+			if out, err := exec.Command(proxyBuild, args...).CombinedOutput(); err != nil {
+				t.Fatalf("build failed: %v\n%s", err, out)
+			}
+
+			// Befehl als erstes Argument
+			out, err := exec.Command(output, "echo", "hi", "there").CombinedOutput()
+			if err != nil || string(out) != "hook\nhi there\n" {
+				t.Errorf("Unexpected output %q: %v", out, err)
+			}
+
+			// Ohne passenden Namen gibt es eine Liste der Befehle
+			out, err = exec.Command(output, "status").CombinedOutput()
+			if err == nil || !strings.Contains(string(out), "verfügbar: echo, printf") {
+				t.Errorf("Expected list of commands: %v\n%s", err, out)
+			}
+
+			// Symlinks wählen die Konfiguration über den Aufrufnamen
+			linkDir := filepath.Join(dir, "bin")
+			if out, err := exec.Command(output, "--proxy-install-links", linkDir).CombinedOutput(); err != nil {
+				t.Fatalf("--proxy-install-links failed: %v\n%s", err, out)
+			}
+			out, err = exec.Command(filepath.Join(linkDir, "printf"), "%s-", "a", "b").CombinedOutput()
+			if err != nil || string(out) != "a-b-" {
+				t.Errorf("Unexpected output %q: %v", out, err)
+			}
+			out, err = exec.Command(filepath.Join(linkDir, "echo"), "printf").CombinedOutput()
+			if err != nil || string(out) != "printf\n" {
+				t.Errorf("Link name should take precedence over the first argument, got %q: %v", out, err)
+			}
+
+			// Ein zweiter Aufruf lässt vorhandene Links stehen, fremde Dateien nicht
+			if out, err := exec.Command(output, "--proxy-install-links", linkDir).CombinedOutput(); err != nil {
+				t.Errorf("Existing links should be accepted: %v\n%s", err, out)
+			}
+			os.Remove(filepath.Join(linkDir, "echo"))
+			os.WriteFile(filepath.Join(linkDir, "echo"), []byte("other"), 0755)
+			out, err = exec.Command(output, "--proxy-install-links", linkDir).CombinedOutput()
+			if err == nil || !strings.Contains(string(out), "existiert bereits") {
+				t.Errorf("Expected error for foreign file: %v\n%s", err, out)
+			}
+
+			out, err = exec.Command(proxyBuild, "-inspect", output).CombinedOutput()
+			if err != nil || !strings.Contains(string(out), "Befehle:       echo, printf") || !strings.Contains(string(out), "Konfiguration printf:") {
+				t.Errorf("-inspect should list all configs: %v\n%s", err, out)
+			}
+		})
+	}
+}
+
+func TestBuildMultiCall_Errors(t *testing.T) {
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	mkdirs(t, tmpDir, "a", "b", "c")
+	first := writeConfig(t, filepath.Join(tmpDir, "a"), proxy.Config{BaseCommand: "/usr/bin/git"})
+	second := writeConfig(t, filepath.Join(tmpDir, "b"), proxy.Config{BaseCommand: "git --no-pager"})
+	packaged := writeConfig(t, filepath.Join(tmpDir, "c"), proxy.Config{
+		BaseCommand: "kubectl",
+		Package:     &proxy.PackageConfig{Name: "kubectl-proxy", Version: "1.0.0"},
+	})
+
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"name collision", []string{first, second}, `ergeben beide den Befehl "git"`},
+		{"package", []string{first, packaged}, "package wird in Multi-Call-Executables nicht unterstützt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-build"}, tt.files...)
+			args = append(args, "-output", filepath.Join(tmpDir, "tools"))
+			out, err := exec.Command(proxyBuild, args...).CombinedOutput()
+			if err == nil || !strings.Contains(string(out), tt.want) {
+				t.Errorf("Expected %q: %v\n%s", tt.want, err, out)
+			}
+		})
+	}
+}
+
+// mkdirs legt Unterverzeichnisse für mehrere config.json an
+func mkdirs(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package tests
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestPackageConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"valid", `{"base_command": "/usr/bin/git", "package": {"version": "1.0.0", "symlinks": ["git"]}}`, ""},
+		{"missing version", `{"base_command": "git", "package": {}}`, "version fehlt"},
+		{"invalid version", `{"base_command": "git", "package": {"version": "1.0-beta"}}`, "ungültige version"},
+		{"unknown format", `{"base_command": "git", "package": {"version": "1.0", "formats": ["msi"]}}`, "unbekanntes format"},
+		{"brew without url", `{"base_command": "git", "package": {"version": "1.0", "formats": ["brew"]}}`, "url_template"},
+		{"shadowing base command", `{"base_command": "git", "package": {"version": "1.0", "symlinks": ["git"]}}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := proxy.LoadConfig([]byte(tt.config))
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestBuildPackages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles runner stubs for several targets")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{
+		BaseCommand: "/bin/echo",
+		Package: &proxy.PackageConfig{
+			Version:     "1.2.0",
+			Maintainer:  "Ops <ops@example.com>",
+			Description: "Echo proxy\nFür Tests.",
+			License:     "MIT",
+			Symlinks:    []string{"echo"},
+			URLTemplate: "https://example.com/v{{.Version}}/{{.Archive}}",
+		},
+	})
+	dist := filepath.Join(tmpDir, "dist")
+
+	cmd := exec.Command(proxyBuild, "-build", configFile, "-targets", "linux/amd64,windows/amd64", "-dist", dist)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+
+	sums := readChecksums(t, filepath.Join(dist, "SHA256SUMS"))
+	for _, file := range []string{"echo-proxy_1.2.0-1_amd64.deb", "echo-proxy-1.2.0-1.x86_64.rpm", "echo-proxy.rb", "echo-proxy.json"} {
+		if sums[file] == "" {
+			t.Errorf("%s missing in SHA256SUMS", file)
+		}
+	}
+
+	t.Run("deb", func(t *testing.T) {
+		members := readAr(t, filepath.Join(dist, "echo-proxy_1.2.0-1_amd64.deb"))
+		if string(members["debian-binary"]) != "2.0\n" {
+			t.Errorf("Unexpected debian-binary: %q", members["debian-binary"])
+		}
+		control := readTarGz(t, members["control.tar.gz"])
+		for _, want := range []string{"Package: echo-proxy", "Version: 1.2.0-1\n", "Architecture: amd64", "Maintainer: Ops <ops@example.com>"} {
+			if !strings.Contains(control["./control"].data, want) {
+				t.Errorf("control missing %q:\n%s", want, control["./control"].data)
+			}
+		}
+		data := readTarGz(t, members["data.tar.gz"])
+		if entry, ok := data["./usr/bin/echo-proxy"]; !ok || entry.mode&0111 == 0 {
+			t.Errorf("Expected executable ./usr/bin/echo-proxy, got %+v", data)
+		}
+		// Der Link liegt nicht in /usr/bin, sonst kollidiert er mit dem Paket des echten Befehls
+		if link := data["./usr/lib/echo-proxy/bin/echo"].link; link != "/usr/bin/echo-proxy" {
+			t.Errorf("Expected symlink to /usr/bin/echo-proxy, got %q", link)
+		}
+		if _, ok := data["./usr/bin/echo"]; ok {
+			t.Error("Symlink must not be placed in bin_dir")
+		}
+		if script := data["./etc/profile.d/echo-proxy.sh"].data; !strings.Contains(script, `PATH="/usr/lib/echo-proxy/bin:$PATH"`) {
+			t.Errorf("Expected profile.d script prepending the shim dir, got %q", script)
+		}
+		if conffiles := control["./conffiles"].data; conffiles != "/etc/profile.d/echo-proxy.sh\n" {
+			t.Errorf("Unexpected conffiles %q", conffiles)
+		}
+
+		// Mit dpkg-deb gegenprüfen, falls vorhanden
+		if _, err := exec.LookPath("dpkg-deb"); err != nil {
+			t.Skip("dpkg-deb not installed")
+		}
+		deb := filepath.Join(dist, "echo-proxy_1.2.0-1_amd64.deb")
+		out, err := exec.Command("dpkg-deb", "--show", "--showformat", "${Package} ${Version} ${Architecture}", deb).CombinedOutput()
+		if err != nil || string(out) != "echo-proxy 1.2.0-1 amd64" {
+			t.Errorf("dpkg-deb --show: %v\n%s", err, out)
+		}
+		out, err = exec.Command("dpkg-deb", "--contents", deb).CombinedOutput()
+		if err != nil || !strings.Contains(string(out), "./usr/lib/echo-proxy/bin/echo -> /usr/bin/echo-proxy") {
+			t.Errorf("dpkg-deb --contents: %v\n%s", err, out)
+		}
+	})
+
+	t.Run("rpm", func(t *testing.T) {
+		content, err := os.ReadFile(filepath.Join(dist, "echo-proxy-1.2.0-1.x86_64.rpm"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(content, []byte{0xed, 0xab, 0xee, 0xdb}) {
+			t.Fatal("Missing RPM lead")
+		}
+		// Signatur-Header (auf 8 Byte aufgefüllt), dann Haupt-Header, dann Payload
+		offset := 96
+		offset = (skipRPMHeader(t, content, offset) + 7) / 8 * 8
+		tags := readRPMTags(t, content, offset)
+		offset = skipRPMHeader(t, content, offset)
+
+		// Tags nach der RPM-Spezifikation (rpmtag.h)
+		for tag, want := range map[int]string{
+			1000: "[echo-proxy]",                                                                     // NAME
+			1001: "[1.2.0]",                                                                          // VERSION
+			1002: "[1]",                                                                              // RELEASE
+			1021: "[linux]",                                                                          // OS
+			1022: "[x86_64]",                                                                         // ARCH
+			1014: "[MIT]",                                                                            // LICENSE
+			1117: "[echo-proxy.sh echo-proxy echo]",                                                  // BASENAMES
+			1118: "[/etc/profile.d/ /usr/bin/ /usr/lib/echo-proxy/bin/]",                             // DIRNAMES
+			1116: "[0 1 2]",                                                                          // DIRINDEXES
+			1036: "[  /usr/bin/echo-proxy]",                                                          // FILELINKTOS
+			1037: "[1 0 0]",                                                                          // FILEFLAGS, %config für das Skript
+			1047: "[echo-proxy]",                                                                     // PROVIDENAME
+			1113: "[1.2.0-1]",                                                                        // PROVIDEVERSION
+			1049: "[rpmlib(CompressedFileNames) rpmlib(FileDigests) rpmlib(PayloadFilesHavePrefix)]", // REQUIRENAME
+		} {
+			if got := fmt.Sprint(tags[tag]); got != want {
+				t.Errorf("Tag %d: expected %s, got %s", tag, want, got)
+			}
+		}
+
+		gr, err := gzip.NewReader(bytes.NewReader(content[offset:]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := io.ReadAll(gr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := cpioNames(t, payload)
+		for _, want := range []string{"./usr/bin/echo-proxy", "./usr/lib/echo-proxy/bin/echo", "./etc/profile.d/echo-proxy.sh"} {
+			if !names[want] {
+				t.Errorf("%s missing in payload, got %v", want, names)
+			}
+		}
+	})
+
+	t.Run("brew", func(t *testing.T) {
+		formula, err := os.ReadFile(filepath.Join(dist, "echo-proxy.rb"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			"class EchoProxy < Formula",
+			`url "https://example.com/v1.2.0/echo-proxy_linux_amd64.tar.gz"`,
+			`sha256 "` + sums["echo-proxy_linux_amd64.tar.gz"] + `"`,
+			`bin.install_symlink "echo-proxy" => "echo"`,
+		} {
+			if !strings.Contains(string(formula), want) {
+				t.Errorf("formula missing %q:\n%s", want, formula)
+			}
+		}
+	})
+
+	t.Run("scoop", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(dist, "echo-proxy.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var manifest struct {
+			Version      string `json:"version"`
+			Architecture map[string]struct {
+				URL  string `json:"url"`
+				Hash string `json:"hash"`
+			} `json:"architecture"`
+			Bin []any `json:"bin"`
+		}
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			t.Fatal(err)
+		}
+		arch := manifest.Architecture["64bit"]
+		if arch.URL != "https://example.com/v1.2.0/echo-proxy_windows_amd64.zip" || arch.Hash != sums["echo-proxy_windows_amd64.zip"] {
+			t.Errorf("Unexpected 64bit entry: %+v", arch)
+		}
+		if len(manifest.Bin) != 2 {
+			t.Errorf("Expected binary and alias in bin, got %v", manifest.Bin)
+		}
+	})
+}
+
+// readAr liest die Einträge eines ar-Archivs
+func readAr(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("!<arch>\n")) {
+		t.Fatal("Missing ar header")
+	}
+	members := make(map[string][]byte)
+	data = data[8:]
+	for len(data) >= 60 {
+		name := strings.TrimSpace(string(data[:16]))
+		size, err := strconv.Atoi(strings.TrimSpace(string(data[48:58])))
+		if err != nil {
+			t.Fatal(err)
+		}
+		members[name] = data[60 : 60+size]
+		data = data[60+size+size%2:]
+	}
+	return members
+}
+
+type tarFile struct {
+	data string
+	mode int64
+	link string
+}
+
+func readTarGz(t *testing.T, data []byte) map[string]tarFile {
+	t.Helper()
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	files := make(map[string]tarFile)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(tr)
+		files[header.Name] = tarFile{data: string(content), mode: header.Mode, link: header.Linkname}
+	}
+}
+
+// skipRPMHeader prüft die Magic eines RPM-Headers und liefert das Ende
+func skipRPMHeader(t *testing.T, data []byte, offset int) int {
+	t.Helper()
+	if !bytes.HasPrefix(data[offset:], []byte{0x8e, 0xad, 0xe8, 0x01}) {
+		t.Fatalf("Missing header magic at %d", offset)
+	}
+	count := int(binary.BigEndian.Uint32(data[offset+8:]))
+	size := int(binary.BigEndian.Uint32(data[offset+12:]))
+	return offset + 16 + count*16 + size
+}
+
+// readRPMTags dekodiert die Einträge des Headers an offset. Strings und
+// String-Arrays werden zu []string, Ganzzahlen zu []uint32.
+func readRPMTags(t *testing.T, data []byte, offset int) map[int]any {
+	t.Helper()
+	count := int(binary.BigEndian.Uint32(data[offset+8:]))
+	store := data[offset+16+count*16:]
+	tags := make(map[int]any)
+	for i := 0; i < count; i++ {
+		entry := data[offset+16+i*16:]
+		tag := int(binary.BigEndian.Uint32(entry))
+		typ := binary.BigEndian.Uint32(entry[4:])
+		pos := int(binary.BigEndian.Uint32(entry[8:]))
+		n := int(binary.BigEndian.Uint32(entry[12:]))
+		switch typ {
+		case 4: // INT32
+			var values []uint32
+			for j := 0; j < n; j++ {
+				values = append(values, binary.BigEndian.Uint32(store[pos+4*j:]))
+			}
+			tags[tag] = values
+		case 6, 8, 9: // STRING, STRING_ARRAY, I18NSTRING
+			var values []string
+			for j := 0; j < n; j++ {
+				end := bytes.IndexByte(store[pos:], 0)
+				values = append(values, string(store[pos:pos+end]))
+				pos += end + 1
+			}
+			tags[tag] = values
+		}
+	}
+	return tags
+}
+
+// cpioNames liefert die Dateinamen eines cpio-Archivs im newc-Format
+func cpioNames(t *testing.T, data []byte) map[string]bool {
+	t.Helper()
+	names := make(map[string]bool)
+	align := func(n int) int { return (n + 3) &^ 3 }
+	for len(data) >= 110 {
+		if string(data[:6]) != "070701" {
+			t.Fatalf("Invalid cpio magic %q", data[:6])
+		}
+		field := func(i int) int {
+			v, err := strconv.ParseUint(string(data[6+i*8:14+i*8]), 16, 32)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return int(v)
+		}
+		fileSize, nameSize := field(6), field(11)
+		name := string(data[110 : 110+nameSize-1])
+		if name == "TRAILER!!!" {
+			break
+		}
+		names[name] = true
+		data = data[align(align(110+nameSize)+fileSize):]
+	}
+	return names
+}
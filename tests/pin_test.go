@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestLoadConfig_Pin(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"valid", `{"base_command": "kubectl", "base_command_path": "/usr/bin/kubectl", "base_command_sha256": "` + sum + `"}`, ""},
+		{"windows path", `{"base_command": "kubectl", "base_command_path": "C:\\tools\\kubectl.exe"}`, ""},
+		{"checksum only", `{"base_command": "kubectl --context prod", "base_command_sha256": "` + sum + `"}`, ""},
+		{"relative path", `{"base_command": "kubectl", "base_command_path": "bin/kubectl"}`, "absoluter Pfad"},
+		{"invalid checksum", `{"base_command": "kubectl", "base_command_sha256": "abc"}`, "64 Hex-Zeichen"},
+		{"template", `{"base_command": "{{.Env.KUBECTL}}", "base_command_path": "/usr/bin/kubectl"}`, "muss der Befehl feststehen"},
+		{"smoke test stub", `{"base_command": "kubectl", "base_command_path": "/usr/bin/kubectl", "smoke_tests": [{"args": ["version"]}]}`, "setze real_command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := proxy.LoadConfig([]byte(tt.json))
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestBuildPin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the sh executor")
+	}
+	proxyBuild := buildProxyBuild(t)
+	tmpDir := t.TempDir()
+	mkdirs(t, tmpDir, "config", "home")
+	real := filepath.Join(tmpDir, "real")
+	greet := filepath.Join(real, "greet")
+	writeScript(t, greet, `echo "real $*"`)
+	pathWith := func(dirs ...string) string {
+		return "PATH=" + strings.Join(append(dirs, os.Getenv("PATH")), string(os.PathListSeparator))
+	}
+	env := func(dirs ...string) []string {
+		return append(shimEnv(filepath.Join(tmpDir, "home")), pathWith(dirs...))
+	}
+
+	tool := filepath.Join(tmpDir, "tool")
+	configFile := writeConfig(t, filepath.Join(tmpDir, "config"), proxy.Config{BaseCommand: "greet", Executor: proxy.ExecutorShell})
+	build := exec.Command(proxyBuild, "-build", configFile, "-output", tool, "-pin")
+	build.Env = env(real)
+	if out, err := build.CombinedOutput(); err != nil || !strings.Contains(string(out), "gepinnt: "+greet) {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+
+	run := func(dirs ...string) (string, error) {
+		t.Helper()
+		cmd := exec.Command(tool, "x")
+		cmd.Env = env(dirs...)
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+	if out, err := run(real); err != nil || out != "real x\n" {
+		t.Errorf("pinned proxy failed: %v\n%s", err, out)
+	}
+	// Ohne greet im PATH läuft die gepinnte Datei
+	if out, err := run(); err != nil || out != "real x\n" {
+		t.Errorf("Expected pinned path without PATH entry: %v\n%s", err, out)
+	}
+
+	// Ein anderer greet vorne im PATH
+	shadow := filepath.Join(tmpDir, "shadow")
+	writeScript(t, filepath.Join(shadow, "greet"), `echo "evil $*"`)
+	out, err := run(shadow, real)
+	if err == nil || !strings.Contains(out, "wird verdeckt: erwartet "+greet+", gefunden "+filepath.Join(shadow, "greet")) {
+		t.Errorf("Expected shadowing error: %v\n%s", err, out)
+	}
+
+	// Ausgetauschte Datei
+	writeScript(t, greet, `echo "swapped $*"`)
+	out, err = run(real)
+	if err == nil || !strings.Contains(out, "wurde verändert: erwartet sha256:") || strings.Contains(out, "swapped") {
+		t.Errorf("Expected checksum error: %v\n%s", err, out)
+	}
+
+	// Pfad und Prüfsumme gelten nur für diesen Rechner
+	other := "windows"
+	if runtime.GOOS == "windows" {
+		other = "linux"
+	}
+	cross := exec.Command(proxyBuild, "-build", configFile, "-output", filepath.Join(tmpDir, "cross"), "-pin", "-os", other)
+	cross.Env = env(real)
+	if out, err := cross.CombinedOutput(); err == nil || !strings.Contains(string(out), "-pin geht nur") {
+		t.Errorf("Expected cross build error: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,236 @@
+package tests
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+type releaseManifest struct {
+	Name      string `json:"name"`
+	Artifacts []struct {
+		Target  string `json:"target"`
+		Binary  string `json:"binary"`
+		Archive string `json:"archive"`
+		SHA256  string `json:"sha256"`
+		Error   string `json:"error"`
+	} `json:"artifacts"`
+}
+
+func TestBuildTargets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles runner stubs for several targets")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo"})
+	dist := filepath.Join(tmpDir, "dist")
+
+	// linux/foo gibt es nicht, die übrigen Ziele müssen trotzdem gebaut werden
+	cmd := exec.Command(proxyBuild, "-build", configFile,
+		"-targets", "linux/amd64,windows/amd64,linux/foo",
+		"-dist", dist, "-jobs", "2", "-name-template", "tool-{{.OS}}-{{.Arch}}")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("Expected build to fail for linux/foo:\n%s", out)
+	}
+	if !strings.Contains(string(out), "1 von 3 Zielen fehlgeschlagen") {
+		t.Errorf("Expected failure summary, got:\n%s", out)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dist, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest releaseManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Artifacts) != 3 {
+		t.Fatalf("Expected 3 artifacts in manifest, got %d", len(manifest.Artifacts))
+	}
+
+	sums := readChecksums(t, filepath.Join(dist, "SHA256SUMS"))
+	for _, artifact := range manifest.Artifacts {
+		if artifact.Target == "linux/foo" {
+			if artifact.Error == "" || artifact.Archive != "" {
+				t.Errorf("linux/foo should be recorded as failed: %+v", artifact)
+			}
+			continue
+		}
+		if artifact.Error != "" {
+			t.Errorf("%s failed: %s", artifact.Target, artifact.Error)
+			continue
+		}
+
+		archive := filepath.Join(dist, artifact.Archive)
+		content, err := os.ReadFile(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(content)
+		if got := hex.EncodeToString(sum[:]); got != artifact.SHA256 || sums[artifact.Archive] != got {
+			t.Errorf("%s: checksum mismatch (manifest %s, SHA256SUMS %s, file %s)", artifact.Archive, artifact.SHA256, sums[artifact.Archive], got)
+		}
+	}
+	if len(sums) != 2 {
+		t.Errorf("Expected 2 entries in SHA256SUMS, got %d", len(sums))
+	}
+
+	if _, err := os.Stat(filepath.Join(dist, "tool-windows-amd64.zip")); err != nil {
+		t.Errorf("Expected zip archive for windows: %v", err)
+	} else {
+		zr, err := zip.OpenReader(filepath.Join(dist, "tool-windows-amd64.zip"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(zr.File) != 1 || zr.File[0].Name != "echo-proxy.exe" {
+			t.Errorf("Unexpected zip content: %v", zr.File)
+		}
+		zr.Close()
+	}
+
+	if runtime.GOOS == "linux" && runtime.GOARCH == "amd64" {
+		binary := extractTarGz(t, filepath.Join(dist, "tool-linux-amd64.tar.gz"), "echo-proxy", tmpDir)
+		out, err := exec.Command(binary, "from", "archive").CombinedOutput()
+		if err != nil {
+			t.Fatalf("running extracted proxy failed: %v\n%s", err, out)
+		}
+		if got := strings.TrimSpace(string(out)); got != "from archive" {
+			t.Errorf("Expected %q, got %q", "from archive", got)
+		}
+	}
+}
+
+func TestBuildTargets_FailedArchive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles runner stubs for several targets")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo"})
+	dist := filepath.Join(tmpDir, "dist")
+	// Ein Verzeichnis mit dem Namen des Archivs lässt das Schreiben scheitern
+	mkdirs(t, dist, "tool-linux-amd64.tar.gz")
+
+	out, err := exec.Command(proxyBuild, "-build", configFile, "-targets", "linux/amd64", "-dist", dist,
+		"-sbom", "cyclonedx", "-name-template", "tool-{{.OS}}-{{.Arch}}").CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "Archiv:") {
+		t.Fatalf("Expected archive error: %v\n%s", err, out)
+	}
+
+	entries, err := os.ReadDir(dist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if got, want := strings.Join(names, " "), "SHA256SUMS manifest.json tool-linux-amd64.tar.gz"; got != want {
+		t.Errorf("Expected no partial files in dist, got %s", got)
+	}
+}
+
+func TestBuildTargets_Invalid(t *testing.T) {
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo"})
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"malformed target", []string{"-targets", "linux"}, "ungültiges Ziel"},
+		{"with -os", []string{"-targets", "linux/amd64", "-os", "linux"}, "-os/-arch"},
+		{"name collision", []string{"-targets", "linux/amd64,linux/arm64", "-name-template", "{{.Name}}_{{.OS}}"}, "denselben Namen"},
+		{"unknown archive", []string{"-targets", "linux/amd64", "-archive", "rar"}, "Archivformat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-build", configFile, "-dist", filepath.Join(tmpDir, "dist")}, tt.args...)
+			out, err := exec.Command(proxyBuild, args...).CombinedOutput()
+			if err == nil {
+				t.Fatalf("Expected error, got:\n%s", out)
+			}
+			if !strings.Contains(string(out), tt.want) {
+				t.Errorf("Expected %q in output, got:\n%s", tt.want, out)
+			}
+		})
+	}
+}
+
+// readChecksums liest eine Datei im Format von sha256sum
+func readChecksums(t *testing.T, path string) map[string]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		sum, name, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok {
+			t.Fatalf("invalid SHA256SUMS line: %q", scanner.Text())
+		}
+		sums[name] = sum
+	}
+	return sums
+}
+
+// extractTarGz entpackt die Datei name aus dem Archiv nach dir
+func extractTarGz(t *testing.T, archive, name, dir string) string {
+	t.Helper()
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			t.Fatalf("%s not found in %s", name, archive)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Name != name {
+			continue
+		}
+		target := filepath.Join(dir, name)
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, data, os.FileMode(header.Mode)); err != nil {
+			t.Fatal(err)
+		}
+		return target
+	}
+}
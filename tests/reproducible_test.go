@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestBuildReproducible(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{
+		BaseCommand: "echo",
+		EnvVars:     map[string]string{"B": "2", "A": "1"},
+	})
+
+	for _, strategy := range []string{"stub", "compile"} {
+		t.Run(strategy, func(t *testing.T) {
+			var outputs [][]byte
+			for i := 0; i < 2; i++ {
+				// Gleicher Dateiname, er steht als Name in den Metadaten
+				output := filepath.Join(tmpDir, strategy+"-"+string(rune('a'+i)), "tool")
+				os.MkdirAll(filepath.Dir(output), 0755)
+				// -no-cache, damit der zweite Build wirklich kompiliert wird
+				cmd := exec.Command(proxyBuild, "-build", configFile, "-strategy", strategy, "-no-cache", "-output", output)
+				// Auch ohne SOURCE_DATE_EPOCH ist die Build-Zeit reproduzierbar
+				cmd.Env = withoutEnv(os.Environ(), "SOURCE_DATE_EPOCH")
+				if out, err := cmd.CombinedOutput(); err != nil {
+					t.Fatalf("build failed: %v\n%s", err, out)
+				}
+				data, err := os.ReadFile(output)
+				if err != nil {
+					t.Fatal(err)
+				}
+				outputs = append(outputs, data)
+			}
+			if !bytes.Equal(outputs[0], outputs[1]) {
+				t.Error("Two builds of the same config should be byte-identical")
+			}
+		})
+	}
+}
+
+func TestBuildVerifyReproducible(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo"})
+
+	cmd := exec.Command(proxyBuild, "-build", configFile, "-verify-reproducible", "-output", filepath.Join(tmpDir, "proxy"))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "Reproduzierbar") {
+		t.Errorf("Expected reproducibility report, got:\n%s", out)
+	}
+
+	out, err = exec.Command(proxyBuild, "-build", configFile, "-verify-reproducible", "-build-time-now", "-output", filepath.Join(tmpDir, "proxy")).CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "-build-time-now") {
+		t.Errorf("Expected -verify-reproducible to reject -build-time-now: %v\n%s", err, out)
+	}
+}
+
+// withoutEnv entfernt die Variable key aus environ
+func withoutEnv(environ []string, key string) []string {
+	var result []string
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, key+"=") {
+			result = append(result, kv)
+		}
+	}
+	return result
+}
+
+func TestBuildSBOM(t *testing.T) {
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo"})
+	output := filepath.Join(tmpDir, "echo-proxy")
+
+	t.Run("cyclonedx", func(t *testing.T) {
+		cmd := exec.Command(proxyBuild, "-build", configFile, "-sbom", "cyclonedx", "-output", output)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("build failed: %v\n%s", err, out)
+		}
+
+		var bom struct {
+			BOMFormat string `json:"bomFormat"`
+			Metadata  struct {
+				Component struct {
+					Hashes []struct {
+						Content string `json:"content"`
+					} `json:"hashes"`
+					Properties []struct {
+						Name  string `json:"name"`
+						Value string `json:"value"`
+					} `json:"properties"`
+				} `json:"component"`
+			} `json:"metadata"`
+			Components []struct {
+				Name string `json:"name"`
+			} `json:"components"`
+		}
+		readJSON(t, output+".cdx.json", &bom)
+
+		if bom.BOMFormat != "CycloneDX" {
+			t.Errorf("Unexpected bomFormat %q", bom.BOMFormat)
+		}
+		if hashes := bom.Metadata.Component.Hashes; len(hashes) != 1 || hashes[0].Content != fileSum(t, output) {
+			t.Errorf("SBOM hash does not match binary: %+v", hashes)
+		}
+		properties := make(map[string]string)
+		for _, p := range bom.Metadata.Component.Properties {
+			properties[p.Name] = p.Value
+		}
+		for _, name := range []string{"proxybuild:version", "proxybuild:config:sha256", "proxybuild:target"} {
+			if properties[name] == "" {
+				t.Errorf("Missing property %s", name)
+			}
+		}
+		var names []string
+		for _, c := range bom.Components {
+			names = append(names, c.Name)
+		}
+		if !strings.Contains(strings.Join(names, ","), "ProxyBuild/proxy") {
+			t.Errorf("Expected ProxyBuild/proxy module in components, got %v", names)
+		}
+	})
+
+	t.Run("spdx", func(t *testing.T) {
+		cmd := exec.Command(proxyBuild, "-build", configFile, "-sbom", "spdx", "-output", output)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("build failed: %v\n%s", err, out)
+		}
+
+		var doc struct {
+			SPDXVersion string `json:"spdxVersion"`
+			Packages    []struct {
+				Name      string `json:"name"`
+				Checksums []struct {
+					Value string `json:"checksumValue"`
+				} `json:"checksums"`
+			} `json:"packages"`
+		}
+		readJSON(t, output+".spdx.json", &doc)
+
+		if doc.SPDXVersion != "SPDX-2.3" {
+			t.Errorf("Unexpected spdxVersion %q", doc.SPDXVersion)
+		}
+		if len(doc.Packages) < 2 || doc.Packages[0].Checksums[0].Value != fileSum(t, output) {
+			t.Errorf("Unexpected packages: %+v", doc.Packages)
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		out, err := exec.Command(proxyBuild, "-build", configFile, "-sbom", "xml").CombinedOutput()
+		if err == nil || !strings.Contains(string(out), "SBOM-Format") {
+			t.Errorf("Expected error for unknown SBOM format, got %v:\n%s", err, out)
+		}
+	})
+}
+
+func TestBuildTargets_SourceDateEpoch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles runner stubs for several targets")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{
+		BaseCommand: "/bin/echo",
+		Package:     &proxy.PackageConfig{Version: "1.0.0"},
+	})
+
+	// Mit SOURCE_DATE_EPOCH sind auch Archive und Pakete identisch
+	var sums []string
+	for _, dist := range []string{"dist-a", "dist-b"} {
+		cmd := exec.Command(proxyBuild, "-build", configFile, "-targets", "linux/amd64,windows/amd64", "-dist", filepath.Join(tmpDir, dist))
+		cmd.Env = append(os.Environ(), "SOURCE_DATE_EPOCH=1700000000")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("build failed: %v\n%s", err, out)
+		}
+		data, err := os.ReadFile(filepath.Join(tmpDir, dist, "SHA256SUMS"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sums = append(sums, string(data))
+	}
+	if sums[0] != sums[1] {
+		t.Errorf("SHA256SUMS differ between builds:\n%s\n%s", sums[0], sums[1])
+	}
+}
+
+func readJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+}
+
+func fileSum(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+// writeScript legt ein ausführbares sh-Skript an
+func writeScript(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveBaseCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the sh executor")
+	}
+	proxyBuild := buildProxyBuild(t)
+	tmpDir := t.TempDir()
+	mkdirs(t, tmpDir, "home", "links", "shims", "shell", "direct")
+
+	build := func(name string, config proxy.Config) string {
+		t.Helper()
+		output := filepath.Join(tmpDir, name+"-proxy")
+		configFile := writeConfig(t, filepath.Join(tmpDir, name), config)
+		if out, err := exec.Command(proxyBuild, "-build", configFile, "-output", output).CombinedOutput(); err != nil {
+			t.Fatalf("build failed: %v\n%s", err, out)
+		}
+		return output
+	}
+	shellProxy := build("shell", proxy.Config{BaseCommand: "greet --from-proxy", Executor: proxy.ExecutorShell})
+	directProxy := build("direct", proxy.Config{BaseCommand: "greet", Executor: proxy.ExecutorDirect})
+
+	links := filepath.Join(tmpDir, "links")
+	shims := filepath.Join(tmpDir, "shims")
+	real := filepath.Join(tmpDir, "real")
+	writeScript(t, filepath.Join(real, "greet"), `echo "real $*"`)
+
+	run := func(path string, dirs []string, args ...string) (string, error) {
+		t.Helper()
+		cmd := exec.Command(path, args...)
+		cmd.Env = append(shimEnv(filepath.Join(tmpDir, "home")),
+			"PATH="+strings.Join(append(dirs, "/bin", "/usr/bin"), string(os.PathListSeparator)),
+			proxy.ShimDirEnv+"="+shims,
+			proxy.DepthEnv+"=",
+		)
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	// Der Proxy liegt unter dem Namen des Basis-Commands vor dem echten Befehl im PATH
+	if err := os.Symlink(shellProxy, filepath.Join(links, "greet")); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := run(filepath.Join(links, "greet"), []string{links, real}, "x"); err != nil || out != "real --from-proxy x\n" {
+		t.Errorf("Expected real command behind the symlink: %v\n%s", err, out)
+	}
+
+	// Eine Kopie im Shim-Verzeichnis ist nicht dieselbe Datei, wird aber übersprungen
+	data, err := os.ReadFile(directProxy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(shims, "greet"), data, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := run(filepath.Join(shims, "greet"), []string{shims, real}, "y"); err != nil || out != "real y\n" {
+		t.Errorf("Expected shim dir to be skipped: %v\n%s", err, out)
+	}
+
+	// Ohne echten Befehl im PATH
+	out, err := run(filepath.Join(links, "greet"), []string{links}, "z")
+	if err == nil || !strings.Contains(out, "der echte Befehl fehlt") || !strings.Contains(out, filepath.Join(links, "greet")) {
+		t.Errorf("Expected missing command error: %v\n%s", err, out)
+	}
+
+	// Ein Wrapper, der den Proxy wieder aufruft, wird nach proxy.MaxDepth Ebenen abgebrochen
+	loop := filepath.Join(tmpDir, "loop")
+	writeScript(t, filepath.Join(loop, "greet"), shellProxy+` "$@"`)
+	// Nur der innerste Aufruf scheitert, der Exit-Code wird nicht weitergereicht
+	if out, _ := run(shellProxy, []string{loop}); !strings.Contains(out, "verschachtelte Proxy-Aufrufe") {
+		t.Errorf("Expected recursion error:\n%s", out)
+	}
+}
@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestSecrets_RoundTrip(t *testing.T) {
+	values := map[string]string{"SLACK_TOKEN": "xoxb-123", "WEBHOOK": "https://hooks.example/abc"}
+
+	for _, passphrase := range []bool{false, true} {
+		secrets, err := proxy.EncryptSecrets(values, []byte("team-key"), passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(secrets.Data, "xoxb-123") {
+			t.Fatal("Secret value stored in plaintext")
+		}
+
+		decrypted, err := secrets.Decrypt([]byte("team-key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted["SLACK_TOKEN"] != "xoxb-123" || decrypted["WEBHOOK"] != values["WEBHOOK"] {
+			t.Errorf("Unexpected decrypted values: %v", decrypted)
+		}
+	}
+}
+
+func TestSecrets_WrongKeyOrTampered(t *testing.T) {
+	secrets, err := proxy.EncryptSecrets(map[string]string{"TOKEN": "abc"}, []byte("right"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := secrets.Decrypt([]byte("wrong")); err == nil {
+		t.Error("Expected error with wrong key")
+	}
+
+	secrets.Names = []string{"OTHER"}
+	if _, err := secrets.Decrypt([]byte("right")); err == nil {
+		t.Error("Expected error when names were changed")
+	}
+}
+
+func TestSecrets_RunExposesValues(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+
+	secrets, err := proxy.EncryptSecrets(map[string]string{"API_KEY": "k-42"}, []byte("team-key"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets.KeyEnv = "PB_TEST_SECRETS_KEY"
+	t.Setenv("PB_TEST_SECRETS_KEY", "team-key")
+
+	outFile := filepath.Join(t.TempDir(), "out.txt")
+	config := proxy.Config{
+		BaseCommand: "echo $API_KEY > " + outFile + " #",
+		Secrets:     secrets,
+		Hooks: map[string][]proxy.Hook{
+			"send": {{Command: "test", Args: []string{"{{.Vars.API_KEY}}", "=", "k-42"}, When: "before"}},
+		},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := proxy.Run(&config, []string{"send"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "k-42" {
+		t.Errorf("Expected decrypted secret in env, got %q", got)
+	}
+}
+
+func TestSecretsEdit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as editor")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	// Reihenfolge und Formatierung außerhalb von secrets müssen erhalten bleiben
+	original := "{\n    \"hooks\": {\"up\": []},\n    \"base_command\": \"echo\",\n    \"env_vars\": {\"B\": \"1\", \"A\": \"2\"}\n}\n"
+	configFile := filepath.Join(tmpDir, "config.json")
+	if err := os.WriteFile(configFile, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	editor := filepath.Join(tmpDir, "editor.sh")
+	script := "#!/bin/sh\nprintf '{\"SLACK_TOKEN\": \"xoxb-secret-1\"}' > \"$1\"\n"
+	if err := os.WriteFile(editor, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile := filepath.Join(tmpDir, "secrets.key")
+	if err := os.WriteFile(keyFile, []byte("file-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Eine zweite Kopie des Schlüssels, etwa ein lokaler Pfad eines Entwicklers
+	localKeyFile := filepath.Join(tmpDir, "local.key")
+	if err := os.WriteFile(localKeyFile, []byte("file-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Der erste Aufruf fügt secrets an, der zweite ersetzt den Abschnitt und
+	// behält dabei key_file aus der Konfiguration
+	var data []byte
+	for _, useKeyFile := range []string{keyFile, localKeyFile} {
+		cmd := exec.Command(proxyBuild, "-secrets-edit", configFile, "-secrets-key-file", useKeyFile)
+		cmd.Env = append(os.Environ(), "EDITOR="+editor, "VISUAL=")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("secrets-edit failed: %v\n%s", err, out)
+		}
+
+		var err error
+		if data, err = os.ReadFile(configFile); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "xoxb-secret-1") {
+			t.Fatal("Secret stored in plaintext")
+		}
+		prefix := strings.TrimSuffix(original, "\n}\n") + ",\n    \"secrets\": {"
+		if !strings.HasPrefix(string(data), prefix) || !strings.HasSuffix(string(data), "\n    }\n}\n") {
+			t.Fatalf("Expected only the secrets section to change, got:\n%s", data)
+		}
+	}
+
+	var config proxy.Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Secrets == nil || config.Secrets.KeyFile != keyFile {
+		t.Fatalf("Expected secrets section with key_file, got %+v", config.Secrets)
+	}
+	values, err := config.Secrets.Decrypt([]byte("file-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["SLACK_TOKEN"] != "xoxb-secret-1" {
+		t.Errorf("Unexpected secrets after edit: %v", values)
+	}
+}
@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestMinisign_PublicKeyFormat(t *testing.T) {
+	// Beispielschlüssel aus der minisign-Dokumentation
+	key, err := proxy.ParseMinisignPublicKey([]byte("untrusted comment: minisign public key E7620F1842B4E81F\nRWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.ID() != "E7620F1842B4E81F" {
+		t.Errorf("Unexpected key ID %s", key.ID())
+	}
+	if key.String() != "RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3" {
+		t.Errorf("Key does not round-trip: %s", key.String())
+	}
+}
+
+func TestMinisign_SignVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := proxy.NewMinisignPublicKey(pub)
+	message := []byte("proxy binary")
+	sig := proxy.SignMinisign(priv, message, "timestamp:1\tfile:proxy")
+
+	trusted, err := key.Verify(message, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trusted != "timestamp:1\tfile:proxy" {
+		t.Errorf("Unexpected trusted comment %q", trusted)
+	}
+
+	if _, err := key.Verify([]byte("modified"), sig); err == nil {
+		t.Error("Expected error for modified message")
+	}
+
+	tampered := strings.Replace(string(sig), "file:proxy", "file:other", 1)
+	if _, err := key.Verify(message, []byte(tampered)); err == nil {
+		t.Error("Expected error for modified trusted comment")
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := proxy.NewMinisignPublicKey(otherPub).Verify(message, sig); err == nil || !strings.Contains(err.Error(), "Schlüssel") {
+		t.Errorf("Expected key ID mismatch, got %v", err)
+	}
+}
+
+func TestBuildSigned(t *testing.T) {
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo"})
+	keyFile := filepath.Join(tmpDir, "key.pem")
+	pubFile := filepath.Join(tmpDir, "key.pub")
+
+	if out, err := exec.Command(proxyBuild, "-gen-sign-key", keyFile).CombinedOutput(); err != nil {
+		t.Fatalf("key generation failed: %v\n%s", err, out)
+	}
+	if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Private key should be written with mode 0600: %v", err)
+	}
+
+	output := filepath.Join(tmpDir, "echo-proxy")
+	cmd := exec.Command(proxyBuild, "-build", configFile, "-sign-key", keyFile, "-output", output)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+	if _, err := os.Stat(output + ".minisig"); err != nil {
+		t.Fatalf("Signature missing: %v", err)
+	}
+
+	if out, err := exec.Command(proxyBuild, "-verify", output, "-pubkey", pubFile).CombinedOutput(); err != nil {
+		t.Fatalf("verification failed: %v\n%s", err, out)
+	}
+
+	// Der öffentliche Schlüssel kann auch direkt angegeben werden
+	pub, err := os.ReadFile(pubFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(pub)), "\n")
+	if out, err := exec.Command(proxyBuild, "-verify", output, "-pubkey", lines[len(lines)-1]).CombinedOutput(); err != nil {
+		t.Fatalf("verification with inline key failed: %v\n%s", err, out)
+	}
+
+	f, err := os.OpenFile(output, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("tampered"))
+	f.Close()
+	out, err := exec.Command(proxyBuild, "-verify", output, "-pubkey", pubFile).CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "Signatur ungültig") {
+		t.Errorf("Expected verification to fail for modified binary, got %v:\n%s", err, out)
+	}
+}
+
+func TestBuildTargets_Signed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles runner stubs for several targets")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo"})
+	keyFile := filepath.Join(tmpDir, "key.pem")
+	if out, err := exec.Command(proxyBuild, "-gen-sign-key", keyFile).CombinedOutput(); err != nil {
+		t.Fatalf("key generation failed: %v\n%s", err, out)
+	}
+
+	dist := filepath.Join(tmpDir, "dist")
+	cmd := exec.Command(proxyBuild, "-build", configFile, "-targets", "linux/amd64", "-dist", dist, "-sign-key", keyFile)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+
+	for _, file := range []string{"echo-proxy_linux_amd64.tar.gz", "SHA256SUMS", "manifest.json"} {
+		path := filepath.Join(dist, file)
+		out, err := exec.Command(proxyBuild, "-verify", path, "-pubkey", filepath.Join(tmpDir, "key.pub")).CombinedOutput()
+		if err != nil {
+			t.Errorf("%s: verification failed: %v\n%s", file, err, out)
+		}
+	}
+}
+
+func TestBuildSigned_InvalidKey(t *testing.T) {
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{BaseCommand: "echo"})
+	keyFile := filepath.Join(tmpDir, "key.pem")
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(tmpDir, "echo-proxy")
+	out, err := exec.Command(proxyBuild, "-build", configFile, "-sign-key", keyFile, "-output", output).CombinedOutput()
+	if err == nil {
+		t.Fatalf("Expected build with invalid key to fail:\n%s", out)
+	}
+	if _, err := os.Stat(output); err == nil {
+		t.Error("No executable should be built when the signing key is invalid")
+	}
+}
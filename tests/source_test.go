@@ -0,0 +1,221 @@
+package tests
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+// buildFromSource baut source mit -build und liefert die Ausgabe von -inspect
+func buildFromSource(t *testing.T, proxyBuild, source string) (string, error) {
+	t.Helper()
+	output := filepath.Join(t.TempDir(), "proxy")
+	if out, err := exec.Command(proxyBuild, "-build", source, "-output", output).CombinedOutput(); err != nil {
+		return string(out), err
+	}
+	out, err := exec.Command(proxyBuild, "-inspect", output).CombinedOutput()
+	if err != nil {
+		t.Fatalf("inspect failed: %v\n%s", err, out)
+	}
+	return string(out), nil
+}
+
+// tarGz packt files in ein tar.gz-Archiv, Skripte werden ausführbar
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		mode := int64(0644)
+		if strings.HasSuffix(name, ".sh") {
+			mode = 0755
+		}
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: mode, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestBuildFromGitSource(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "--quiet")
+	mkdirs(t, repo, "configs")
+	writeConfig(t, filepath.Join(repo, "configs"), proxy.Config{BaseCommand: "docker-v1"})
+	os.Rename(filepath.Join(repo, "configs", "config.json"), filepath.Join(repo, "configs", "docker.json"))
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "v1")
+	git("tag", "v1.2")
+	writeConfig(t, filepath.Join(repo, "configs"), proxy.Config{BaseCommand: "docker-v2"})
+	os.Rename(filepath.Join(repo, "configs", "config.json"), filepath.Join(repo, "configs", "docker.json"))
+	git("commit", "--quiet", "-am", "v2")
+
+	repoURL := "git+file://" + filepath.ToSlash(repo)
+	if runtime.GOOS == "windows" {
+		repoURL = "git+file:///" + filepath.ToSlash(repo)
+	}
+
+	out, err := buildFromSource(t, proxyBuild, repoURL+"//configs/docker.json@v1.2")
+	if err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "docker-v1") || strings.Contains(out, "docker-v2") {
+		t.Errorf("Expected config from tag v1.2:\n%s", out)
+	}
+
+	out, err = buildFromSource(t, proxyBuild, repoURL+"//configs/docker.json")
+	if err != nil || !strings.Contains(out, "docker-v2") {
+		t.Errorf("Expected config from default branch: %v\n%s", err, out)
+	}
+
+	for source, want := range map[string]string{
+		repoURL + "//configs/docker.json@v9": "ref v9",
+		repoURL + "//configs/missing.json":   "configs/missing.json nicht gefunden",
+		repoURL + "//../outside.json@v1.2":   "ungültiger Pfad",
+		"git+file:///does/not/exist//x.json": "git clone",
+	} {
+		out, err := buildFromSource(t, proxyBuild, source)
+		if err == nil || !strings.Contains(out, want) {
+			t.Errorf("%s: expected error containing %q, got %v\n%s", source, want, err, out)
+		}
+	}
+}
+
+func TestBuildFromArchiveSource(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the sh executor")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	config := `{"base_command": "echo base", "executor": "shell", "embed_dirs": ["scripts"],
+		"hooks": {"up": [{"command": "asset:scripts/pre-up.sh", "when": "before"}]}}`
+	archive := tarGz(t, map[string]string{
+		"config.json":        config,
+		"configs/other.json": `{"base_command": "other-tool"}`,
+		"scripts/pre-up.sh":  "#!/bin/sh\necho pre-up\n",
+	})
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tools.tar.gz")
+	if err := os.WriteFile(archivePath, archive, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Das Archiv bringt die Assets der Konfiguration mit
+	output := filepath.Join(dir, "up-proxy")
+	if out, err := exec.Command(proxyBuild, "-build", archivePath, "-output", output).CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, out)
+	}
+	cmd := exec.Command(output, "up")
+	cmd.Env = cacheEnv(t, t.TempDir())
+	if out, err := cmd.CombinedOutput(); err != nil || string(out) != "pre-up\nbase up\n" {
+		t.Errorf("Unexpected output %q: %v", out, err)
+	}
+
+	out, err := buildFromSource(t, proxyBuild, archivePath+"//configs/other.json")
+	if err != nil || !strings.Contains(out, "other-tool") {
+		t.Errorf("Expected config from archive path: %v\n%s", err, out)
+	}
+
+	for i, name := range []string{"../config.json", `..\..\config.json`, `C:\config.json`, "/config.json"} {
+		evil := filepath.Join(dir, fmt.Sprintf("evil-%d.tar.gz", i))
+		os.WriteFile(evil, tarGz(t, map[string]string{name: config}), 0644)
+		if out, err := buildFromSource(t, proxyBuild, evil); err == nil || !strings.Contains(out, "ungültiger Pfad") {
+			t.Errorf("%s: expected error for path outside the archive: %v\n%s", name, err, out)
+		}
+	}
+
+	// 65 MiB Nullen sind gepackt nur einige KiB groß
+	var bomb bytes.Buffer
+	gz := gzip.NewWriter(&bomb)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "config.json", Mode: 0644, Size: 65 << 20})
+	tw.Write(make([]byte, 65<<20))
+	tw.Close()
+	gz.Close()
+	bombPath := filepath.Join(dir, "bomb.tar.gz")
+	os.WriteFile(bombPath, bomb.Bytes(), 0644)
+	if out, err := buildFromSource(t, proxyBuild, bombPath); err == nil || !strings.Contains(out, "entpackt größer als 64 MiB") {
+		t.Errorf("Expected error for an archive that expands beyond the limit: %v\n%s", err, out)
+	}
+}
+
+func TestBuildFromHTTPSource(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	config := []byte(`{"base_command": "remote-tool"}`)
+	archive := tarGz(t, map[string]string{"nested/config.json": `{"base_command": "archived-tool"}`})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/config.json":
+			w.Write(config)
+		case "/tools.tar.gz":
+			w.Write(archive)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	sum := func(data []byte) string {
+		s := sha256.Sum256(data)
+		return hex.EncodeToString(s[:])
+	}
+
+	out, err := buildFromSource(t, proxyBuild, server.URL+"/config.json#sha256="+sum(config))
+	if err != nil || !strings.Contains(out, "remote-tool") {
+		t.Errorf("Expected downloaded config: %v\n%s", err, out)
+	}
+	out, err = buildFromSource(t, proxyBuild, server.URL+"/tools.tar.gz//nested/config.json#sha256="+sum(archive))
+	if err != nil || !strings.Contains(out, "archived-tool") {
+		t.Errorf("Expected config from downloaded archive: %v\n%s", err, out)
+	}
+
+	for source, want := range map[string]string{
+		server.URL + "/config.json":                                "brauchen eine erwartete Prüfsumme",
+		server.URL + "/config.json#sha256=" + sum([]byte("other")): "Prüfsumme stimmt nicht",
+		server.URL + "/missing.json#sha256=" + sum(config):         "404",
+	} {
+		out, err := buildFromSource(t, proxyBuild, source)
+		if err == nil || !strings.Contains(out, want) {
+			t.Errorf("%s: expected error containing %q, got %v\n%s", source, want, err, out)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestValidate_KnownTemplateFields(t *testing.T) {
+	config := proxy.Config{
+		BaseCommand: "echo",
+		Hooks: map[string][]proxy.Hook{
+			"up": {
+				{Command: "echo", Args: []string{"{{.SubCommand}}", "{{index .Args 1}}", "{{.Env.USER}}", "{{.Cwd}}", "{{.Timestamp}}"}, When: "before"},
+				{Command: "notify", Args: []string{"exit={{.ExitCode}}", "took={{.Duration}}"}, When: "after"},
+				{Command: "echo", Args: []string{"{{range .Args}}{{.}} {{end}}"}, When: "before"},
+			},
+		},
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected valid config, got: %v", err)
+	}
+}
+
+func TestValidate_UnknownTemplateField(t *testing.T) {
+	config := proxy.Config{
+		BaseCommand: "echo",
+		Hooks: map[string][]proxy.Hook{
+			"up": {
+				{Command: "echo", Args: []string{"ok", "{{.Subcommand}}"}, When: "before"},
+			},
+		},
+	}
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Expected error for unknown template field")
+	}
+	if !strings.Contains(err.Error(), "hooks.up[0].args[1]") {
+		t.Errorf("Error should name the hook location, got: %v", err)
+	}
+}
+
+func TestValidate_AfterFieldInBeforeHook(t *testing.T) {
+	config := proxy.Config{
+		BaseCommand: "echo",
+		Hooks: map[string][]proxy.Hook{
+			"up": {
+				{Command: "echo {{.ExitCode}}", When: "before"},
+			},
+		},
+	}
+
+	if err := config.Validate(); err == nil {
+		t.Error("ExitCode should not be available in before hooks")
+	}
+}
+
+func TestValidate_InvalidTemplateSyntax(t *testing.T) {
+	config := proxy.Config{
+		BaseCommand: "echo",
+		Hooks: map[string][]proxy.Hook{
+			"up": {
+				{Command: "echo", Args: []string{"{{.SubCommand"}, When: "before"},
+			},
+		},
+	}
+
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for malformed template")
+	}
+}
+
+func TestRun_TemplateExpansion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+
+	tmpDir := t.TempDir()
+	outFile := filepath.Join(tmpDir, "out.txt")
+
+	config := proxy.Config{
+		BaseCommand: "exit 3;",
+		Hooks: map[string][]proxy.Hook{
+			"deploy": {
+				{Command: "echo", Args: []string{"{{.SubCommand}}", "{{index .Args 1}}", ">", outFile}, When: "before"},
+				{Command: "echo", Args: []string{"exit={{.ExitCode}}", ">>", outFile}, When: "after"},
+			},
+		},
+	}
+
+	if err := proxy.Run(&config, []string{"deploy", "prod"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "deploy prod\nexit=3\n"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestRun_TemplateFuncs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+
+	tmpDir := t.TempDir()
+	outFile := filepath.Join(tmpDir, "out.txt")
+
+	config := proxy.Config{
+		BaseCommand: "true #",
+		Hooks: map[string][]proxy.Hook{
+			"deploy": {
+				{Command: "printf", Args: []string{"'%s|'", "{{quote .Args}}", ">", outFile}, When: "before"},
+				{Command: "echo", Args: []string{"'[{{index .Args 5}}]'", "{{quote (join \",\" .Args)}}", ">>", outFile}, When: "after"},
+				{Command: "echo", Args: []string{"'[{{index .Env \"PB_MISSING\"}}]'", ">>", outFile}, When: "after"},
+			},
+		},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := proxy.Run(&config, []string{"deploy", "two words", "it's"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "deploy|two words|it's|[] deploy,two words,it's\n[]\n"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestRun_TemplateShellQuoting(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+
+	tmpDir := t.TempDir()
+	outFile := filepath.Join(tmpDir, "out.txt")
+	pwned := filepath.Join(tmpDir, "pwned")
+	t.Setenv("PB_FLAGS", "-n")
+
+	config := proxy.Config{
+		BaseCommand: "true #",
+		Hooks: map[string][]proxy.Hook{
+			"deploy": {
+				{Command: "printf", Args: []string{"'%s|'", "{{.Args}}", "{{index .Args 1}}", ">", outFile}, When: "before"},
+				{Command: "echo", Args: []string{"{{raw .Env.PB_FLAGS}}", "x={{.SubCommand}}", ">>", outFile}, When: "before"},
+				{Command: "printf", Args: []string{"%s|", "{{.Args}}"}, Executor: proxy.ExecutorDirect, When: "after", CaptureAs: "DIRECT"},
+				{Command: "printf", Args: []string{"'%s'", "{{.Vars.DIRECT}}", ">>", outFile}, When: "after"},
+			},
+		},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := proxy.Run(&config, []string{"deploy", "$(touch " + pwned + "); touch " + pwned}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(pwned); err == nil {
+		t.Error("Args must not be evaluated by the shell")
+	}
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arg := "$(touch " + pwned + "); touch " + pwned
+	if got, want := string(data), "deploy|"+arg+"|"+arg+"|x=deploydeploy "+arg+"|"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestQuoteShellWord(t *testing.T) {
+	tests := []struct {
+		goos, word, want string
+	}{
+		{"linux", "plain-word_1.txt", "plain-word_1.txt"},
+		{"linux", "two words", "'two words'"},
+		{"linux", "it's", `'it'\''s'`},
+		{"linux", "$(rm -rf ~); `id`", "'$(rm -rf ~); `id`'"},
+		{"linux", "100%", "'100%'"},
+		{"linux", "", "''"},
+		{"windows", `C:\tools\bin`, `C:\tools\bin`},
+		{"windows", "two words", `"two words"`},
+		{"windows", "a & b | c > d", `"a & b | c > d"`},
+		{"windows", "%PATH%", `""^%"PATH"^%""`},
+		{"windows", "x!y", `"x"^!"y"`},
+		{"windows", `say "hi" & del`, `"say "\^""hi"\^"" & del"`},
+		{"windows", `C:\dir with space\`, `"C:\dir with space\\"`},
+		{"windows", `a\"b`, `"a\\"\^""b"`},
+		{"windows", "", `""`},
+	}
+	for _, tt := range tests {
+		got, err := proxy.QuoteShellWord(tt.word, tt.goos)
+		if err != nil || got != tt.want {
+			t.Errorf("QuoteShellWord(%q, %s) = %s, %v, want %s", tt.word, tt.goos, got, err, tt.want)
+		}
+	}
+
+	if _, err := proxy.QuoteShellWord("a\nb", "windows"); err == nil {
+		t.Error("Expected an error for a newline with cmd")
+	}
+}
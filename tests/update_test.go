@@ -0,0 +1,351 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestLoadConfig_Update(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	key := proxy.NewMinisignPublicKey(pub).String()
+
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"valid", `{"base_command": "echo", "update": {"feed": "https://example.com/feed", "public_key": "` + key + `", "check_interval": "24h"}}`, ""},
+		{"missing feed", `{"base_command": "echo", "update": {"public_key": "` + key + `"}}`, "update: feed fehlt"},
+		{"invalid key", `{"base_command": "echo", "update": {"feed": "/srv/feed", "public_key": "abc"}}`, "update: public_key"},
+		{"invalid channel", `{"base_command": "echo", "update": {"feed": "/srv/feed", "channel": "../beta", "public_key": "` + key + `"}}`, "channel"},
+		{"short interval", `{"base_command": "echo", "update": {"feed": "/srv/feed", "public_key": "` + key + `", "check_interval": "5s"}}`, "check_interval"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := proxy.LoadConfig([]byte(tt.json))
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// updateFixture erzeugt einen Signaturschlüssel und baut Proxies, die ihre
+// Ausgabe mit einer Version versehen
+type updateFixture struct {
+	t          *testing.T
+	proxyBuild string
+	dir        string
+	keyFile    string
+	publicKey  string
+}
+
+func newUpdateFixture(t *testing.T) *updateFixture {
+	proxyBuild := buildProxyBuild(t)
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key.pem")
+	if out, err := exec.Command(proxyBuild, "-gen-sign-key", keyFile).CombinedOutput(); err != nil {
+		t.Fatalf("key generation failed: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "key.pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := proxy.ParseMinisignPublicKey(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &updateFixture{t: t, proxyBuild: proxyBuild, dir: dir, keyFile: keyFile, publicKey: pub.String()}
+}
+
+// build baut "echo <version>" mit update-Section zum Zeitpunkt epoch nach output
+func (f *updateFixture) build(version, epoch, output string, update *proxy.UpdateConfig, args ...string) (string, error) {
+	f.t.Helper()
+	configDir := filepath.Join(f.dir, "config-"+version)
+	os.MkdirAll(configDir, 0755)
+	configFile := writeConfig(f.t, configDir, proxy.Config{BaseCommand: "echo " + version, Update: update})
+	cmd := exec.Command(f.proxyBuild, append([]string{"-build", configFile, "-output", output}, args...)...)
+	cmd.Env = append(os.Environ(), "SOURCE_DATE_EPOCH="+epoch)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestSelfUpdate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("replaces the test binary while running")
+	}
+	f := newUpdateFixture(t)
+	feedDir := filepath.Join(f.dir, "feed")
+	update := &proxy.UpdateConfig{Feed: feedDir, PublicKey: f.publicKey}
+
+	installDir := t.TempDir()
+	tool := filepath.Join(installDir, "tool")
+	if out, err := f.build("v1", "1000", tool, update, "-sign-key", f.keyFile, "-publish", feedDir); err != nil {
+		t.Fatalf("build v1 failed: %v\n%s", err, out)
+	}
+	out, err := f.build("v2", "2000", filepath.Join(f.dir, "tool"), update, "-sign-key", f.keyFile, "-publish", feedDir)
+	if err != nil || !strings.Contains(out, "Update-Feed aktualisiert") {
+		t.Fatalf("build v2 failed: %v\n%s", err, out)
+	}
+	for _, file := range []string{"stable.json", "stable.json.minisig", "stable/" + runtime.GOOS + "-" + runtime.GOARCH + "/tool"} {
+		if _, err := os.Stat(filepath.Join(feedDir, filepath.FromSlash(file))); err != nil {
+			t.Errorf("Expected %s in feed: %v", file, err)
+		}
+	}
+
+	cacheDir := t.TempDir()
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		cmd := exec.Command(tool, args...)
+		cmd.Env = cacheEnv(t, cacheDir)
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	if out, _ := run("x"); out != "v1 x\n" {
+		t.Fatalf("Unexpected output before update %q", out)
+	}
+	out, err = run("--proxy-update")
+	if err != nil || !strings.Contains(out, "Aktualisiert auf Build 1970-01-01T00:33:20Z") {
+		t.Fatalf("update failed: %v\n%s", err, out)
+	}
+	if out, _ := run("x"); out != "v2 x\n" {
+		t.Errorf("Unexpected output after update %q", out)
+	}
+	if _, err := os.Stat(tool + proxy.PreviousSuffix); err != nil {
+		t.Errorf("Previous version should be kept: %v", err)
+	}
+	if out, err := run("--proxy-update"); err != nil || !strings.Contains(out, "bereits aktuell") {
+		t.Errorf("Expected no update: %v\n%s", err, out)
+	}
+
+	out, err = run("--proxy-rollback")
+	if err != nil || !strings.Contains(out, "wiederhergestellt") {
+		t.Fatalf("rollback failed: %v\n%s", err, out)
+	}
+	if out, _ := run("x"); out != "v1 x\n" {
+		t.Errorf("Unexpected output after rollback %q", out)
+	}
+
+	// Ein älterer Build im Feed wird nicht installiert
+	older := filepath.Join(f.dir, "older")
+	os.MkdirAll(older, 0755)
+	if out, err := f.build("v0", "500", filepath.Join(older, "tool"), update, "-sign-key", f.keyFile, "-publish", feedDir); err != nil {
+		t.Fatalf("build v0 failed: %v\n%s", err, out)
+	}
+	if out, err := run("--proxy-update"); err != nil || !strings.Contains(out, "einen älteren Build") {
+		t.Errorf("Expected downgrade to be refused: %v\n%s", err, out)
+	}
+
+	// Manipulierter Feed und manipuliertes Executable
+	f.build("v3", "3000", filepath.Join(f.dir, "tool"), update, "-sign-key", f.keyFile, "-publish", feedDir)
+	binary := filepath.Join(feedDir, "stable", runtime.GOOS+"-"+runtime.GOARCH, "tool")
+	os.WriteFile(binary, []byte("#!/bin/sh\necho evil\n"), 0755)
+	if out, err := run("--proxy-update"); err == nil || !strings.Contains(out, "Prüfsumme stimmt nicht") {
+		t.Errorf("Expected checksum error: %v\n%s", err, out)
+	}
+	feedFile := filepath.Join(feedDir, "stable.json")
+	data, _ := os.ReadFile(feedFile)
+	os.WriteFile(feedFile, []byte(strings.Replace(string(data), `"sha256": "`, `"sha256": "0`, 1)), 0644)
+	if out, err := run("--proxy-update"); err == nil || !strings.Contains(out, "Signatur ungültig") {
+		t.Errorf("Expected signature error: %v\n%s", err, out)
+	}
+	if out, _ := run("x"); out != "v1 x\n" {
+		t.Errorf("Failed updates must not replace the executable, got %q", out)
+	}
+}
+
+func TestSelfUpdate_SameBuildTime(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("replaces the test binary while running")
+	}
+	f := newUpdateFixture(t)
+	feedDir := filepath.Join(f.dir, "feed")
+	update := &proxy.UpdateConfig{Feed: feedDir, PublicKey: f.publicKey}
+
+	// Alle Builds mit demselben SOURCE_DATE_EPOCH
+	tool := filepath.Join(t.TempDir(), "tool")
+	if out, err := f.build("v1", "1000", tool, update, "-sign-key", f.keyFile, "-publish", feedDir); err != nil {
+		t.Fatalf("build v1 failed: %v\n%s", err, out)
+	}
+	if out, err := f.build("v2", "1000", filepath.Join(f.dir, "tool"), update, "-sign-key", f.keyFile, "-publish", feedDir); err != nil {
+		t.Fatalf("build v2 failed: %v\n%s", err, out)
+	}
+	feedFile := filepath.Join(feedDir, "stable.json")
+	oldFeed, _ := os.ReadFile(feedFile)
+	oldSig, _ := os.ReadFile(feedFile + ".minisig")
+	if !strings.Contains(string(oldFeed), `"sequence": 2`) {
+		t.Errorf("Expected sequence 2 after two publishes:\n%s", oldFeed)
+	}
+
+	cacheDir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command(tool, args...)
+		cmd.Env = cacheEnv(t, cacheDir)
+		out, _ := cmd.CombinedOutput()
+		return string(out)
+	}
+	if out := run("--proxy-update"); !strings.Contains(out, "Aktualisiert") {
+		t.Fatalf("Expected update to a build with the same build time:\n%s", out)
+	}
+	if out := run("x"); out != "v2 x\n" {
+		t.Errorf("Unexpected output after update %q", out)
+	}
+
+	// Ein zurückgespielter, gültig signierter Feed mit kleinerer Sequenz
+	if out, err := f.build("v3", "1000", filepath.Join(f.dir, "tool"), update, "-sign-key", f.keyFile, "-publish", feedDir); err != nil {
+		t.Fatalf("build v3 failed: %v\n%s", err, out)
+	}
+	if out := run("--proxy-update"); !strings.Contains(out, "Aktualisiert") {
+		t.Fatalf("Expected update to v3:\n%s", out)
+	}
+	os.WriteFile(feedFile, oldFeed, 0644)
+	os.WriteFile(feedFile+".minisig", oldSig, 0644)
+	if out := run("--proxy-update"); !strings.Contains(out, "älter als der zuletzt gesehene") {
+		t.Errorf("Expected replayed feed to be refused:\n%s", out)
+	}
+	if out := run("x"); out != "v3 x\n" {
+		t.Errorf("Replayed feed must not downgrade, got %q", out)
+	}
+}
+
+func TestSelfUpdate_ForeignFeed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("replaces the test binary while running")
+	}
+	f := newUpdateFixture(t)
+	feedDir := filepath.Join(f.dir, "feed")
+	update := &proxy.UpdateConfig{Feed: feedDir, PublicKey: f.publicKey}
+
+	tool := filepath.Join(t.TempDir(), "tool")
+	if out, err := f.build("v1", "1000", tool, update, "-sign-key", f.keyFile, "-publish", feedDir); err != nil {
+		t.Fatalf("build v1 failed: %v\n%s", err, out)
+	}
+
+	// Ein anderer Proxy, mit demselben Schlüssel signiert, in einem eigenen Feed
+	otherFeedDir := filepath.Join(f.dir, "other-feed")
+	otherUpdate := &proxy.UpdateConfig{Feed: otherFeedDir, PublicKey: f.publicKey}
+	if out, err := f.build("evil", "2000", filepath.Join(f.dir, "other"), otherUpdate, "-sign-key", f.keyFile, "-publish", otherFeedDir); err != nil {
+		t.Fatalf("build other failed: %v\n%s", err, out)
+	}
+	for _, file := range []string{"stable.json", "stable.json.minisig"} {
+		data, err := os.ReadFile(filepath.Join(otherFeedDir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(feedDir, file), data, 0644)
+	}
+	os.MkdirAll(filepath.Join(feedDir, "stable", runtime.GOOS+"-"+runtime.GOARCH), 0755)
+	data, _ := os.ReadFile(filepath.Join(otherFeedDir, "stable", runtime.GOOS+"-"+runtime.GOARCH, "other"))
+	os.WriteFile(filepath.Join(feedDir, "stable", runtime.GOOS+"-"+runtime.GOARCH, "other"), data, 0755)
+
+	cmd := exec.Command(tool, "--proxy-update")
+	cmd.Env = cacheEnv(t, t.TempDir())
+	out, err := cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "der Feed gehört zu other, nicht zu tool") {
+		t.Errorf("Expected foreign feed to be refused: %v\n%s", err, out)
+	}
+	cmd = exec.Command(tool, "x")
+	cmd.Env = cacheEnv(t, t.TempDir())
+	if out, _ := cmd.CombinedOutput(); string(out) != "v1 x\n" {
+		t.Errorf("Foreign feed must not replace the executable, got %q", out)
+	}
+}
+
+func TestSelfUpdate_AutoCheckHTTP(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("replaces the test binary while running")
+	}
+	f := newUpdateFixture(t)
+	feedDir := filepath.Join(f.dir, "feed")
+	server := httptest.NewServer(http.FileServer(http.Dir(feedDir)))
+	defer server.Close()
+	update := &proxy.UpdateConfig{Feed: server.URL, Channel: "beta", PublicKey: f.publicKey, CheckInterval: "1h"}
+
+	tool := filepath.Join(t.TempDir(), "tool")
+	if out, err := f.build("v1", "1000", tool, update); err != nil {
+		t.Fatalf("build v1 failed: %v\n%s", err, out)
+	}
+	if out, err := f.build("v2", "2000", filepath.Join(f.dir, "tool"), update, "-sign-key", f.keyFile, "-publish", feedDir); err != nil {
+		t.Fatalf("build v2 failed: %v\n%s", err, out)
+	}
+
+	cacheDir := t.TempDir()
+	run := func() string {
+		t.Helper()
+		cmd := exec.Command(tool, "x")
+		cmd.Env = cacheEnv(t, cacheDir)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("proxy failed: %v\n%s", err, out)
+		}
+		return string(out)
+	}
+
+	// Der erste Aufruf läuft noch mit v1 und aktualisiert danach
+	if out := run(); !strings.HasPrefix(out, "v1 x\n") || !strings.Contains(out, "wurde aktualisiert") {
+		t.Errorf("Expected v1 run with update notice, got %q", out)
+	}
+	if out := run(); out != "v2 x\n" {
+		t.Errorf("Expected v2 without a new check, got %q", out)
+	}
+}
+
+func TestPublish_Errors(t *testing.T) {
+	f := newUpdateFixture(t)
+	other := filepath.Join(f.dir, "other.pem")
+	if out, err := exec.Command(f.proxyBuild, "-gen-sign-key", other).CombinedOutput(); err != nil {
+		t.Fatalf("key generation failed: %v\n%s", err, out)
+	}
+	update := &proxy.UpdateConfig{Feed: "/srv/feed", PublicKey: f.publicKey}
+	output := filepath.Join(f.dir, "tool")
+	feedDir := filepath.Join(f.dir, "feed")
+
+	tests := []struct {
+		name   string
+		update *proxy.UpdateConfig
+		args   []string
+		want   string
+	}{
+		{"no update section", nil, []string{"-sign-key", f.keyFile}, "braucht eine update-Section"},
+		{"no sign key", update, nil, "braucht -sign-key"},
+		{"other key", update, []string{"-sign-key", other}, "passt nicht zu update.public_key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := f.build("v1", "1000", output, tt.update, append(tt.args, "-publish", feedDir)...)
+			if err == nil || !strings.Contains(out, tt.want) {
+				t.Errorf("Expected error containing %q, got %v\n%s", tt.want, err, out)
+			}
+		})
+	}
+}
